@@ -17,6 +17,7 @@ func newRootCmd() *cobra.Command {
 		registry:           runtime.PrometheusRegistry(),
 	}))
 	cmd.AddCommand(newVersionCmd())
+	cmd.AddCommand(newSignCmd())
 	return cmd
 }
 
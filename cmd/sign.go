@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/gojek/darkroom/pkg/config"
+	"github.com/gojek/darkroom/pkg/signature"
+	"github.com/spf13/cobra"
+)
+
+func newSignCmd() *cobra.Command {
+	args := struct {
+		key       string
+		expiresIn time.Duration
+	}{}
+	cmd := &cobra.Command{
+		Use:   "sign [url]",
+		Short: "Generate a signed URL",
+		Long: `Generate a signed URL for the given URL or path along with its query params.
+The first key in signature.keys is used if a key is not passed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, a []string) error {
+			key := args.key
+			if key == "" && len(config.SignatureKeys()) > 0 {
+				key = config.SignatureKeys()[0]
+			}
+			if key == "" {
+				return errors.New("a key is required to sign the url")
+			}
+			u, err := url.Parse(a[0])
+			if err != nil {
+				return err
+			}
+			var expiresAt time.Time
+			if args.expiresIn > 0 {
+				expiresAt = time.Now().Add(args.expiresIn)
+			}
+			cmd.Println(signature.SignURL(key, u, expiresAt).String())
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringVarP(&args.key, "key", "k", "", "key used to sign the url")
+	cmd.PersistentFlags().DurationVarP(&args.expiresIn, "expires-in", "e", 0, "duration after which the url expires, eg: 24h")
+	return cmd
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/config"
+	"github.com/gojek/darkroom/pkg/signature"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/suite"
+)
+
+type SignCmdSuite struct {
+	suite.Suite
+	rootCmd *cobra.Command
+	buf     *bytes.Buffer
+}
+
+func TestSignCmd(t *testing.T) {
+	suite.Run(t, new(SignCmdSuite))
+}
+
+func (s *SignCmdSuite) SetupTest() {
+	s.rootCmd = &cobra.Command{
+		Use: "app",
+	}
+	s.rootCmd.AddCommand(newSignCmd())
+	s.buf = &bytes.Buffer{}
+	s.rootCmd.SetOut(s.buf)
+	config.Viper().Set("signature.keys", []string{"new-secret", "old-secret"})
+	config.Update()
+}
+
+func (s *SignCmdSuite) TearDownTest() {
+	config.Viper().Set("signature.keys", []string{})
+	config.Update()
+}
+
+func (s *SignCmdSuite) TestSignWithConfiguredKey() {
+	s.rootCmd.SetArgs([]string{"sign", "https://example.com/image.jpg?w=100"})
+	s.NoError(s.rootCmd.Execute())
+
+	u, err := url.Parse(strings.TrimSpace(s.buf.String()))
+	s.NoError(err)
+	s.Equal("/image.jpg", u.Path)
+	s.Equal("100", u.Query().Get("w"))
+	s.Equal("", u.Query().Get(signature.ExpiresParam))
+	s.NoError(signature.NewVerifier("new-secret").Verify(u.Path, u.Query()))
+}
+
+func (s *SignCmdSuite) TestSignWithKeyAndExpiry() {
+	s.rootCmd.SetArgs([]string{"sign", "/image.jpg?w=100", "--key", "another-secret", "--expires-in", "1h"})
+	s.NoError(s.rootCmd.Execute())
+
+	u, err := url.Parse(strings.TrimSpace(s.buf.String()))
+	s.NoError(err)
+	s.NotEqual("", u.Query().Get(signature.ExpiresParam))
+	s.NoError(signature.NewVerifier("another-secret").Verify(u.Path, u.Query()))
+}
+
+func (s *SignCmdSuite) TestSignWithoutKey() {
+	config.Viper().Set("signature.keys", []string{})
+	config.Update()
+	s.rootCmd.SetArgs([]string{"sign", "/image.jpg"})
+	s.Error(s.rootCmd.Execute())
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AcceptHeader is the request header key used by clients to list the media types they can display
+const AcceptHeader = "Accept"
+
+// negotiableFormats are the media types which auto=format can pick from the Accept header, ordered by the
+// preference of the server which breaks the ties between the ones with the same quality value. The other
+// media types don't change the processed image
+var negotiableFormats = []string{"image/avif", "image/webp"}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// getAcceptedFormats returns the media types and ranges listed in the Accept header without their parameters,
+// ordered by their quality value as described in https://tools.ietf.org/html/rfc7231#section-5.3.2. Among the ones
+// with the same quality value, the negotiable formats come first in the order of negotiableFormats and the others
+// keep the order of the header. The ones with a quality value of 0 are left out.
+// Wildcards, eg: image/* or */*, are kept as they are but they don't make AVIF or WebP accepted, since the
+// browsers which can't display them send wildcards as well.
+func getAcceptedFormats(accept string) []string {
+	var ranges []mediaRange
+	for _, r := range strings.Split(accept, ",") {
+		parts := strings.Split(r, ";")
+		mr := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(parts[0])), q: 1}
+		if mr.mediaType == "" {
+			continue
+		}
+		for _, p := range parts[1:] {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(k), "q") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q >= 0 && q <= 1 {
+				mr.q = q
+			}
+		}
+		if mr.q > 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return getPreference(ranges[i].mediaType) < getPreference(ranges[j].mediaType)
+	})
+	var formats []string
+	for _, mr := range ranges {
+		formats = append(formats, mr.mediaType)
+	}
+	return formats
+}
+
+// getPreference returns the index of the media type in negotiableFormats, the other media types come after them
+func getPreference(mediaType string) int {
+	for i, nf := range negotiableFormats {
+		if mediaType == nf {
+			return i
+		}
+	}
+	return len(negotiableFormats)
+}
+
+// getFormatVariant returns the negotiable formats accepted by the caller in the order they are picked by
+// auto=format. A processed image can be encoded differently for each variant, so it is a part of the ETag
+// and the process key.
+func getFormatVariant(formats []string) string {
+	var accepted []string
+	for _, f := range formats {
+		if getPreference(f) < len(negotiableFormats) {
+			accepted = append(accepted, f)
+		}
+	}
+	return strings.Join(accepted, ",")
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAcceptedFormats(t *testing.T) {
+	cases := []struct {
+		name     string
+		accept   string
+		expected []string
+	}{
+		{name: "Empty", accept: "", expected: nil},
+		{name: "Browser", accept: "image/avif,image/webp,image/apng,image/*,*/*;q=0.8",
+			expected: []string{"image/avif", "image/webp", "image/apng", "image/*", "*/*"}},
+		{name: "CaseAndSpaces", accept: " Image/WebP ; Q=0.9 , image/png,",
+			expected: []string{"image/png", "image/webp"}},
+		{name: "OrderedByQuality", accept: "image/webp;q=0.5,image/avif;q=0.9,image/png",
+			expected: []string{"image/png", "image/avif", "image/webp"}},
+		{name: "ServerPreferenceBreaksTies", accept: "image/png,image/webp,image/avif",
+			expected: []string{"image/avif", "image/webp", "image/png"}},
+		{name: "ClientPreferenceFirst", accept: "image/avif;q=0.8,image/webp,*/*;q=0.8",
+			expected: []string{"image/webp", "image/avif", "*/*"}},
+		{name: "ZeroQuality", accept: "image/webp;q=0,image/avif;q=0.000,image/*",
+			expected: []string{"image/*"}},
+		{name: "WildcardsOnly", accept: "image/*;q=0.8,*/*;q=0.5", expected: []string{"image/*", "*/*"}},
+		{name: "InvalidQuality", accept: "image/webp;q=abc,image/avif;q=2", expected: []string{"image/avif", "image/webp"}},
+		{name: "OtherParams", accept: "image/webp;level=1;q=0.4,image/png", expected: []string{"image/png", "image/webp"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, getAcceptedFormats(c.accept))
+		})
+	}
+}
+
+func TestGetFormatVariant(t *testing.T) {
+	assert.Equal(t, "", getFormatVariant(nil))
+	assert.Equal(t, "", getFormatVariant([]string{"image/png", "image/*", "*/*"}))
+	assert.Equal(t, "image/webp", getFormatVariant([]string{"image/webp", "image/png"}))
+	assert.Equal(t, "image/avif,image/webp", getFormatVariant([]string{"image/avif", "image/webp"}))
+	assert.Equal(t, "image/webp,image/avif", getFormatVariant([]string{"image/webp", "image/png", "image/avif"}))
+	assert.Equal(t, "image/avif,image/webp", getFormatVariant(getAcceptedFormats("image/webp,image/avif")))
+	assert.Equal(t, "image/webp,image/avif", getFormatVariant(getAcceptedFormats("image/webp,image/avif;q=0.9")))
+	assert.Equal(t, "image/webp", getFormatVariant(getAcceptedFormats("image/avif;q=0,image/webp")))
+}
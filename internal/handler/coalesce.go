@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojek/darkroom/pkg/metrics"
+	"github.com/gojek/darkroom/pkg/storage"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	fetchStage   = "fetch"
+	processStage = "process"
+)
+
+// coalescer shares the result of a fetch or a processing job with the identical requests which
+// arrive while it is in flight, so that a burst of requests for the same image does the work only once
+type coalescer struct {
+	fetches       singleflight.Group
+	processes     singleflight.Group
+	metricService metrics.MetricService
+}
+
+func newCoalescer(metricService metrics.MetricService) *coalescer {
+	return &coalescer{metricService: metricService}
+}
+
+// get fetches the path from the storage backend, concurrent calls for the same path share one Storage.Get
+func (c *coalescer) get(ctx context.Context, s storage.Storage, path string) storage.IResponse {
+	v, _ := c.do(&c.fetches, fetchStage, path, func() (interface{}, error) {
+		// The response is shared with the other requests, so it shouldn't fail if this request is cancelled
+		return s.Get(detachedContext{ctx}, path), nil
+	})
+	return v.(storage.IResponse)
+}
+
+// process runs the processing job, concurrent calls with the same key share one run of fn
+func (c *coalescer) process(key string, fn func() (processResult, error)) (processResult, error) {
+	v, err := c.do(&c.processes, processStage, key, func() (interface{}, error) {
+		return fn()
+	})
+	return v.(processResult), err
+}
+
+func (c *coalescer) do(g *singleflight.Group, stage, key string, fn func() (interface{}, error)) (interface{}, error) {
+	executed := false
+	v, err, shared := g.Do(key, func() (interface{}, error) {
+		executed = true
+		return fn()
+	})
+	if shared && !executed {
+		c.metricService.CountCoalescedRequests(stage)
+	}
+	return v, err
+}
+
+// detachedContext keeps the values of the parent context but is never cancelled
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
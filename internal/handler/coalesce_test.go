@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojek/darkroom/pkg/metrics"
+	"github.com/gojek/darkroom/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+const concurrentRequests = 10
+
+type blockingStorage struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (s *blockingStorage) Get(ctx context.Context, path string) storage.IResponse {
+	atomic.AddInt32(&s.calls, 1)
+	<-s.release
+	if ctx.Err() != nil {
+		return storage.NewResponse(nil, http.StatusUnprocessableEntity, ctx.Err())
+	}
+	return storage.NewResponse([]byte(path), http.StatusOK, nil)
+}
+
+func (s *blockingStorage) GetPartially(ctx context.Context, path string, _ *storage.GetPartiallyRequestOptions) storage.IResponse {
+	return s.Get(ctx, path)
+}
+
+func runConcurrently(fn func()) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrentRequests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCoalescerGet(t *testing.T) {
+	ms := &metrics.MockMetricService{}
+	ms.On("CountCoalescedRequests", fetchStage)
+	s := &blockingStorage{release: make(chan struct{})}
+	c := newCoalescer(ms)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		close(s.release)
+	}()
+
+	runConcurrently(func() {
+		res := c.get(ctx, s, "/image-valid")
+		assert.NoError(t, res.Error())
+		assert.Equal(t, "/image-valid", string(res.Data()))
+	})
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&s.calls))
+	ms.AssertNumberOfCalls(t, "CountCoalescedRequests", concurrentRequests-1)
+}
+
+func TestCoalescerProcess(t *testing.T) {
+	ms := &metrics.MockMetricService{}
+	ms.On("CountCoalescedRequests", processStage)
+	c := newCoalescer(ms)
+	var calls int32
+
+	runConcurrently(func() {
+		res, err := c.process("/image-valid#?w=100", func() (processResult, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(100 * time.Millisecond)
+			return processResult{data: []byte("processedData"), format: "webp"}, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, processResult{data: []byte("processedData"), format: "webp"}, res)
+	})
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	ms.AssertNumberOfCalls(t, "CountCoalescedRequests", concurrentRequests-1)
+}
+
+func TestCoalescerProcessWithError(t *testing.T) {
+	c := newCoalescer(metrics.NoOpMetricService{})
+	calls := 0
+	fn := func() (processResult, error) {
+		calls++
+		return processResult{}, errors.New("error")
+	}
+
+	_, err := c.process("/image-valid#?w=100", fn)
+	assert.Error(t, err)
+	_, err = c.process("/image-valid#?w=100", fn)
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gojek/darkroom/pkg/storage"
+)
+
+const weakETagPrefix = "W/"
+
+// normalizeParams returns the params as a query string sorted by key, so that the same set of
+// params always results in the same string irrespective of the order in which they were requested
+func normalizeParams(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(k))
+		sb.WriteByte('=')
+		sb.WriteString(url.QueryEscape(params[k]))
+	}
+	return sb.String()
+}
+
+// getETag returns the ETag of the response. Unprocessed responses reuse the ETag of the source object, while
+// processed responses get a strong ETag derived from the source object's ETag, the normalized params and the
+// format variant (see getFormatVariant).
+// An empty string is returned if the storage backend didn't provide an ETag for the source object.
+func getETag(metadata *storage.ResponseMetadata, processed bool, params map[string]string, variant string) string {
+	if metadata == nil || metadata.ETag == "" {
+		return ""
+	}
+	if !processed {
+		return quoteETag(metadata.ETag)
+	}
+	h := sha1.New()
+	_, _ = h.Write([]byte(metadata.ETag))
+	_, _ = h.Write([]byte{'?'})
+	_, _ = h.Write([]byte(normalizeParams(params)))
+	if variant != "" {
+		_, _ = h.Write([]byte{'#'})
+		_, _ = h.Write([]byte(variant))
+	}
+	return quoteETag(hex.EncodeToString(h.Sum(nil)))
+}
+
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, weakETagPrefix) || strings.HasPrefix(etag, `"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// isNotModified evaluates the If-None-Match and If-Modified-Since request headers against the etag and
+// lastModified values of the response as described in https://tools.ietf.org/html/rfc7232#section-6
+func isNotModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get(IfNoneMatchHeader); inm != "" {
+		return etag != "" && eTagMatches(inm, etag)
+	}
+	ims := r.Header.Get(IfModifiedSinceHeader)
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// eTagMatches uses the weak comparison function, which is the one If-None-Match requires
+func eTagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, weakETagPrefix)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, weakETagPrefix) == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeParams(t *testing.T) {
+	assert.Equal(t, "", normalizeParams(nil))
+	assert.Equal(t, "fit=crop&h=100&w=200", normalizeParams(map[string]string{"w": "200", "fit": "crop", "h": "100"}))
+	assert.Equal(t, "auto=compress%2Cformat", normalizeParams(map[string]string{"auto": "compress,format"}))
+}
+
+func TestGetETag(t *testing.T) {
+	metadata := &storage.ResponseMetadata{ETag: `"32705ce195789d7bf07f3d44783c2988"`}
+	params := map[string]string{"w": "100"}
+
+	assert.Equal(t, "", getETag(nil, true, params, ""))
+	assert.Equal(t, "", getETag(&storage.ResponseMetadata{}, true, params, ""))
+	assert.Equal(t, `"32705ce195789d7bf07f3d44783c2988"`, getETag(metadata, false, nil, ""))
+	assert.Equal(t, `"CJ2Cq9LR9OQCEAE="`, getETag(&storage.ResponseMetadata{ETag: "CJ2Cq9LR9OQCEAE="}, false, nil, ""))
+
+	processed := getETag(metadata, true, params, "")
+	assert.Len(t, processed, 42)
+	assert.Equal(t, processed, getETag(metadata, true, map[string]string{"w": "100"}, ""))
+	assert.NotEqual(t, processed, getETag(metadata, true, map[string]string{"w": "200"}, ""))
+	assert.NotEqual(t, processed, getETag(&storage.ResponseMetadata{ETag: `"another"`}, true, params, ""))
+	assert.NotEqual(t, processed, getETag(metadata, true, params, "image/webp"))
+	assert.NotEqual(t, getETag(metadata, true, params, "image/avif,image/webp"), getETag(metadata, true, params, "image/webp"))
+	assert.Equal(t, getETag(metadata, false, nil, ""), getETag(metadata, false, nil, "image/webp"))
+}
+
+func TestIsNotModified(t *testing.T) {
+	etag := `"32705ce195789d7bf07f3d44783c2988"`
+	lastModified := "Wed, 21 Oct 2015 07:28:00 GMT"
+	cases := []struct {
+		name     string
+		headers  map[string]string
+		expected bool
+	}{
+		{name: "WithoutConditionalHeaders", expected: false},
+		{name: "WithMatchingETag", headers: map[string]string{IfNoneMatchHeader: etag}, expected: true},
+		{name: "WithMatchingWeakETag", headers: map[string]string{IfNoneMatchHeader: "W/" + etag}, expected: true},
+		{name: "WithETagList", headers: map[string]string{IfNoneMatchHeader: `"foo", ` + etag}, expected: true},
+		{name: "WithWildcard", headers: map[string]string{IfNoneMatchHeader: "*"}, expected: true},
+		{name: "WithDifferentETag", headers: map[string]string{IfNoneMatchHeader: `"foo"`}, expected: false},
+		{
+			name: "IfNoneMatchTakesPrecedence",
+			headers: map[string]string{
+				IfNoneMatchHeader:     `"foo"`,
+				IfModifiedSinceHeader: lastModified,
+			},
+			expected: false,
+		},
+		{name: "WithSameModifiedTime", headers: map[string]string{IfModifiedSinceHeader: lastModified}, expected: true},
+		{name: "WithLaterModifiedTime", headers: map[string]string{IfModifiedSinceHeader: "Thu, 22 Oct 2015 07:28:00 GMT"}, expected: true},
+		{name: "WithEarlierModifiedTime", headers: map[string]string{IfModifiedSinceHeader: "Tue, 20 Oct 2015 07:28:00 GMT"}, expected: false},
+		{name: "WithInvalidModifiedTime", headers: map[string]string{IfModifiedSinceHeader: "yesterday"}, expected: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "/image-valid", nil)
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+			assert.Equal(t, c.expected, isNotModified(r, etag, lastModified))
+		})
+	}
+}
+
+func TestIsNotModifiedWithoutValidators(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid", nil)
+	r.Header.Set(IfNoneMatchHeader, "*")
+	r.Header.Set(IfModifiedSinceHeader, "Wed, 21 Oct 2015 07:28:00 GMT")
+	assert.False(t, isNotModified(r, "", ""))
+}
@@ -1,65 +1,285 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gojek/darkroom/pkg/config"
 	"github.com/gojek/darkroom/pkg/logger"
+	"github.com/gojek/darkroom/pkg/processor"
 	"github.com/gojek/darkroom/pkg/service"
+	"github.com/gojek/darkroom/pkg/signature"
+	"github.com/gojek/darkroom/pkg/storage"
+	"github.com/gorilla/mux"
 )
 
 const (
 	// ContentLengthHeader is the response header key used to set content length
 	ContentLengthHeader = "Content-Length"
+	// ContentTypeHeader is the response header key used to set the media type of the image
+	ContentTypeHeader = "Content-Type"
 	// CacheControlHeader is the response header key used to set cache control
 	CacheControlHeader = "Cache-Control"
 	// VaryHeader is the response header key used to indicate the CDN that the response should depend on client's accept header
 	// Ref: https://tools.ietf.org/html/rfc7231#section-7.1.4
 	VaryHeader = "Vary"
+	// ETagHeader is the response header key used to set the entity tag of the response
+	ETagHeader = "ETag"
+	// LastModifiedHeader is the response header key used to set the last modified time of the source image
+	LastModifiedHeader = "Last-Modified"
+	// IfNoneMatchHeader is the request header key used by clients to revalidate a cached response with its ETag
+	IfNoneMatchHeader = "If-None-Match"
+	// IfModifiedSinceHeader is the request header key used by clients to revalidate a cached response with its
+	// Last-Modified time
+	IfModifiedSinceHeader = "If-Modified-Since"
+	// RangeHeader is the request header key used by clients to request only a part of the image
+	RangeHeader = "Range"
+	// ContentRangeHeader is the response header key used to indicate which part of the image is being returned
+	ContentRangeHeader = "Content-Range"
+	// AcceptRangesHeader is the response header key used to advertise the support for range requests
+	AcceptRangesHeader = "Accept-Ranges"
 	// StorageGetErrorKey is the key used while pushing metrics update to statsd
 	StorageGetErrorKey = "storage_get_error"
 	// ProcessorErrorKey is the key used while pushing metrics update to statsd
 	ProcessorErrorKey = "processor_error"
+	// SignatureErrorKey is the key used while pushing metrics update to statsd
+	SignatureErrorKey = "signature_error"
+	// ResultStoragePutErrorKey is the key used while pushing metrics update to statsd
+	ResultStoragePutErrorKey = "result_storage_put_error"
+	// ResultStorageDropKey is the key used while pushing metrics update to statsd when a processed image is not
+	// written to the result storage because too many writes are in flight
+	ResultStorageDropKey = "result_storage_drop"
+	// PresetErrorKey is the key used while pushing metrics update to statsd
+	PresetErrorKey = "preset_error"
+	// PresetPathPrefix is the path prefix used to select a preset, eg: /p/thumbnail/path/to/image.jpg
+	PresetPathPrefix = "/p/"
 )
 
-// ImageHandler is responsible for fetching the path from the storage backend and processing it if required
+// ImageHandler is responsible for fetching the path from the storage backend and processing it if required.
+// HEAD requests get the same headers as a GET request without the body.
+// Identical requests which arrive while a fetch or processing job is in flight share its result.
 func ImageHandler(deps *service.Dependencies) http.HandlerFunc {
+	c := newCoalescer(deps.MetricService)
+	rw := newResultWriter(deps)
 	return func(w http.ResponseWriter, r *http.Request) {
 		l := logger.SugaredWithRequest(r)
-		res := deps.Storage.Get(r.Context(), r.URL.Path)
+		values := r.URL.Query()
+		if deps.Verifier != nil {
+			if err := deps.Verifier.Verify(r.URL.Path, values); err != nil {
+				l.Errorf("error from Verifier.Verify: %s", err)
+				deps.MetricService.CountImageHandlerErrors(SignatureErrorKey)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			values.Del(signature.Param)
+			values.Del(signature.ExpiresParam)
+		}
+
+		path := r.URL.Path
+		preset := values.Get(service.PresetParam)
+		if name, ok := mux.Vars(r)[service.PresetParam]; ok {
+			preset = name
+			path = strings.TrimPrefix(path, PresetPathPrefix+name)
+		}
+		hasParams := len(values) > 0 || preset != ""
+		values.Del(service.PresetParam)
+
+		params := make(map[string]string)
+		for v := range values {
+			if len(values.Get(v)) != 0 {
+				params[v] = values.Get(v)
+			}
+		}
+		params, err := deps.Presets.Resolve(preset, params)
+		if err != nil {
+			l.Errorf("error from Presets.Resolve: %s", err)
+			deps.MetricService.CountImageHandlerErrors(PresetErrorKey)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Range is only defined for GET, a HEAD request with a Range header gets the headers of the whole image
+		if rng := r.Header.Get(RangeHeader); rng != "" && r.Method != http.MethodHead && !hasParams && !deps.Manipulator.HasDefaultParams() {
+			if servePartialContent(w, r, deps, path, rng) {
+				return
+			}
+		}
+
+		formats := getAcceptedFormats(r.Header.Get(AcceptHeader))
+		variant := getFormatVariant(formats)
+
+		if deps.ResultStorage != nil && (hasParams || deps.Manipulator.HasDefaultParams()) {
+			if pr, res, ok := getStoredResult(r.Context(), deps, c, path, params, variant); ok {
+				// The stored result gets its validators from its own object as the source object isn't fetched
+				var lastModified string
+				if res.Metadata() != nil {
+					lastModified = res.Metadata().LastModified
+				}
+				etag := getETag(res.Metadata(), true, params, variant)
+				if !writeNotModified(w, r, etag, lastModified) {
+					writeImage(w, r, pr.data, getContentType(pr.format), etag, lastModified)
+				}
+				return
+			}
+		}
+
+		res := c.get(r.Context(), deps.Storage, path)
 		if res.Error() != nil {
 			l.Errorf("error from Storage.Get: %s", res.Error())
 			deps.MetricService.CountImageHandlerErrors(StorageGetErrorKey)
 			w.WriteHeader(res.Status())
 			return
 		}
-		var data []byte
-		var err error
-		data = res.Data()
+		data := res.Data()
+		var contentType, lastModified string
+		if res.Metadata() != nil {
+			contentType = res.Metadata().ContentType
+			lastModified = res.Metadata().LastModified
+		}
 
-		params := make(map[string]string)
-		values := r.URL.Query()
-		if len(values) > 0 || deps.Manipulator.HasDefaultParams() {
-			for v := range values {
-				if len(values.Get(v)) != 0 {
-					params[v] = values.Get(v)
-				}
-			}
-			data, err = deps.Manipulator.Process(service.NewSpecBuilder().WithImageData(data).WithParams(params).Build())
+		processed := hasParams || deps.Manipulator.HasDefaultParams()
+		etag := getETag(res.Metadata(), processed, params, variant)
+		if writeNotModified(w, r, etag, lastModified) {
+			return
+		}
+
+		if processed {
+			pr, err := processImage(r.Context(), deps, c, rw, path, res, params, formats)
 			if err != nil {
 				l.Errorf("error from Manipulator.Process: %s", err)
 				deps.MetricService.CountImageHandlerErrors(ProcessorErrorKey)
-				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.WriteHeader(getProcessErrorStatus(err))
 				return
 			}
+			data = pr.data
+			contentType = getContentType(pr.format)
 		}
+		writeImage(w, r, data, contentType, etag, lastModified)
+	}
+}
+
+// writeNotModified writes a 304 Not Modified and returns true if the request is conditional and its validators
+// match the ETag or the last modified time of the image
+func writeNotModified(w http.ResponseWriter, r *http.Request, etag, lastModified string) bool {
+	if !isNotModified(r, etag, lastModified) {
+		return false
+	}
+	setCacheHeaders(w, etag, lastModified)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// writeImage writes the image with its headers, or only the headers for a HEAD request. The content type is sniffed
+// from the image if it is empty.
+func writeImage(w http.ResponseWriter, r *http.Request, data []byte, contentType, etag, lastModified string) {
+	setCacheHeaders(w, etag, lastModified)
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	w.Header().Set(ContentTypeHeader, contentType)
+	w.Header().Set(ContentLengthHeader, fmt.Sprintf("%d", len(data)))
 
-		w.Header().Set(CacheControlHeader, fmt.Sprintf("public,max-age=%d", config.CacheTime()))
-		// Ref to Google CDN we support: https://cloud.google.com/cdn/docs/caching#cacheability
-		w.Header().Set(VaryHeader, "Accept")
-		w.Header().Set(ContentLengthHeader, fmt.Sprintf("%d", len(data)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(data)
+}
+
+// servePartialContent passes the range request through to the storage backend, it is only used for
+// requests which don't need any processing as a part of an image can't be decoded on its own.
+// It returns false without writing the response if the backend can't parse the range, so that the range is
+// ignored and the whole image is served with 200 OK as allowed by RFC 7233.
+func servePartialContent(w http.ResponseWriter, r *http.Request, deps *service.Dependencies, path, rng string) bool {
+	l := logger.SugaredWithRequest(r)
+	res := deps.Storage.GetPartially(r.Context(), path, &storage.GetPartiallyRequestOptions{Range: rng})
+	if errors.Is(res.Error(), storage.ErrInvalidRange) {
+		l.Debugf("ignoring the range %s: %s", rng, res.Error())
+		return false
+	}
+	if res.Error() != nil {
+		l.Errorf("error from Storage.GetPartially: %s", res.Error())
+		deps.MetricService.CountImageHandlerErrors(StorageGetErrorKey)
+		w.WriteHeader(res.Status())
+		return true
+	}
+	data := res.Data()
+	metadata := res.Metadata()
+	if metadata == nil {
+		metadata = &storage.ResponseMetadata{}
+	}
+
+	etag := getETag(metadata, false, nil, "")
+	if isNotModified(r, etag, metadata.LastModified) {
+		setCacheHeaders(w, etag, metadata.LastModified)
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
 
-		_, _ = w.Write(data)
+	setCacheHeaders(w, etag, metadata.LastModified)
+	if metadata.AcceptRanges != "" {
+		w.Header().Set(AcceptRangesHeader, metadata.AcceptRanges)
+	}
+	if metadata.ContentRange != "" {
+		w.Header().Set(ContentRangeHeader, metadata.ContentRange)
+	}
+	contentType := metadata.ContentType
+	if contentType == "" && res.Status() == http.StatusOK {
+		contentType = http.DetectContentType(data)
+	}
+	if contentType != "" {
+		w.Header().Set(ContentTypeHeader, contentType)
+	}
+	w.Header().Set(ContentLengthHeader, fmt.Sprintf("%d", len(data)))
+	// Backends which can't serve a part of the object return the whole of it with 200 OK
+	w.WriteHeader(res.Status())
+
+	_, _ = w.Write(data)
+	return true
+}
+
+// getProcessErrorStatus returns the status code of the response to a request which failed to be processed,
+// the images in formats which can't be decoded are rejected with 415 Unsupported Media Type and the requests
+// with an invalid rect with 400 Bad Request
+func getProcessErrorStatus(err error) int {
+	var ufe *processor.UnsupportedFormatError
+	if errors.As(err, &ufe) {
+		return http.StatusUnsupportedMediaType
+	}
+	if errors.Is(err, service.ErrInvalidRect) {
+		return http.StatusBadRequest
+	}
+	return http.StatusUnprocessableEntity
+}
+
+// getContentType returns the media type of an image encoded in the given format, an empty string is returned
+// for unknown formats so that the caller can fall back to sniffing the content
+func getContentType(format string) string {
+	switch format {
+	case processor.ExtensionJPG, processor.ExtensionJPEG:
+		return "image/jpeg"
+	case processor.ExtensionPNG:
+		return "image/png"
+	case processor.ExtensionWebP:
+		return "image/webp"
+	case processor.ExtensionAVIF:
+		return "image/avif"
+	case processor.ExtensionGIF:
+		return "image/gif"
+	default:
+		return ""
+	}
+}
+
+func setCacheHeaders(w http.ResponseWriter, etag, lastModified string) {
+	w.Header().Set(CacheControlHeader, fmt.Sprintf("public,max-age=%d", config.CacheTime()))
+	// Ref to Google CDN we support: https://cloud.google.com/cdn/docs/caching#cacheability
+	w.Header().Set(VaryHeader, AcceptHeader)
+	if etag != "" {
+		w.Header().Set(ETagHeader, etag)
+	}
+	if lastModified != "" {
+		w.Header().Set(LastModifiedHeader, lastModified)
 	}
 }
@@ -6,13 +6,20 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gojek/darkroom/pkg/metrics"
 
+	"github.com/gojek/darkroom/pkg/cache"
 	"github.com/gojek/darkroom/pkg/config"
+	"github.com/gojek/darkroom/pkg/processor"
 	"github.com/gojek/darkroom/pkg/service"
+	"github.com/gojek/darkroom/pkg/signature"
 	"github.com/gojek/darkroom/pkg/storage"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -59,7 +66,7 @@ func (s *ImageHandlerTestSuite) TestImageHandlerWithDefaultParams() {
 
 	s.storage.On("Get", mock.Anything, "/image-valid").Return(data, http.StatusOK, nil)
 	s.manipulator.On("HasDefaultParams").Return(true)
-	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return(data, nil)
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return(data, "png", nil)
 
 	ImageHandler(s.deps).ServeHTTP(rr, r)
 
@@ -91,7 +98,7 @@ func (s *ImageHandlerTestSuite) TestImageHandlerWithQueryParameters() {
 	params["w"] = "100"
 	params["h"] = "100"
 	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
-	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return(processedData, nil)
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return(processedData, "png", nil)
 
 	ImageHandler(s.deps).ServeHTTP(rr, r)
 
@@ -100,6 +107,48 @@ func (s *ImageHandlerTestSuite) TestImageHandlerWithQueryParameters() {
 	assert.Equal(s.T(), fmt.Sprintf("%d", len(processedData)), rr.Header().Get(ContentLengthHeader))
 	assert.Equal(s.T(), fmt.Sprintf("public,max-age=%d", maxAge), rr.Header().Get(CacheControlHeader))
 	assert.Equal(s.T(), "Accept", rr.Header().Get(VaryHeader))
+	assert.Equal(s.T(), "image/png", rr.Header().Get(ContentTypeHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerSetsContentTypeOfEncodedFormat() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid.png?w=100", nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid.png").
+		Return([]byte("validData"), http.StatusOK, nil, &storage.ResponseMetadata{ContentType: "image/png"})
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte("processedData"), "webp", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "image/webp", rr.Header().Get(ContentTypeHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithoutParamsUsesSourceContentType() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid", nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").
+		Return([]byte("validData"), http.StatusOK, nil, &storage.ResponseMetadata{ContentType: "image/gif"})
+	s.manipulator.On("HasDefaultParams").Return(false)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "image/gif", rr.Header().Get(ContentTypeHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithoutParamsAndContentTypeSniffsContent() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid", nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("\x89PNG\r\n\x1a\n"), http.StatusOK, nil)
+	s.manipulator.On("HasDefaultParams").Return(false)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "image/png", rr.Header().Get(ContentTypeHeader))
 }
 
 func (s *ImageHandlerTestSuite) TestImageHandlerWithQueryParametersAndProcessingError() {
@@ -110,7 +159,7 @@ func (s *ImageHandlerTestSuite) TestImageHandlerWithQueryParametersAndProcessing
 	params["w"] = "100"
 	params["h"] = "100"
 	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
-	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte(nil), errors.New("error"))
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte(nil), "", errors.New("error"))
 	s.mockMetricService.On("CountImageHandlerErrors", "processor_error")
 
 	ImageHandler(s.deps).ServeHTTP(rr, r)
@@ -120,13 +169,486 @@ func (s *ImageHandlerTestSuite) TestImageHandlerWithQueryParametersAndProcessing
 	assert.Equal(s.T(), http.StatusUnprocessableEntity, rr.Code)
 }
 
+func (s *ImageHandlerTestSuite) TestImageHandlerWithUnsupportedFormat() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?w=100", nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("%PDF-1.4"), http.StatusOK, nil)
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).
+		Return([]byte(nil), "", &processor.UnsupportedFormatError{Format: "application/pdf"})
+	s.mockMetricService.On("CountImageHandlerErrors", "processor_error")
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.mockMetricService.AssertCalled(s.T(), "CountImageHandlerErrors", "processor_error")
+	assert.Equal(s.T(), http.StatusUnsupportedMediaType, rr.Code)
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithStoredResult() {
+	resultStorage := &mockStorage{}
+	s.deps.ResultStorage = resultStorage
+	metadata := &storage.ResponseMetadata{ETag: `"result-etag"`, LastModified: "Wed, 21 Oct 2015 07:28:00 GMT"}
+	params := map[string]string{"w": "100"}
+	resultStorage.On("Get", mock.Anything, getResultPath("/image-valid", params, "image/webp")).
+		Return(webpData, http.StatusOK, nil, metadata)
+	etag := getETag(metadata, true, params, "image/webp")
+
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?w=100", nil)
+	r.Header.Set(AcceptHeader, "image/webp")
+	rr := httptest.NewRecorder()
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), webpData, rr.Body.Bytes())
+	assert.Equal(s.T(), "image/webp", rr.Header().Get(ContentTypeHeader))
+	assert.Equal(s.T(), etag, rr.Header().Get(ETagHeader))
+
+	r.Header.Set(IfNoneMatchHeader, etag)
+	rr = httptest.NewRecorder()
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusNotModified, rr.Code)
+	s.storage.AssertNotCalled(s.T(), "Get", mock.Anything, mock.Anything)
+	s.manipulator.AssertNotCalled(s.T(), "Process", mock.Anything)
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithInvalidRect() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?rect=0,0,500,500", nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).
+		Return([]byte(nil), "", fmt.Errorf("%w: 0,0,500,500 is not inside the 100x100 image", service.ErrInvalidRect))
+	s.mockMetricService.On("CountImageHandlerErrors", "processor_error")
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.mockMetricService.AssertCalled(s.T(), "CountImageHandlerErrors", "processor_error")
+	assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerSetsETagAndLastModified() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?w=100", nil)
+	rr := httptest.NewRecorder()
+	metadata := &storage.ResponseMetadata{ETag: `"source-etag"`, LastModified: "Wed, 21 Oct 2015 07:28:00 GMT"}
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil, metadata)
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte("processedData"), "webp", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), getETag(metadata, true, map[string]string{"w": "100"}, ""), rr.Header().Get(ETagHeader))
+	assert.Equal(s.T(), metadata.LastModified, rr.Header().Get(LastModifiedHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithAcceptHeader() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?w=100", nil)
+	r.Header.Set(AcceptHeader, "image/avif,image/webp,*/*;q=0.8")
+	rr := httptest.NewRecorder()
+	metadata := &storage.ResponseMetadata{ETag: `"source-etag"`}
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil, metadata)
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte("processedData"), "avif", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "image/avif", rr.Header().Get(ContentTypeHeader))
+	assert.Equal(s.T(), getETag(metadata, true, map[string]string{"w": "100"}, "image/avif,image/webp"),
+		rr.Header().Get(ETagHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithoutParamsUsesSourceETag() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid", nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").
+		Return([]byte("validData"), http.StatusOK, nil, &storage.ResponseMetadata{ETag: `"source-etag"`})
+	s.manipulator.On("HasDefaultParams").Return(false)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), `"source-etag"`, rr.Header().Get(ETagHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithMatchingIfNoneMatch() {
+	metadata := &storage.ResponseMetadata{ETag: `"source-etag"`}
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?w=100", nil)
+	r.Header.Set(IfNoneMatchHeader, getETag(metadata, true, map[string]string{"w": "100"}, ""))
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil, metadata)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.manipulator.AssertNotCalled(s.T(), "Process", mock.Anything)
+	assert.Equal(s.T(), http.StatusNotModified, rr.Code)
+	assert.Equal(s.T(), "", rr.Body.String())
+	assert.Equal(s.T(), r.Header.Get(IfNoneMatchHeader), rr.Header().Get(ETagHeader))
+	assert.Equal(s.T(), fmt.Sprintf("public,max-age=%d", config.CacheTime()), rr.Header().Get(CacheControlHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithStaleIfNoneMatch() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?w=100", nil)
+	r.Header.Set(IfNoneMatchHeader, `"stale-etag"`)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").
+		Return([]byte("validData"), http.StatusOK, nil, &storage.ResponseMetadata{ETag: `"source-etag"`})
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte("processedData"), "webp", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "processedData", rr.Body.String())
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithIfModifiedSince() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?w=100", nil)
+	r.Header.Set(IfModifiedSinceHeader, "Thu, 22 Oct 2015 07:28:00 GMT")
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").
+		Return([]byte("validData"), http.StatusOK, nil, &storage.ResponseMetadata{LastModified: "Wed, 21 Oct 2015 07:28:00 GMT"})
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.manipulator.AssertNotCalled(s.T(), "Process", mock.Anything)
+	assert.Equal(s.T(), http.StatusNotModified, rr.Code)
+	assert.Equal(s.T(), "Wed, 21 Oct 2015 07:28:00 GMT", rr.Header().Get(LastModifiedHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithRangeAndWithoutParams() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid", nil)
+	r.Header.Set(RangeHeader, "bytes=0-3")
+	rr := httptest.NewRecorder()
+	metadata := &storage.ResponseMetadata{
+		AcceptRanges:  "bytes",
+		ContentLength: "4",
+		ContentRange:  "bytes 0-3/9",
+		ContentType:   "image/jpeg",
+		ETag:          `"source-etag"`,
+	}
+
+	s.manipulator.On("HasDefaultParams").Return(false)
+	s.storage.On("GetPartially", mock.Anything, "/image-valid", &storage.GetPartiallyRequestOptions{Range: "bytes=0-3"}).
+		Return([]byte("vali"), http.StatusPartialContent, nil, metadata)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.storage.AssertNotCalled(s.T(), "Get", mock.Anything, mock.Anything)
+	assert.Equal(s.T(), http.StatusPartialContent, rr.Code)
+	assert.Equal(s.T(), "vali", rr.Body.String())
+	assert.Equal(s.T(), "bytes 0-3/9", rr.Header().Get(ContentRangeHeader))
+	assert.Equal(s.T(), "bytes", rr.Header().Get(AcceptRangesHeader))
+	assert.Equal(s.T(), `"source-etag"`, rr.Header().Get(ETagHeader))
+	assert.Equal(s.T(), "image/jpeg", rr.Header().Get(ContentTypeHeader))
+	assert.Equal(s.T(), "4", rr.Header().Get(ContentLengthHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithOpenEndedRange() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid", nil)
+	r.Header.Set(RangeHeader, "bytes=0-")
+	rr := httptest.NewRecorder()
+	metadata := &storage.ResponseMetadata{AcceptRanges: "bytes", ContentRange: "bytes 0-8/9", ContentType: "image/jpeg"}
+
+	s.manipulator.On("HasDefaultParams").Return(false)
+	s.storage.On("GetPartially", mock.Anything, "/image-valid", &storage.GetPartiallyRequestOptions{Range: "bytes=0-"}).
+		Return([]byte("validData"), http.StatusPartialContent, nil, metadata)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusPartialContent, rr.Code)
+	assert.Equal(s.T(), "validData", rr.Body.String())
+	assert.Equal(s.T(), "bytes 0-8/9", rr.Header().Get(ContentRangeHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithRangeUnsupportedByStorage() {
+	for _, rng := range []string{"bytes=0-", "bytes=0-1,4-5"} {
+		s.SetupTest()
+		r, _ := http.NewRequest(http.MethodGet, "/image-valid", nil)
+		r.Header.Set(RangeHeader, rng)
+		rr := httptest.NewRecorder()
+
+		s.manipulator.On("HasDefaultParams").Return(false)
+		s.storage.On("GetPartially", mock.Anything, "/image-valid", &storage.GetPartiallyRequestOptions{Range: rng}).
+			Return([]byte(nil), http.StatusUnprocessableEntity, storage.ErrInvalidRange, (*storage.ResponseMetadata)(nil))
+		s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+
+		ImageHandler(s.deps).ServeHTTP(rr, r)
+
+		// The range is ignored and the whole image is served
+		assert.Equal(s.T(), http.StatusOK, rr.Code, rng)
+		assert.Equal(s.T(), "validData", rr.Body.String(), rng)
+		assert.Equal(s.T(), "", rr.Header().Get(ContentRangeHeader), rng)
+		s.mockMetricService.AssertNotCalled(s.T(), "CountImageHandlerErrors", mock.Anything)
+	}
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithRangeAndStorageGetPartiallyError() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid", nil)
+	r.Header.Set(RangeHeader, "bytes=100-200")
+	rr := httptest.NewRecorder()
+
+	s.manipulator.On("HasDefaultParams").Return(false)
+	s.storage.On("GetPartially", mock.Anything, "/image-valid", &storage.GetPartiallyRequestOptions{Range: "bytes=100-200"}).
+		Return([]byte(nil), http.StatusRequestedRangeNotSatisfiable, errors.New("error"), (*storage.ResponseMetadata)(nil))
+	s.mockMetricService.On("CountImageHandlerErrors", "storage_get_error")
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.mockMetricService.AssertCalled(s.T(), "CountImageHandlerErrors", "storage_get_error")
+	assert.Equal(s.T(), http.StatusRequestedRangeNotSatisfiable, rr.Code)
+	assert.Equal(s.T(), "", rr.Body.String())
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithRangeAndQueryParameters() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?w=100", nil)
+	r.Header.Set(RangeHeader, "bytes=0-3")
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte("processedData"), "png", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.storage.AssertNotCalled(s.T(), "GetPartially", mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "processedData", rr.Body.String())
+	assert.Equal(s.T(), "", rr.Header().Get(ContentRangeHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithRangeAndDefaultParams() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid", nil)
+	r.Header.Set(RangeHeader, "bytes=0-3")
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+	s.manipulator.On("HasDefaultParams").Return(true)
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte("processedData"), "png", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.storage.AssertNotCalled(s.T(), "GetPartially", mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "processedData", rr.Body.String())
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithHeadAndWithoutParams() {
+	r, _ := http.NewRequest(http.MethodHead, "/image-valid", nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").
+		Return([]byte("validData"), http.StatusOK, nil, &storage.ResponseMetadata{ContentType: "image/jpeg", ETag: `"source-etag"`})
+	s.manipulator.On("HasDefaultParams").Return(false)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.manipulator.AssertNotCalled(s.T(), "Process", mock.Anything)
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "", rr.Body.String())
+	assert.Equal(s.T(), "9", rr.Header().Get(ContentLengthHeader))
+	assert.Equal(s.T(), "image/jpeg", rr.Header().Get(ContentTypeHeader))
+	assert.Equal(s.T(), `"source-etag"`, rr.Header().Get(ETagHeader))
+	assert.Equal(s.T(), fmt.Sprintf("public,max-age=%d", config.CacheTime()), rr.Header().Get(CacheControlHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithHeadAndQueryParameters() {
+	r, _ := http.NewRequest(http.MethodHead, "/image-valid?w=100", nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte("processedData"), "webp", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "", rr.Body.String())
+	assert.Equal(s.T(), "13", rr.Header().Get(ContentLengthHeader))
+	assert.Equal(s.T(), "image/webp", rr.Header().Get(ContentTypeHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithHeadIgnoresRange() {
+	r, _ := http.NewRequest(http.MethodHead, "/image-valid", nil)
+	r.Header.Set(RangeHeader, "bytes=0-3")
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+	s.manipulator.On("HasDefaultParams").Return(false)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.storage.AssertNotCalled(s.T(), "GetPartially", mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "9", rr.Header().Get(ContentLengthHeader))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithValidSignature() {
+	s.deps.Verifier = signature.NewVerifier("secret")
+	u, _ := url.Parse("/image-valid?w=100")
+	r, _ := http.NewRequest(http.MethodGet, signature.SignURL("secret", u, time.Now().Add(time.Hour)).String(), nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+	s.manipulator.On("Process", mock.MatchedBy(func(spec interface{}) bool {
+		params := reflect.ValueOf(spec).FieldByName("Params")
+		return params.Len() == 1 && params.MapIndex(reflect.ValueOf("w")).String() == "100"
+	})).Return([]byte("processedData"), "png", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "processedData", rr.Body.String())
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithSignatureAndWithoutParams() {
+	s.deps.Verifier = signature.NewVerifier("secret")
+	u, _ := url.Parse("/image-valid")
+	r, _ := http.NewRequest(http.MethodGet, signature.SignURL("secret", u, time.Time{}).String(), nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+	s.manipulator.On("HasDefaultParams").Return(false)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	s.manipulator.AssertNotCalled(s.T(), "Process", mock.Anything)
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "validData", rr.Body.String())
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithInvalidSignature() {
+	s.deps.Verifier = signature.NewVerifier("secret")
+	u, _ := url.Parse("/image-valid?w=100")
+	signed := signature.SignURL("another-secret", u, time.Time{})
+	cases := []string{"/image-valid?w=9999", signed.String(), signature.SignURL("secret", u, time.Now().Add(-time.Hour)).String()}
+	s.mockMetricService.On("CountImageHandlerErrors", "signature_error")
+
+	for _, c := range cases {
+		r, _ := http.NewRequest(http.MethodGet, c, nil)
+		rr := httptest.NewRecorder()
+
+		ImageHandler(s.deps).ServeHTTP(rr, r)
+
+		assert.Equal(s.T(), http.StatusForbidden, rr.Code, c)
+	}
+	s.storage.AssertNotCalled(s.T(), "Get", mock.Anything, mock.Anything)
+	s.mockMetricService.AssertNumberOfCalls(s.T(), "CountImageHandlerErrors", len(cases))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithPresetParam() {
+	s.deps.Presets, _ = service.NewPresets(map[string]string{"thumbnail": "w=200&h=200&fit=crop"}, false)
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?preset=thumbnail&w=100", nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+	s.manipulator.On("Process", mock.MatchedBy(func(spec interface{}) bool {
+		params := reflect.ValueOf(spec).FieldByName("Params").Interface().(map[string]string)
+		return reflect.DeepEqual(map[string]string{"w": "100", "h": "200", "fit": "crop"}, params)
+	})).Return([]byte("processedData"), "png", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "processedData", rr.Body.String())
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithPresetPath() {
+	s.deps.Presets, _ = service.NewPresets(map[string]string{"thumbnail": "w=200&h=200&fit=crop"}, true)
+	r, _ := http.NewRequest(http.MethodGet, "/p/thumbnail/image-valid", nil)
+	r = mux.SetURLVars(r, map[string]string{"preset": "thumbnail"})
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+	s.manipulator.On("Process", mock.MatchedBy(func(spec interface{}) bool {
+		params := reflect.ValueOf(spec).FieldByName("Params").Interface().(map[string]string)
+		return reflect.DeepEqual(map[string]string{"w": "200", "h": "200", "fit": "crop"}, params)
+	})).Return([]byte("processedData"), "png", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "processedData", rr.Body.String())
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithPresetParamWithoutPresets() {
+	r, _ := http.NewRequest(http.MethodGet, "/image-valid?preset=thumbnail&w=100", nil)
+	rr := httptest.NewRecorder()
+
+	s.storage.On("Get", mock.Anything, "/image-valid").Return([]byte("validData"), http.StatusOK, nil)
+	s.manipulator.On("Process", mock.MatchedBy(func(spec interface{}) bool {
+		params := reflect.ValueOf(spec).FieldByName("Params").Interface().(map[string]string)
+		return reflect.DeepEqual(map[string]string{"w": "100"}, params)
+	})).Return([]byte("processedData"), "png", nil)
+
+	ImageHandler(s.deps).ServeHTTP(rr, r)
+
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+	assert.Equal(s.T(), "processedData", rr.Body.String())
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithInvalidPreset() {
+	only, _ := service.NewPresets(map[string]string{"thumbnail": "w=200&h=200&fit=crop"}, true)
+	cases := []struct {
+		presets *service.Presets
+		url     string
+	}{
+		{presets: only, url: "/image-valid?preset=hero"},
+		{presets: only, url: "/image-valid?w=100"},
+		{presets: only, url: "/image-valid?preset=thumbnail&w=100"},
+	}
+	s.mockMetricService.On("CountImageHandlerErrors", "preset_error")
+
+	for _, c := range cases {
+		s.deps.Presets = c.presets
+		r, _ := http.NewRequest(http.MethodGet, c.url, nil)
+		rr := httptest.NewRecorder()
+
+		ImageHandler(s.deps).ServeHTTP(rr, r)
+
+		assert.Equal(s.T(), http.StatusBadRequest, rr.Code, c.url)
+	}
+	s.storage.AssertNotCalled(s.T(), "Get", mock.Anything, mock.Anything)
+	s.mockMetricService.AssertNumberOfCalls(s.T(), "CountImageHandlerErrors", len(cases))
+}
+
+func (s *ImageHandlerTestSuite) TestImageHandlerWithCache() {
+	s.deps.Cache = cache.NewMemory(1024, metrics.NoOpMetricService{})
+	s.storage.On("Get", mock.Anything, "/image-valid").
+		Return([]byte("validData"), http.StatusOK, nil, &storage.ResponseMetadata{ETag: `"source-etag"`})
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte("processedData"), "webp", nil)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodGet} {
+		r, _ := http.NewRequest(method, "/image-valid?w=100", nil)
+		rr := httptest.NewRecorder()
+
+		ImageHandler(s.deps).ServeHTTP(rr, r)
+
+		assert.Equal(s.T(), http.StatusOK, rr.Code)
+		assert.Equal(s.T(), "image/webp", rr.Header().Get(ContentTypeHeader))
+		assert.Equal(s.T(), "13", rr.Header().Get(ContentLengthHeader))
+	}
+	s.manipulator.AssertNumberOfCalls(s.T(), "Process", 1)
+}
+
 type mockStorage struct {
 	mock.Mock
 }
 
 func (m *mockStorage) Get(ctx context.Context, path string) storage.IResponse {
 	args := m.Called(ctx, path)
-	return storage.NewResponse(args[0].([]byte), args.Int(1), args.Error(2))
+	res := storage.NewResponse(args[0].([]byte), args.Int(1), args.Error(2))
+	if len(args) > 3 {
+		res.WithMetadata(args[3].(*storage.ResponseMetadata))
+	}
+	return res
+}
+
+func (m *mockStorage) Put(ctx context.Context, path string, data []byte, opt *storage.PutRequestOptions) error {
+	args := m.Called(ctx, path, data, opt)
+	return args.Error(0)
 }
 
 func (m *mockStorage) GetPartially(ctx context.Context, path string, opt *storage.GetPartiallyRequestOptions) storage.IResponse {
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gojek/darkroom/pkg/cache"
+	"github.com/gojek/darkroom/pkg/logger"
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/gojek/darkroom/pkg/service"
+	"github.com/gojek/darkroom/pkg/storage"
+)
+
+type processResult struct {
+	data   []byte
+	format string
+}
+
+// maxPendingResultWrites is the number of processed images which can be written to the result storage at the
+// same time, the writes of the images processed while all of them are in flight are dropped
+const maxPendingResultWrites = 64
+
+// processImage returns the processed image from the cache if it has it, otherwise it processes the image and stores
+// the result in the cache and the result storage, which is written to asynchronously by rw. The result storage is
+// looked up by getStoredResult before the source image is fetched. The formats are the media types accepted by the
+// caller.
+func processImage(ctx context.Context, deps *service.Dependencies, c *coalescer, rw *resultWriter, path string,
+	res storage.IResponse, params map[string]string, formats []string) (processResult, error) {
+	variant := getFormatVariant(formats)
+	key := getProcessKey(path, res.Metadata(), params, variant)
+	if deps.Cache != nil {
+		if e, ok := deps.Cache.Get(key); ok {
+			return processResult{data: e.Data, format: e.Format}, nil
+		}
+	}
+	return c.process(key, func() (processResult, error) {
+		assets, err := fetchAssets(ctx, deps, c, service.AssetPaths(params))
+		if err != nil {
+			return processResult{}, err
+		}
+		d, f, err := deps.Manipulator.Process(service.NewSpecBuilder().
+			WithImageData(res.Data()).
+			WithParams(params).
+			WithFormats(formats).
+			WithTargetFormat(service.GetTargetFormat(params)).
+			WithAssets(assets).
+			Build())
+		if err != nil {
+			return processResult{}, err
+		}
+		pr := processResult{data: d, format: f}
+		setCache(deps.Cache, key, pr)
+		rw.put(getResultPath(path, params, variant), pr)
+		return pr, nil
+	})
+}
+
+// getStoredResult returns the image processed with the params for the format variant (see getFormatVariant) from
+// the result storage and its response, and false if the result storage is not configured or doesn't have it.
+// It doesn't need the source image, so that the images which were processed before are never fetched again.
+// The results written more than deps.ResultMaxAge ago are processed again, which picks up the changes of the source.
+func getStoredResult(ctx context.Context, deps *service.Dependencies, c *coalescer, path string,
+	params map[string]string, variant string) (processResult, storage.IResponse, bool) {
+	if deps.ResultStorage == nil {
+		return processResult{}, nil, false
+	}
+	res := c.get(ctx, deps.ResultStorage, getResultPath(path, params, variant))
+	if res.Error() != nil {
+		return processResult{}, nil, false
+	}
+	if deps.ResultMaxAge > 0 && !isFresh(res.Metadata(), deps.ResultMaxAge) {
+		return processResult{}, nil, false
+	}
+	return processResult{data: res.Data(), format: processor.DetectFormat(res.Data())}, res, true
+}
+
+// isFresh returns true if the object of md was last modified less than maxAge ago, the objects without a valid
+// Last-Modified are never fresh
+func isFresh(md *storage.ResponseMetadata, maxAge time.Duration) bool {
+	if md == nil {
+		return false
+	}
+	t, err := http.ParseTime(md.LastModified)
+	return err == nil && time.Since(t) < maxAge
+}
+
+// fetchAssets returns the assets at the paths from the asset cache, the ones which are not cached are fetched from
+// the Storage and cached, so that an asset which is used by many images isn't downloaded on every request
+func fetchAssets(ctx context.Context, deps *service.Dependencies, c *coalescer, paths []string) (map[string][]byte, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	assets := make(map[string][]byte, len(paths))
+	for _, p := range paths {
+		if deps.AssetCache != nil {
+			if e, ok := deps.AssetCache.Get(p); ok {
+				assets[p] = e.Data
+				continue
+			}
+		}
+		res := c.get(ctx, deps.Storage, p)
+		if res.Error() != nil {
+			return nil, fmt.Errorf("error fetching asset %s: %w", p, res.Error())
+		}
+		assets[p] = res.Data()
+		if deps.AssetCache != nil {
+			deps.AssetCache.Set(p, &cache.Entry{Data: res.Data()})
+		}
+	}
+	return assets, nil
+}
+
+func setCache(ch cache.Cache, key string, pr processResult) {
+	if ch != nil {
+		ch.Set(key, &cache.Entry{Data: pr.data, Format: pr.format})
+	}
+}
+
+// resultWriter writes the processed images to the result storage in the background, at most
+// maxPendingResultWrites at a time
+type resultWriter struct {
+	deps    *service.Dependencies
+	pending chan struct{}
+}
+
+func newResultWriter(deps *service.Dependencies) *resultWriter {
+	return &resultWriter{deps: deps, pending: make(chan struct{}, maxPendingResultWrites)}
+}
+
+// put writes the processed image to the result storage without waiting for it, the image is dropped if the result
+// storage is not configured or if too many writes are in flight, it is then written when it is processed again
+func (rw *resultWriter) put(resultPath string, pr processResult) {
+	if rw.deps.ResultStorage == nil {
+		return
+	}
+	select {
+	case rw.pending <- struct{}{}:
+	default:
+		rw.deps.MetricService.CountImageHandlerErrors(ResultStorageDropKey)
+		return
+	}
+	go func() {
+		defer func() { <-rw.pending }()
+		opt := &storage.PutRequestOptions{ContentType: getContentType(pr.format)}
+		if err := rw.deps.ResultStorage.Put(context.Background(), resultPath, pr.data, opt); err != nil {
+			logger.Errorf("error from ResultStorage.Put: %s", err)
+			rw.deps.MetricService.CountImageHandlerErrors(ResultStoragePutErrorKey)
+		}
+	}()
+}
+
+// getProcessKey returns the key which identifies the result of processing the source object at the path with the
+// params for the format variant (see getFormatVariant). The ETag of the source object is a part of the key, so that
+// a modified object doesn't get a stale result.
+func getProcessKey(path string, metadata *storage.ResponseMetadata, params map[string]string, variant string) string {
+	var etag string
+	if metadata != nil {
+		etag = metadata.ETag
+	}
+	key := path + "#" + etag + "?" + normalizeParams(params)
+	if variant != "" {
+		key += "#" + variant
+	}
+	return key
+}
+
+// getResultPath returns the deterministic path of the image processed with the params for the format variant in the
+// result storage, the processed images of a source object are kept next to each other under its path. The path
+// doesn't depend on the version of the source object, so its results have to be deleted when it is overwritten.
+func getResultPath(path string, params map[string]string, variant string) string {
+	sum := sha1.Sum([]byte(getProcessKey(path, nil, params, variant)))
+	return path + "/" + hex.EncodeToString(sum[:])
+}
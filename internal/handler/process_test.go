@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gojek/darkroom/pkg/cache"
+	"github.com/gojek/darkroom/pkg/metrics"
+	"github.com/gojek/darkroom/pkg/service"
+	"github.com/gojek/darkroom/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+var webpData = []byte("RIFF\x00\x00\x00\x00WEBPVP8 ")
+
+type ProcessImageTestSuite struct {
+	suite.Suite
+	deps          *service.Dependencies
+	manipulator   *service.MockManipulator
+	resultStorage *mockStorage
+	metricService *metrics.MockMetricService
+	source        storage.IResponse
+	params        map[string]string
+	formats       []string
+	key           string
+}
+
+func TestProcessImageSuite(t *testing.T) {
+	suite.Run(t, new(ProcessImageTestSuite))
+}
+
+func (s *ProcessImageTestSuite) SetupTest() {
+	s.manipulator = &service.MockManipulator{}
+	s.resultStorage = &mockStorage{}
+	s.metricService = &metrics.MockMetricService{}
+	s.deps = &service.Dependencies{
+		Manipulator:   s.manipulator,
+		MetricService: s.metricService,
+		Cache:         cache.NewMemory(1024, metrics.NoOpMetricService{}),
+		ResultStorage: s.resultStorage,
+	}
+	s.source = storage.NewResponse([]byte("validData"), http.StatusOK, nil).
+		WithMetadata(&storage.ResponseMetadata{ETag: `"source-etag"`})
+	s.params = map[string]string{"w": "100"}
+	s.formats = []string{"image/webp", "*/*"}
+	s.key = getProcessKey("/image-valid", s.source.Metadata(), s.params, "image/webp")
+}
+
+func (s *ProcessImageTestSuite) process() (processResult, error) {
+	return processImage(context.Background(), s.deps, newCoalescer(s.metricService), newResultWriter(s.deps),
+		"/image-valid", s.source, s.params, s.formats)
+}
+
+func (s *ProcessImageTestSuite) waitFor(done chan struct{}, msg string) {
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.Fail(msg)
+	}
+}
+
+func (s *ProcessImageTestSuite) TestWithCachedResult() {
+	s.deps.Cache.Set(s.key, &cache.Entry{Data: webpData, Format: "webp"})
+
+	pr, err := s.process()
+
+	s.NoError(err)
+	s.Equal(processResult{data: webpData, format: "webp"}, pr)
+	s.resultStorage.AssertNotCalled(s.T(), "Get", mock.Anything, mock.Anything)
+	s.manipulator.AssertNotCalled(s.T(), "Process", mock.Anything)
+}
+
+func (s *ProcessImageTestSuite) TestWritesResult() {
+	resultPath := getResultPath("/image-valid", s.params, "image/webp")
+	done := make(chan struct{})
+	s.resultStorage.On("Put", mock.Anything, resultPath, webpData, &storage.PutRequestOptions{ContentType: "image/webp"}).
+		Run(func(mock.Arguments) { close(done) }).
+		Return(nil)
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return(webpData, "webp", nil)
+
+	pr, err := s.process()
+
+	s.NoError(err)
+	s.Equal(processResult{data: webpData, format: "webp"}, pr)
+	s.waitFor(done, "processed image was not written to ResultStorage")
+	s.resultStorage.AssertNotCalled(s.T(), "Get", mock.Anything, mock.Anything)
+	_, ok := s.deps.Cache.Get(s.key)
+	s.True(ok)
+}
+
+func (s *ProcessImageTestSuite) TestWithResultStoragePutError() {
+	resultPath := getResultPath("/image-valid", s.params, "image/webp")
+	s.resultStorage.On("Put", mock.Anything, resultPath, webpData, mock.Anything).Return(errors.New("error"))
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return(webpData, "webp", nil)
+	done := make(chan struct{})
+	s.metricService.On("CountImageHandlerErrors", ResultStoragePutErrorKey).Run(func(mock.Arguments) {
+		close(done)
+	})
+
+	_, err := s.process()
+
+	s.NoError(err)
+	s.waitFor(done, "error from ResultStorage.Put was not counted")
+}
+
+func (s *ProcessImageTestSuite) TestGetStoredResult() {
+	resultPath := getResultPath("/image-valid", s.params, "image/webp")
+	metadata := &storage.ResponseMetadata{ETag: `"result-etag"`}
+	s.resultStorage.On("Get", mock.Anything, resultPath).Return(webpData, http.StatusOK, nil, metadata).Once()
+	s.resultStorage.On("Get", mock.Anything, resultPath).Return([]byte(nil), http.StatusNotFound, errors.New("not found"))
+	c := newCoalescer(s.metricService)
+
+	pr, res, ok := getStoredResult(context.Background(), s.deps, c, "/image-valid", s.params, "image/webp")
+
+	s.True(ok)
+	s.Equal(processResult{data: webpData, format: "webp"}, pr)
+	s.Equal(metadata, res.Metadata())
+
+	_, _, ok = getStoredResult(context.Background(), s.deps, c, "/image-valid", s.params, "image/webp")
+	s.False(ok)
+
+	s.deps.ResultStorage = nil
+	_, _, ok = getStoredResult(context.Background(), s.deps, c, "/image-valid", s.params, "image/webp")
+	s.False(ok)
+	s.resultStorage.AssertNumberOfCalls(s.T(), "Get", 2)
+}
+
+func (s *ProcessImageTestSuite) TestGetStoredResultWithMaxAge() {
+	resultPath := getResultPath("/image-valid", s.params, "image/webp")
+	s.deps.ResultMaxAge = time.Hour
+	cases := []struct {
+		name     string
+		metadata *storage.ResponseMetadata
+		ok       bool
+	}{
+		{name: "Fresh", metadata: &storage.ResponseMetadata{
+			LastModified: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat),
+		}, ok: true},
+		{name: "Stale", metadata: &storage.ResponseMetadata{
+			LastModified: time.Now().Add(-2 * time.Hour).UTC().Format(http.TimeFormat),
+		}},
+		{name: "WithoutLastModified", metadata: &storage.ResponseMetadata{}},
+		{name: "WithoutMetadata"},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			s.resultStorage.ExpectedCalls = nil
+			s.resultStorage.On("Get", mock.Anything, resultPath).Return(webpData, http.StatusOK, nil, c.metadata)
+
+			_, _, ok := getStoredResult(context.Background(), s.deps, newCoalescer(s.metricService), "/image-valid",
+				s.params, "image/webp")
+
+			s.Equal(c.ok, ok)
+		})
+	}
+}
+
+func (s *ProcessImageTestSuite) TestResultWriterDropsWritesWhenFull() {
+	release := make(chan struct{})
+	s.resultStorage.On("Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { <-release }).
+		Return(nil)
+	s.metricService.On("CountImageHandlerErrors", ResultStorageDropKey)
+	rw := newResultWriter(s.deps)
+
+	for i := 0; i < maxPendingResultWrites+10; i++ {
+		rw.put(fmt.Sprintf("/image-valid/%d", i), processResult{data: webpData, format: "webp"})
+	}
+	close(release)
+
+	s.metricService.AssertNumberOfCalls(s.T(), "CountImageHandlerErrors", 10)
+	s.Eventually(func() bool { return len(rw.pending) == 0 }, time.Second, 10*time.Millisecond)
+	s.resultStorage.AssertNumberOfCalls(s.T(), "Put", maxPendingResultWrites)
+}
+
+func (s *ProcessImageTestSuite) TestWithProcessingError() {
+	s.deps.ResultStorage = nil
+	s.manipulator.On("Process", mock.AnythingOfType("service.processSpec")).Return([]byte(nil), "", errors.New("error"))
+
+	_, err := s.process()
+
+	s.Error(err)
+	_, ok := s.deps.Cache.Get(s.key)
+	s.False(ok)
+}
+
+func (s *ProcessImageTestSuite) TestWithAcceptedFormats() {
+	s.deps.ResultStorage = nil
+	s.manipulator.On("Process", mock.MatchedBy(func(spec interface{}) bool {
+		// the methods of the spec have pointer receivers
+		ps := reflect.New(reflect.TypeOf(spec))
+		ps.Elem().Set(reflect.ValueOf(spec))
+		return ps.Interface().(service.ProcessSpec).IsWebPSupported()
+	})).Return(webpData, "webp", nil)
+
+	_, err := s.process()
+
+	s.NoError(err)
+	s.manipulator.AssertExpectations(s.T())
+	_, ok := s.deps.Cache.Get(s.key)
+	s.True(ok)
+}
+
+func (s *ProcessImageTestSuite) TestWithTargetFormat() {
+	s.deps.ResultStorage = nil
+	s.params = map[string]string{"w": "100", service.FormatParam: "png"}
+	s.manipulator.On("Process", mock.MatchedBy(func(spec interface{}) bool {
+		return reflect.ValueOf(spec).FieldByName("TargetFormat").String() == "png"
+	})).Return([]byte("pngData"), "png", nil)
+
+	pr, err := s.process()
+
+	s.NoError(err)
+	s.Equal(processResult{data: []byte("pngData"), format: "png"}, pr)
+	s.manipulator.AssertExpectations(s.T())
+}
+
+func (s *ProcessImageTestSuite) TestWithWatermark() {
+	s.deps.ResultStorage = nil
+	s.deps.AssetCache = cache.NewNamedMemory("assets", 1024, metrics.NoOpMetricService{})
+	sourceStorage := &mockStorage{}
+	s.deps.Storage = sourceStorage
+	sourceStorage.On("Get", mock.Anything, "/logos/brand.png").Return([]byte("markData"), http.StatusOK, nil).Once()
+	assets := map[string][]byte{"/logos/brand.png": []byte("markData")}
+	s.manipulator.On("Process", mock.MatchedBy(func(spec interface{}) bool {
+		return reflect.DeepEqual(reflect.ValueOf(spec).FieldByName("Assets").Interface(), assets)
+	})).Return(webpData, "webp", nil)
+
+	for _, w := range []string{"100", "200"} {
+		s.params = map[string]string{"w": w, "mark": "logos/brand.png"}
+		_, err := s.process()
+		s.NoError(err)
+	}
+
+	sourceStorage.AssertExpectations(s.T())
+	s.manipulator.AssertNumberOfCalls(s.T(), "Process", 2)
+}
+
+func (s *ProcessImageTestSuite) TestWithUnavailableWatermark() {
+	s.deps.ResultStorage = nil
+	sourceStorage := &mockStorage{}
+	s.deps.Storage = sourceStorage
+	sourceStorage.On("Get", mock.Anything, "/logos/brand.png").Return([]byte(nil), http.StatusNotFound, errors.New("not found"))
+	s.params = map[string]string{"mark": "/logos/brand.png"}
+
+	_, err := s.process()
+
+	s.Error(err)
+	s.manipulator.AssertNotCalled(s.T(), "Process", mock.Anything)
+	_, ok := s.deps.Cache.Get(s.key)
+	s.False(ok)
+}
+
+func TestGetProcessKey(t *testing.T) {
+	params := map[string]string{"w": "100", "h": "100"}
+	key := getProcessKey("/image-valid", &storage.ResponseMetadata{ETag: `"etag"`}, params, "")
+
+	assert.Equal(t, `/image-valid#"etag"?h=100&w=100`, key)
+	assert.Equal(t, "/image-valid#?h=100&w=100", getProcessKey("/image-valid", nil, params, ""))
+	assert.Equal(t, "/image-valid#?h=100&w=100#image/webp", getProcessKey("/image-valid", nil, params, "image/webp"))
+	assert.NotEqual(t, key, getProcessKey("/image-valid", &storage.ResponseMetadata{ETag: `"modified"`}, params, ""))
+}
+
+func TestGetResultPath(t *testing.T) {
+	params := map[string]string{"w": "100"}
+	resultPath := getResultPath("/path/to/image.jpg", params, "")
+
+	assert.Equal(t, resultPath, getResultPath("/path/to/image.jpg", map[string]string{"w": "100"}, ""))
+	assert.Regexp(t, "^/path/to/image.jpg/[0-9a-f]{40}$", resultPath)
+	assert.NotEqual(t, resultPath, getResultPath("/path/to/image.jpg", map[string]string{"w": "200"}, ""))
+	assert.NotEqual(t, resultPath, getResultPath("/path/to/image.jpg", params, "image/webp"))
+}
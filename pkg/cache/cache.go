@@ -0,0 +1,32 @@
+// Package cache contains the Cache interface and its implementations which are used to keep the processed images
+// around, so that the same variant of an image isn't processed again on every request
+package cache
+
+const (
+	// HitEvent is the event tracked when a key is found in the cache
+	HitEvent = "hit"
+	// MissEvent is the event tracked when a key is not found in the cache
+	MissEvent = "miss"
+	// EvictionEvent is the event tracked when an entry is removed to make room for a new one
+	EvictionEvent = "eviction"
+)
+
+// Cache interface sets the contract that the implementation has to fulfil.
+type Cache interface {
+	// Get returns the entry stored against the key and true if it is present, it returns nil and false otherwise
+	Get(key string) (*Entry, bool)
+	// Set stores the entry against the key, replacing the existing entry if any
+	Set(key string, e *Entry)
+}
+
+// Entry holds a processed image
+type Entry struct {
+	// Data is the encoded image
+	Data []byte
+	// Format is the format the image is encoded in
+	Format string
+}
+
+func (e *Entry) size() int64 {
+	return int64(len(e.Data) + len(e.Format))
+}
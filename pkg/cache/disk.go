@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojek/darkroom/pkg/logger"
+	"github.com/gojek/darkroom/pkg/metrics"
+)
+
+const (
+	diskCacheName  = "disk"
+	tempFilePrefix = ".tmp-"
+)
+
+// Disk is a least recently used cache which keeps the entries as files in a directory until their total size
+// reaches a byte budget. The entries are written atomically and are picked up again after a restart.
+type Disk struct {
+	mu            sync.Mutex
+	dir           string
+	maxBytes      int64
+	size          int64
+	ll            *list.List
+	items         map[string]*list.Element
+	metricService metrics.MetricService
+}
+
+type diskItem struct {
+	name string
+	size int64
+}
+
+// NewDisk returns a new Disk cache which keeps its files in dir and evicts the least recently used
+// entries once their size goes over maxBytes. The entries already present in dir are loaded.
+func NewDisk(dir string, maxBytes int64, metricService metrics.MetricService) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	d := &Disk{
+		dir:           dir,
+		maxBytes:      maxBytes,
+		ll:            list.New(),
+		items:         make(map[string]*list.Element),
+		metricService: metricService,
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Get returns the entry stored against the key and true if it is present, it returns nil and false otherwise
+func (d *Disk) Get(key string) (*Entry, bool) {
+	h := hash(key)
+	d.mu.Lock()
+	el, ok := d.items[h]
+	if ok {
+		d.ll.MoveToFront(el)
+	}
+	d.mu.Unlock()
+	if ok {
+		name := el.Value.(*diskItem).name
+		data, err := ioutil.ReadFile(filepath.Join(d.dir, name))
+		if err == nil {
+			now := time.Now()
+			// Keeps the recency of the entry across restarts, the entries are loaded in the order of their mtime
+			_ = os.Chtimes(filepath.Join(d.dir, name), now, now)
+			d.metricService.CountCacheEvents(diskCacheName, HitEvent)
+			return &Entry{Data: data, Format: formatFromName(name)}, true
+		}
+		d.mu.Lock()
+		if cur, ok := d.items[h]; ok && cur == el {
+			d.removeElement(el)
+		}
+		d.mu.Unlock()
+	}
+	d.metricService.CountCacheEvents(diskCacheName, MissEvent)
+	return nil, false
+}
+
+// Set writes the entry to a temporary file and renames it, so that a partially written entry is never read.
+// An entry which is larger than the budget on its own is not stored.
+func (d *Disk) Set(key string, e *Entry) {
+	size := int64(len(e.Data))
+	if size > d.maxBytes {
+		return
+	}
+	h := hash(key)
+	name := h
+	if e.Format != "" {
+		name = h + "." + e.Format
+	}
+	if err := d.write(name, e.Data); err != nil {
+		logger.Errorf("error writing to disk cache: %s", err)
+		return
+	}
+
+	d.mu.Lock()
+	if el, ok := d.items[h]; ok {
+		old := d.removeElement(el)
+		if old.name != name {
+			_ = os.Remove(filepath.Join(d.dir, old.name))
+		}
+	}
+	d.items[h] = d.ll.PushFront(&diskItem{name: name, size: size})
+	d.size += size
+	var evicted []*diskItem
+	for d.size > d.maxBytes {
+		evicted = append(evicted, d.removeElement(d.ll.Back()))
+	}
+	d.mu.Unlock()
+
+	for _, item := range evicted {
+		_ = os.Remove(filepath.Join(d.dir, item.name))
+		d.metricService.CountCacheEvents(diskCacheName, EvictionEvent)
+	}
+}
+
+// Size returns the total size of the entries held by the cache
+func (d *Disk) Size() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.size
+}
+
+func (d *Disk) write(name string, data []byte) error {
+	f, err := ioutil.TempFile(d.dir, tempFilePrefix)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err = os.Rename(f.Name(), filepath.Join(d.dir, name)); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// load rebuilds the index from the files in the directory, the most recently used entries are the ones
+// with the latest mtime. Leftover temporary files from an interrupted write are removed, and so are
+// the entries which don't fit in the budget anymore.
+func (d *Disk) load() error {
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(f.Name(), tempFilePrefix) {
+			_ = os.Remove(filepath.Join(d.dir, f.Name()))
+			continue
+		}
+		h := strings.SplitN(f.Name(), ".", 2)[0]
+		if _, err := hex.DecodeString(h); err != nil || len(h) != sha256.Size*2 {
+			// Not an entry of the cache, it is left alone
+			continue
+		}
+		if _, ok := d.items[h]; ok || d.size+f.Size() > d.maxBytes {
+			_ = os.Remove(filepath.Join(d.dir, f.Name()))
+			continue
+		}
+		d.items[h] = d.ll.PushBack(&diskItem{name: f.Name(), size: f.Size()})
+		d.size += f.Size()
+	}
+	return nil
+}
+
+func (d *Disk) removeElement(el *list.Element) *diskItem {
+	item := d.ll.Remove(el).(*diskItem)
+	delete(d.items, strings.SplitN(item.name, ".", 2)[0])
+	d.size -= item.size
+	return item
+}
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func formatFromName(name string) string {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
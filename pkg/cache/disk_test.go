@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gojek/darkroom/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskGetAndSet(t *testing.T) {
+	dir := t.TempDir()
+	ms := &metrics.MockMetricService{}
+	ms.On("CountCacheEvents", "disk", MissEvent)
+	ms.On("CountCacheEvents", "disk", HitEvent)
+	d, err := NewDisk(dir, 1024, ms)
+	assert.NoError(t, err)
+
+	e, ok := d.Get("key")
+	assert.False(t, ok)
+	assert.Nil(t, e)
+
+	d.Set("key", &Entry{Data: []byte("processedData"), Format: "webp"})
+	e, ok = d.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, &Entry{Data: []byte("processedData"), Format: "webp"}, e)
+
+	d.Set("key", &Entry{Data: []byte("reprocessedData"), Format: "png"})
+	e, _ = d.Get("key")
+	assert.Equal(t, &Entry{Data: []byte("reprocessedData"), Format: "png"}, e)
+	assert.Equal(t, int64(len("reprocessedData")), d.Size())
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+	assert.Equal(t, hash("key")+".png", files[0].Name())
+	ms.AssertNumberOfCalls(t, "CountCacheEvents", 3)
+}
+
+func TestDiskEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	ms := &metrics.MockMetricService{}
+	ms.On("CountCacheEvents", "disk", HitEvent)
+	ms.On("CountCacheEvents", "disk", MissEvent)
+	ms.On("CountCacheEvents", "disk", EvictionEvent)
+	d, _ := NewDisk(dir, 350, ms)
+
+	for i := 0; i < 3; i++ {
+		d.Set(fmt.Sprintf("k%d", i), newEntry(100))
+	}
+	_, ok := d.Get("k0")
+	assert.True(t, ok)
+
+	d.Set("k3", newEntry(100))
+
+	_, ok = d.Get("k1")
+	assert.False(t, ok)
+	for _, k := range []string{"k0", "k2", "k3"} {
+		_, ok = d.Get(k)
+		assert.True(t, ok, k)
+	}
+	_, err := os.Stat(filepath.Join(dir, hash("k1")+".png"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, int64(300), d.Size())
+	ms.AssertNumberOfCalls(t, "CountCacheEvents", 6)
+
+	d.Set("large", newEntry(351))
+	_, ok = d.Get("large")
+	assert.False(t, ok)
+}
+
+func TestDiskSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	d, _ := NewDisk(dir, 250, metrics.NoOpMetricService{})
+	for i := 0; i < 3; i++ {
+		d.Set(fmt.Sprintf("k%d", i), newEntry(100))
+		// mtime is the only record of recency across restarts
+		past := time.Now().Add(time.Duration(i-10) * time.Second)
+		_ = os.Chtimes(filepath.Join(dir, hash(fmt.Sprintf("k%d", i))+".png"), past, past)
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, tempFilePrefix+"123"), []byte("partial"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not an entry"), 0644))
+
+	d, err := NewDisk(dir, 250, metrics.NoOpMetricService{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(200), d.Size())
+	e, ok := d.Get("k2")
+	assert.True(t, ok)
+	assert.Equal(t, newEntry(100), e)
+	_, ok = d.Get("k1")
+	assert.True(t, ok)
+	_, ok = d.Get("k0")
+	assert.False(t, ok)
+	_, err = os.Stat(filepath.Join(dir, tempFilePrefix+"123"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "README"))
+	assert.NoError(t, err)
+}
+
+func TestDiskWithFileRemovedExternally(t *testing.T) {
+	dir := t.TempDir()
+	d, _ := NewDisk(dir, 1024, metrics.NoOpMetricService{})
+	d.Set("key", newEntry(100))
+	assert.NoError(t, os.Remove(filepath.Join(dir, hash("key")+".png")))
+
+	_, ok := d.Get("key")
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), d.Size())
+}
+
+func TestNewDiskWithInvalidDirectory(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, ioutil.WriteFile(f, nil, 0644))
+
+	_, err := NewDisk(f, 1024, metrics.NoOpMetricService{})
+	assert.Error(t, err)
+}
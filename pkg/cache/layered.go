@@ -0,0 +1,31 @@
+package cache
+
+// Layered looks up the caches in order, so that a faster cache can be put in front of a larger one
+type Layered struct {
+	caches []Cache
+}
+
+// NewLayered returns a new Layered cache over the caches, the first cache is looked up first
+func NewLayered(caches ...Cache) *Layered {
+	return &Layered{caches: caches}
+}
+
+// Get returns the entry from the first cache which has it and copies it to the caches in front of it
+func (l *Layered) Get(key string) (*Entry, bool) {
+	for i, c := range l.caches {
+		if e, ok := c.Get(key); ok {
+			for j := 0; j < i; j++ {
+				l.caches[j].Set(key, e)
+			}
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Set stores the entry in all of the caches
+func (l *Layered) Set(key string, e *Entry) {
+	for _, c := range l.caches {
+		c.Set(key, e)
+	}
+}
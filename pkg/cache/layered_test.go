@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayered(t *testing.T) {
+	memory := NewMemory(1024, metrics.NoOpMetricService{})
+	disk, _ := NewDisk(t.TempDir(), 1024, metrics.NoOpMetricService{})
+	l := NewLayered(memory, disk)
+
+	_, ok := l.Get("key")
+	assert.False(t, ok)
+
+	l.Set("key", newEntry(10))
+	_, ok = memory.Get("key")
+	assert.True(t, ok)
+	_, ok = disk.Get("key")
+	assert.True(t, ok)
+
+	disk.Set("only-on-disk", newEntry(20))
+	e, ok := l.Get("only-on-disk")
+	assert.True(t, ok)
+	assert.Equal(t, newEntry(20), e)
+	e, ok = memory.Get("only-on-disk")
+	assert.True(t, ok)
+	assert.Equal(t, newEntry(20), e)
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/gojek/darkroom/pkg/metrics"
+)
+
+const memoryCacheName = "memory"
+
+// Memory is an in-process least recently used cache which holds the entries until their total size reaches a byte budget
+type Memory struct {
+	mu            sync.Mutex
+	name          string
+	maxBytes      int64
+	size          int64
+	ll            *list.List
+	items         map[string]*list.Element
+	metricService metrics.MetricService
+}
+
+type memoryItem struct {
+	key   string
+	entry *Entry
+}
+
+// NewMemory returns a new Memory cache which evicts the least recently used entries once the size
+// of the keys and the entries goes over maxBytes
+func NewMemory(maxBytes int64, metricService metrics.MetricService) *Memory {
+	return NewNamedMemory(memoryCacheName, maxBytes, metricService)
+}
+
+// NewNamedMemory returns a new Memory cache like NewMemory, its events are tracked under the name given to it,
+// so that it can be told apart from the cache of processed images
+func NewNamedMemory(name string, maxBytes int64, metricService metrics.MetricService) *Memory {
+	return &Memory{
+		name:          name,
+		maxBytes:      maxBytes,
+		ll:            list.New(),
+		items:         make(map[string]*list.Element),
+		metricService: metricService,
+	}
+}
+
+// Get returns the entry stored against the key and true if it is present, it returns nil and false otherwise
+func (m *Memory) Get(key string) (*Entry, bool) {
+	m.mu.Lock()
+	el, ok := m.items[key]
+	if ok {
+		m.ll.MoveToFront(el)
+	}
+	m.mu.Unlock()
+	if !ok {
+		m.metricService.CountCacheEvents(m.name, MissEvent)
+		return nil, false
+	}
+	m.metricService.CountCacheEvents(m.name, HitEvent)
+	return el.Value.(*memoryItem).entry, true
+}
+
+// Set stores the entry against the key, an entry which is larger than the budget on its own is not stored
+func (m *Memory) Set(key string, e *Entry) {
+	size := int64(len(key)) + e.size()
+	if size > m.maxBytes {
+		return
+	}
+	m.mu.Lock()
+	if el, ok := m.items[key]; ok {
+		m.removeElement(el)
+	}
+	m.items[key] = m.ll.PushFront(&memoryItem{key: key, entry: e})
+	m.size += size
+	evicted := 0
+	for m.size > m.maxBytes {
+		m.removeElement(m.ll.Back())
+		evicted++
+	}
+	m.mu.Unlock()
+	for i := 0; i < evicted; i++ {
+		m.metricService.CountCacheEvents(m.name, EvictionEvent)
+	}
+}
+
+// Size returns the total size of the keys and the entries held by the cache
+func (m *Memory) Size() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.size
+}
+
+func (m *Memory) removeElement(el *list.Element) {
+	item := m.ll.Remove(el).(*memoryItem)
+	delete(m.items, item.key)
+	m.size -= int64(len(item.key)) + item.entry.size()
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newEntry(size int) *Entry {
+	return &Entry{Data: make([]byte, size), Format: "png"}
+}
+
+func TestMemoryGetAndSet(t *testing.T) {
+	ms := &metrics.MockMetricService{}
+	ms.On("CountCacheEvents", "memory", MissEvent)
+	ms.On("CountCacheEvents", "memory", HitEvent)
+	m := NewMemory(1024, ms)
+
+	e, ok := m.Get("key")
+	assert.False(t, ok)
+	assert.Nil(t, e)
+
+	m.Set("key", newEntry(10))
+	e, ok = m.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, newEntry(10), e)
+	assert.Equal(t, int64(len("key")+10+len("png")), m.Size())
+
+	m.Set("key", newEntry(20))
+	e, _ = m.Get("key")
+	assert.Equal(t, newEntry(20), e)
+	assert.Equal(t, int64(len("key")+20+len("png")), m.Size())
+
+	ms.AssertNumberOfCalls(t, "CountCacheEvents", 3)
+}
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	ms := &metrics.MockMetricService{}
+	ms.On("CountCacheEvents", "memory", HitEvent)
+	ms.On("CountCacheEvents", "memory", MissEvent)
+	ms.On("CountCacheEvents", "memory", EvictionEvent)
+	// Every entry takes up 100 bytes, so the cache can hold 3 of them
+	m := NewMemory(350, ms)
+
+	for i := 0; i < 3; i++ {
+		m.Set(fmt.Sprintf("k%d", i), newEntry(100-len("k0")-len("png")))
+	}
+	_, ok := m.Get("k0")
+	assert.True(t, ok)
+
+	m.Set("k3", newEntry(100-len("k3")-len("png")))
+
+	_, ok = m.Get("k1")
+	assert.False(t, ok)
+	for _, k := range []string{"k0", "k2", "k3"} {
+		_, ok = m.Get(k)
+		assert.True(t, ok, k)
+	}
+	assert.Equal(t, int64(300), m.Size())
+	ms.AssertCalled(t, "CountCacheEvents", "memory", EvictionEvent)
+
+	m.Set("large", newEntry(300))
+	assert.Equal(t, int64(len("large")+300+len("png")), m.Size())
+}
+
+func TestMemorySkipsEntriesLargerThanBudget(t *testing.T) {
+	ms := &metrics.MockMetricService{}
+	ms.On("CountCacheEvents", "memory", MissEvent)
+	m := NewMemory(100, ms)
+
+	m.Set("key", newEntry(100))
+
+	_, ok := m.Get("key")
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), m.Size())
+}
+
+func TestNamedMemory(t *testing.T) {
+	ms := &metrics.MockMetricService{}
+	ms.On("CountCacheEvents", "assets", mock.Anything)
+	m := NewNamedMemory("assets", 1024, ms)
+
+	m.Get("key")
+	m.Set("key", newEntry(100))
+	m.Get("key")
+
+	ms.AssertCalled(t, "CountCacheEvents", "assets", MissEvent)
+	ms.AssertCalled(t, "CountCacheEvents", "assets", HitEvent)
+	ms.AssertNotCalled(t, "CountCacheEvents", "memory", mock.Anything)
+}
+
+func TestMemoryConcurrentAccess(t *testing.T) {
+	m := NewMemory(1024, metrics.NoOpMetricService{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				k := fmt.Sprintf("k%d", (i+j)%20)
+				m.Set(k, newEntry(50))
+				m.Get(k)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, m.Size(), int64(1024))
+}
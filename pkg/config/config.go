@@ -3,6 +3,7 @@ package config
 import (
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/afex/hystrix-go/hystrix"
 	"github.com/gojek/darkroom/pkg/storage"
@@ -13,12 +14,38 @@ type config struct {
 	debugMode                       bool
 	cacheTime                       int
 	dataSource                      Source
+	resultStorage                   Source
+	resultMaxAge                    time.Duration
 	enableConcurrentOpacityChecking bool
 	defaultParams                   string
 	metricsSystem                   string
 	statsdConfig                    StatsdCollectorConfig
+	signatureKeys                   []string
+	presets                         map[string]string
+	presetsOnly                     bool
+	memoryCacheSize                 int64
+	assetCacheSize                  int64
+	textFont                        string
+	diskCacheConfig                 DiskCacheConfig
+	jpegEncoderConfig               JpegEncoderConfig
+	webPEncoderConfig               WebPEncoderConfig
+	avifEncoderConfig               AvifEncoderConfig
+	animationConfig                 AnimationConfig
+	svgExternalReferences           bool
+	facesConfig                     FacesConfig
 }
 
+// defaultAssetCacheSize is the byte budget of the asset cache when it is not configured, 32 MiB
+const defaultAssetCacheSize = 32 << 20
+
+const (
+	defaultJpegQuality = 75
+	defaultWebPQuality = 90
+	defaultAvifQuality = 60
+	defaultAvifSpeed   = 6
+	maxAvifSpeed       = 10
+)
+
 var instance *config
 var once sync.Once
 
@@ -31,20 +58,6 @@ func getConfig() *config {
 
 func newConfig() *config {
 	v := Viper()
-	s := Source{
-		Kind: v.GetString("source.kind"),
-		HystrixCommand: storage.HystrixCommand{
-			Name: v.GetString("source.hystrix.commandName"),
-			Config: hystrix.CommandConfig{
-				Timeout:                v.GetInt("source.hystrix.timeout"),
-				MaxConcurrentRequests:  v.GetInt("source.hystrix.maxConcurrentRequests"),
-				RequestVolumeThreshold: v.GetInt("source.hystrix.requestVolumeThreshold"),
-				SleepWindow:            v.GetInt("source.hystrix.sleepWindow"),
-				ErrorPercentThreshold:  v.GetInt("source.hystrix.errorPercentThreshold")},
-		},
-		PathPrefix: v.GetString("source.pathPrefix"),
-	}
-	s.readValue()
 	c := StatsdCollectorConfig{
 		StatsdAddr: v.GetString("metrics.statsd.statsdAddr"),
 		Prefix:     v.GetString("metrics.statsd.prefix"),
@@ -56,12 +69,95 @@ func newConfig() *config {
 		logLevel:                        v.GetString("log.level"),
 		debugMode:                       v.GetBool("debug"),
 		cacheTime:                       v.GetInt("cache.time"),
-		dataSource:                      s,
+		dataSource:                      newSource("source"),
+		resultStorage:                   newSource("resultStorage"),
+		resultMaxAge:                    v.GetDuration("resultStorage.maxAge"),
 		enableConcurrentOpacityChecking: v.GetBool("enableConcurrentOpacityChecking"),
 		defaultParams:                   v.GetString("defaultParams"),
 		metricsSystem:                   v.GetString("metrics.system"),
 		statsdConfig:                    c,
+		signatureKeys:                   v.GetStringSlice("signature.keys"),
+		presets:                         v.GetStringMapString("presets"),
+		presetsOnly:                     v.GetBool("presetsOnly"),
+		memoryCacheSize:                 v.GetInt64("cache.memory.size"),
+		assetCacheSize:                  getAssetCacheSize(v.GetInt64("cache.assets.size")),
+		textFont:                        v.GetString("text.font"),
+		diskCacheConfig: DiskCacheConfig{
+			Path: v.GetString("cache.disk.path"),
+			Size: v.GetInt64("cache.disk.size"),
+		},
+		jpegEncoderConfig: JpegEncoderConfig{Quality: getQuality("encoder.jpeg.quality", defaultJpegQuality)},
+		webPEncoderConfig: WebPEncoderConfig{Quality: getQuality("encoder.webp.quality", defaultWebPQuality)},
+		avifEncoderConfig: getAvifEncoderConfig(),
+		animationConfig: AnimationConfig{
+			MaxPixels:        v.GetInt64("animation.maxPixels"),
+			WebPFrameQuality: clamp(v.GetInt("animation.webpFrameQuality"), 0, 100),
+		},
+		svgExternalReferences: v.GetBool("svg.allowExternalReferences"),
+		facesConfig: FacesConfig{
+			Cascade:  v.GetString("faces.cascade"),
+			Fallback: v.GetString("faces.fallback"),
+		},
+	}
+}
+
+// getQuality returns the configured quality of an encoder clamped from 1 to 100, or the default if it is not set
+func getQuality(key string, defaultQuality int) int {
+	v := Viper()
+	if !v.IsSet(key) {
+		return defaultQuality
 	}
+	return clamp(v.GetInt(key), 1, 100)
+}
+
+// getAvifEncoderConfig returns the configured quality and speed of the AVIF encoder clamped to their ranges,
+// the defaults are used for the values which are not set
+func getAvifEncoderConfig() AvifEncoderConfig {
+	v := Viper()
+	c := AvifEncoderConfig{Quality: defaultAvifQuality, Speed: defaultAvifSpeed}
+	if v.IsSet("encoder.avif.quality") {
+		c.Quality = clamp(v.GetInt("encoder.avif.quality"), 0, 100)
+	}
+	if v.IsSet("encoder.avif.speed") {
+		c.Speed = clamp(v.GetInt("encoder.avif.speed"), 0, maxAvifSpeed)
+	}
+	return c
+}
+
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+func getAssetCacheSize(size int64) int64 {
+	if size <= 0 {
+		return defaultAssetCacheSize
+	}
+	return size
+}
+
+func newSource(key string) Source {
+	v := Viper()
+	s := Source{
+		Kind: v.GetString(key + ".kind"),
+		HystrixCommand: storage.HystrixCommand{
+			Name: v.GetString(key + ".hystrix.commandName"),
+			Config: hystrix.CommandConfig{
+				Timeout:                v.GetInt(key + ".hystrix.timeout"),
+				MaxConcurrentRequests:  v.GetInt(key + ".hystrix.maxConcurrentRequests"),
+				RequestVolumeThreshold: v.GetInt(key + ".hystrix.requestVolumeThreshold"),
+				SleepWindow:            v.GetInt(key + ".hystrix.sleepWindow"),
+				ErrorPercentThreshold:  v.GetInt(key + ".hystrix.errorPercentThreshold")},
+		},
+		PathPrefix: v.GetString(key + ".pathPrefix"),
+	}
+	s.readValue(key)
+	return s
 }
 
 // Update creates a new instance of the configuration and reads all values again
@@ -89,6 +185,18 @@ func DataSource() *Source {
 	return &getConfig().dataSource
 }
 
+// ResultStorage returns the source struct of the bucket where the processed images are stored after it is initialised
+// from the environment values, the processed images are not stored if its Kind is not set
+func ResultStorage() *Source {
+	return &getConfig().resultStorage
+}
+
+// ResultMaxAge returns how long the processed images in the result storage are served for after they are written
+// from the environment, they are served until they are deleted if it is not set
+func ResultMaxAge() time.Duration {
+	return getConfig().resultMaxAge
+}
+
 // ConcurrentOpacityCheckingEnabled returns true if we want to process image using multiple cores (checking isOpaque)
 func ConcurrentOpacityCheckingEnabled() bool {
 	return getConfig().enableConcurrentOpacityChecking
@@ -108,3 +216,72 @@ func MetricsSystem() string {
 func StatsdConfig() *StatsdCollectorConfig {
 	return &getConfig().statsdConfig
 }
+
+// SignatureKeys returns the keys which are accepted for signed URLs from the environment,
+// the first key is used to sign new URLs. Signed URLs are not required if no key is set.
+func SignatureKeys() []string {
+	return getConfig().signatureKeys
+}
+
+// Presets returns the named presets from the environment, each preset is a query string of params, eg: w=200&h=200&fit=crop
+func Presets() map[string]string {
+	return getConfig().presets
+}
+
+// PresetsOnly returns true if the requests are restricted to presets only from the environment
+func PresetsOnly() bool {
+	return getConfig().presetsOnly
+}
+
+// SVGExternalReferencesAllowed returns true if the SVG images may reference external resources from the environment
+func SVGExternalReferencesAllowed() bool {
+	return getConfig().svgExternalReferences
+}
+
+// MemoryCacheSize returns the byte budget of the in-memory cache for processed images from the environment,
+// the cache is disabled if it is not set
+func MemoryCacheSize() int64 {
+	return getConfig().memoryCacheSize
+}
+
+// AssetCacheSize returns the byte budget of the in-memory cache for the assets used while processing,
+// eg: the watermark images, from the environment
+func AssetCacheSize() int64 {
+	return getConfig().assetCacheSize
+}
+
+// TextFont returns the path of the TTF or OTF file which is used to render text from the environment,
+// the bundled font is used if it is not set
+func TextFont() string {
+	return getConfig().textFont
+}
+
+// DiskCache returns the config of the on-disk cache for processed images from the environment
+func DiskCache() *DiskCacheConfig {
+	return &getConfig().diskCacheConfig
+}
+
+// Faces returns the config of the face detection from the environment
+func Faces() *FacesConfig {
+	return &getConfig().facesConfig
+}
+
+// Animation returns the config of the animated images from the environment
+func Animation() *AnimationConfig {
+	return &getConfig().animationConfig
+}
+
+// JpegEncoder returns the config of the JPEG encoder from the environment
+func JpegEncoder() *JpegEncoderConfig {
+	return &getConfig().jpegEncoderConfig
+}
+
+// WebPEncoder returns the config of the WebP encoder from the environment
+func WebPEncoder() *WebPEncoderConfig {
+	return &getConfig().webPEncoderConfig
+}
+
+// AvifEncoder returns the config of the AVIF encoder from the environment
+func AvifEncoder() *AvifEncoderConfig {
+	return &getConfig().avifEncoderConfig
+}
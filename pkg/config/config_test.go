@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,6 +34,14 @@ func TestConfigCasesWithFeatureToggle(t *testing.T) {
 			key:      "debug",
 			callFunc: DebugModeEnabled,
 		},
+		{
+			key:      "presetsOnly",
+			callFunc: PresetsOnly,
+		},
+		{
+			key:      "svg.allowExternalReferences",
+			callFunc: SVGExternalReferencesAllowed,
+		},
 	}
 	for _, c := range cases {
 		assert.Equal(t, v.GetBool(c.key), c.callFunc())
@@ -63,6 +72,7 @@ func TestConfigCasesWithIntValues(t *testing.T) {
 func TestConfigCasesWithStringSliceValues(t *testing.T) {
 	v := Viper()
 	v.Set("defaultParams", "auto=compress")
+	v.Set("signature.keys", []string{"new-secret", "old-secret"})
 	Update()
 	cases := []struct {
 		key      string
@@ -72,9 +82,150 @@ func TestConfigCasesWithStringSliceValues(t *testing.T) {
 			key:      "defaultParams",
 			callFunc: DefaultParams,
 		},
+		{
+			key:      "signature.keys",
+			callFunc: SignatureKeys,
+		},
 	}
 
 	for _, c := range cases {
 		assert.Equal(t, v.GetStringSlice(c.key), c.callFunc())
 	}
 }
+
+func TestConfigCasesWithStringMapValues(t *testing.T) {
+	v := Viper()
+	v.Set("presets", map[string]string{"thumbnail": "w=200&h=200&fit=crop"})
+	Update()
+
+	assert.Equal(t, map[string]string{"thumbnail": "w=200&h=200&fit=crop"}, Presets())
+}
+
+func TestConfigCasesWithInt64Values(t *testing.T) {
+	v := Viper()
+	v.Set("cache.memory.size", 268435456)
+	v.Set("cache.disk.path", "/var/cache/darkroom")
+	v.Set("cache.disk.size", 10737418240)
+	Update()
+
+	assert.Equal(t, int64(268435456), MemoryCacheSize())
+	assert.Equal(t, int64(defaultAssetCacheSize), AssetCacheSize())
+	assert.Equal(t, &DiskCacheConfig{Path: "/var/cache/darkroom", Size: 10737418240}, DiskCache())
+}
+
+func TestAssetCacheSize(t *testing.T) {
+	v := Viper()
+	v.Set("cache.assets.size", 1048576)
+	Update()
+	defer func() {
+		v.Set("cache.assets.size", 0)
+		Update()
+	}()
+
+	assert.Equal(t, int64(1048576), AssetCacheSize())
+}
+
+func TestTextFont(t *testing.T) {
+	v := Viper()
+	v.Set("text.font", "/usr/share/fonts/brand.ttf")
+	Update()
+	defer func() {
+		v.Set("text.font", "")
+		Update()
+	}()
+
+	assert.Equal(t, "/usr/share/fonts/brand.ttf", TextFont())
+}
+
+func TestAvifEncoder(t *testing.T) {
+	assert.Equal(t, &AvifEncoderConfig{Quality: 60, Speed: 6}, AvifEncoder())
+
+	v := Viper()
+	v.Set("encoder.avif.quality", 80)
+	v.Set("encoder.avif.speed", 0)
+	Update()
+	assert.Equal(t, &AvifEncoderConfig{Quality: 80, Speed: 0}, AvifEncoder())
+
+	v.Set("encoder.avif.quality", 120)
+	v.Set("encoder.avif.speed", -1)
+	Update()
+	defer func() {
+		v.Set("encoder.avif.quality", nil)
+		v.Set("encoder.avif.speed", nil)
+		Update()
+	}()
+	assert.Equal(t, &AvifEncoderConfig{Quality: 100, Speed: 0}, AvifEncoder())
+}
+
+func TestJpegAndWebPEncoders(t *testing.T) {
+	assert.Equal(t, &JpegEncoderConfig{Quality: 75}, JpegEncoder())
+	assert.Equal(t, &WebPEncoderConfig{Quality: 90}, WebPEncoder())
+
+	v := Viper()
+	v.Set("encoder.jpeg.quality", 0)
+	v.Set("encoder.webp.quality", 80)
+	Update()
+	assert.Equal(t, &JpegEncoderConfig{Quality: 1}, JpegEncoder())
+	assert.Equal(t, &WebPEncoderConfig{Quality: 80}, WebPEncoder())
+
+	v.Set("encoder.jpeg.quality", 100)
+	v.Set("encoder.webp.quality", 120)
+	Update()
+	defer func() {
+		v.Set("encoder.jpeg.quality", nil)
+		v.Set("encoder.webp.quality", nil)
+		Update()
+	}()
+	assert.Equal(t, &JpegEncoderConfig{Quality: 100}, JpegEncoder())
+	assert.Equal(t, &WebPEncoderConfig{Quality: 100}, WebPEncoder())
+}
+
+func TestFaces(t *testing.T) {
+	assert.Equal(t, &FacesConfig{}, Faces())
+
+	v := Viper()
+	v.Set("faces.cascade", "/etc/darkroom/facefinder")
+	v.Set("faces.fallback", "top")
+	Update()
+	defer func() {
+		v.Set("faces.cascade", "")
+		v.Set("faces.fallback", "")
+		Update()
+	}()
+	assert.Equal(t, &FacesConfig{Cascade: "/etc/darkroom/facefinder", Fallback: "top"}, Faces())
+}
+
+func TestAnimation(t *testing.T) {
+	assert.Equal(t, &AnimationConfig{}, Animation())
+
+	v := Viper()
+	v.Set("animation.maxPixels", 1000000)
+	v.Set("animation.webpFrameQuality", 150)
+	Update()
+	defer func() {
+		v.Set("animation.maxPixels", 0)
+		v.Set("animation.webpFrameQuality", 0)
+		Update()
+	}()
+	assert.Equal(t, &AnimationConfig{MaxPixels: 1000000, WebPFrameQuality: 100}, Animation())
+}
+
+func TestResultStorage(t *testing.T) {
+	v := Viper()
+	v.Set("resultStorage.kind", "s3")
+	v.Set("resultStorage.bucket.name", "results")
+	v.Set("resultStorage.bucket.region", "region")
+	v.Set("resultStorage.hystrix.commandName", "RESULT_STORAGE")
+	v.Set("resultStorage.maxAge", "24h")
+	Update()
+	defer func() {
+		v.Set("resultStorage.kind", "")
+		v.Set("resultStorage.maxAge", "")
+		Update()
+	}()
+
+	assert.Equal(t, "s3", ResultStorage().Kind)
+	assert.Equal(t, "RESULT_STORAGE", ResultStorage().HystrixCommand.Name)
+	assert.Equal(t, S3Bucket{Name: "results", Region: "region"}, ResultStorage().Value)
+	assert.Equal(t, 24*time.Hour, ResultMaxAge())
+}
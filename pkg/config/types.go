@@ -67,27 +67,74 @@ type StatsdCollectorConfig struct {
 	FlushBytes int
 }
 
-func (s *Source) readValue() {
+// DiskCacheConfig contains the configuration of the on-disk cache for processed images
+type DiskCacheConfig struct {
+	// Path of the directory which holds the cache files
+	Path string
+	// Size is the byte budget of the cache, the cache is disabled if it is not set
+	Size int64
+}
+
+// JpegEncoderConfig contains the configuration of the JPEG encoder
+type JpegEncoderConfig struct {
+	// Quality ranges from 1 to 100, opaque PNG images are encoded as JPEG unless it is 100
+	Quality int
+}
+
+// WebPEncoderConfig contains the configuration of the WebP encoder
+type WebPEncoderConfig struct {
+	// Quality ranges from 1 to 100
+	Quality int
+}
+
+// AvifEncoderConfig contains the configuration of the AVIF encoder
+type AvifEncoderConfig struct {
+	// Quality ranges from 0 to 100, 100 is lossless
+	Quality int
+	// Speed ranges from 0 to 10, 0 is the slowest and gives the smallest images
+	Speed int
+}
+
+// FacesConfig contains the configuration of the face detection of the crops with crop=faces
+type FacesConfig struct {
+	// Cascade is the path of the face detection cascade in the pico binary format, the facefinder cascade
+	// of pigo which is bundled with darkroom is used if it is not set
+	Cascade string
+	// Fallback is the crop param value, eg: top, used for the images in which no face is detected
+	Fallback string
+}
+
+// AnimationConfig contains the configuration of the animated images
+type AnimationConfig struct {
+	// MaxPixels is the limit of the number of frames times the number of pixels of an animation, before and after
+	// it is resized, the default limit of the processor is used if it is not set
+	MaxPixels int64
+	// WebPFrameQuality is the quality of every frame of an animated WebP image from 1 to 100,
+	// the quality of the still WebP images is used if it is not set
+	WebPFrameQuality int
+}
+
+func (s *Source) readValue(key string) {
 	v := Viper()
 	if regex.S3Matcher.MatchString(s.Kind) {
 		s.Value = S3Bucket{
-			Name:      v.GetString("source.bucket.name"),
-			Region:    v.GetString("source.bucket.region"),
-			AccessKey: v.GetString("source.bucket.accessKey"),
-			SecretKey: v.GetString("source.bucket.secretKey"),
-			Endpoint:  v.GetString("source.bucket.endpoint"),
+			Name:      v.GetString(key + ".bucket.name"),
+			Region:    v.GetString(key + ".bucket.region"),
+			AccessKey: v.GetString(key + ".bucket.accessKey"),
+			SecretKey: v.GetString(key + ".bucket.secretKey"),
+			Endpoint:  v.GetString(key + ".bucket.endpoint"),
 		}
 	} else if regex.CloudfrontMatcher.MatchString(s.Kind) {
 		s.Value = Cloudfront{
-			Host:           v.GetString("source.host"),
-			SecureProtocol: v.GetBool("source.secureProtocol"),
+			Host:           v.GetString(key + ".host"),
+			SecureProtocol: v.GetBool(key + ".secureProtocol"),
 		}
 	} else if regex.WebFolderMatcher.MatchString(s.Kind) {
-		s.Value = WebFolder{BaseURL: v.GetString("source.baseURL")}
+		s.Value = WebFolder{BaseURL: v.GetString(key + ".baseURL")}
 	} else if regex.GoogleCloudStorageMatcher.MatchString(s.Kind) {
 		s.Value = GoogleCloudStorage{
-			Name:            v.GetString("source.bucket.name"),
-			CredentialsJSON: v.GetString("source.bucket.credentialsJson"),
+			Name:            v.GetString(key + ".bucket.name"),
+			CredentialsJSON: v.GetString(key + ".bucket.credentialsJson"),
 		}
 	}
 }
@@ -8,4 +8,8 @@ type MetricService interface {
 	TrackDuration(imageProcess string, start time.Time, ImageData []byte)
 
 	CountImageHandlerErrors(kind string)
+
+	CountCoalescedRequests(stage string)
+
+	CountCacheEvents(cache string, event string)
 }
@@ -17,3 +17,11 @@ func (m *MockMetricService) TrackDuration(imageProcess string, start time.Time,
 func (m *MockMetricService) CountImageHandlerErrors(kind string) {
 	m.Called(kind)
 }
+
+func (m *MockMetricService) CountCoalescedRequests(stage string) {
+	m.Called(stage)
+}
+
+func (m *MockMetricService) CountCacheEvents(cache string, event string) {
+	m.Called(cache, event)
+}
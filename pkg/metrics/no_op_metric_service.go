@@ -11,3 +11,9 @@ func (NoOpMetricService) TrackDuration(string, time.Time, []byte) {
 
 func (NoOpMetricService) CountImageHandlerErrors(string) {
 }
+
+func (NoOpMetricService) CountCoalescedRequests(string) {
+}
+
+func (NoOpMetricService) CountCacheEvents(string, string) {
+}
@@ -8,5 +8,7 @@ import (
 func TestNoOpMetricService(t *testing.T) {
 	ms := NoOpMetricService{}
 	ms.CountImageHandlerErrors("handler_error")
+	ms.CountCoalescedRequests("process")
+	ms.CountCacheEvents("memory", "hit")
 	ms.TrackDuration("error", time.Now(), []byte(nil))
 }
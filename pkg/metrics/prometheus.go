@@ -12,6 +12,8 @@ import (
 type prometheusService struct {
 	imageProcessDuration     *prometheus.HistogramVec
 	imageHandlerErrorCounter *prometheus.CounterVec
+	coalescedRequestCounter  *prometheus.CounterVec
+	cacheEventCounter        *prometheus.CounterVec
 	reg                      *prometheus.Registry
 }
 
@@ -27,6 +29,16 @@ func NewPrometheus(reg *prometheus.Registry) MetricService {
 				Name: "image_handler_errors",
 				Help: "The total number of errors for each storage and processor",
 			}, []string{"error_type"}),
+		coalescedRequestCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "coalesced_requests",
+				Help: "The total number of requests which shared the result of an identical concurrent request",
+			}, []string{"stage"}),
+		cacheEventCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_events",
+				Help: "The total number of hits, misses and evictions for each cache",
+			}, []string{"cache", "event"}),
 
 		reg: reg,
 	}
@@ -39,6 +51,8 @@ func (p prometheusService) registerMetrics() {
 	p.reg.MustRegister(
 		p.imageProcessDuration,
 		p.imageHandlerErrorCounter,
+		p.coalescedRequestCounter,
+		p.cacheEventCounter,
 	)
 }
 
@@ -51,6 +65,14 @@ func (p prometheusService) CountImageHandlerErrors(kind string) {
 	p.imageHandlerErrorCounter.WithLabelValues(kind).Inc()
 }
 
+func (p prometheusService) CountCoalescedRequests(stage string) {
+	p.coalescedRequestCounter.WithLabelValues(stage).Inc()
+}
+
+func (p prometheusService) CountCacheEvents(cache string, event string) {
+	p.cacheEventCounter.WithLabelValues(cache, event).Inc()
+}
+
 func (p prometheusService) getImageType(ImageData []byte) string {
 	ext := strings.Split(http.DetectContentType(ImageData), "/")[1]
 	labelValue := fmt.Sprintf("%s.%s", GetImageSizeCluster(ImageData), ext)
@@ -57,6 +57,33 @@ func TestPrometheusMetrics(t *testing.T) {
 			},
 			expCode: 200,
 		},
+		{
+			name: "Counting coalesced requests should expose metrics on prometheus endpoint.",
+			addMetrics: func(s MetricService) {
+				s.CountCoalescedRequests("fetch")
+				s.CountCoalescedRequests("process")
+				s.CountCoalescedRequests("process")
+			},
+			expMetrics: []string{
+				`coalesced_requests{stage="fetch"} 1`,
+				`coalesced_requests{stage="process"} 2`,
+			},
+			expCode: 200,
+		},
+		{
+			name: "Counting cache events should expose metrics on prometheus endpoint.",
+			addMetrics: func(s MetricService) {
+				s.CountCacheEvents("memory", "hit")
+				s.CountCacheEvents("memory", "miss")
+				s.CountCacheEvents("memory", "eviction")
+			},
+			expMetrics: []string{
+				`cache_events{cache="memory",event="hit"} 1`,
+				`cache_events{cache="memory",event="miss"} 1`,
+				`cache_events{cache="memory",event="eviction"} 1`,
+			},
+			expCode: 200,
+		},
 	}
 
 	for _, test := range tests {
@@ -78,6 +78,20 @@ func (s statsdClient) CountImageHandlerErrors(kind string) {
 	}
 }
 
+func (s statsdClient) CountCoalescedRequests(stage string) {
+	err := s.client.Inc(fmt.Sprintf("coalesced_requests.%s", stage), 1, s.sampleRate)
+	if err != nil {
+		logger.Errorf("MetricService.CountCoalescedRequests got an error: %s", err)
+	}
+}
+
+func (s statsdClient) CountCacheEvents(cache string, event string) {
+	err := s.client.Inc(fmt.Sprintf("cache.%s.%s", cache, event), 1, s.sampleRate)
+	if err != nil {
+		logger.Errorf("MetricService.CountCacheEvents got an error: %s", err)
+	}
+}
+
 func (s statsdClient) getMetricTag(imageProcess string, ImageData []byte) string {
 	ext := strings.Split(http.DetectContentType(ImageData), "/")[1]
 	tag := fmt.Sprintf("%s.%s.%s", imageProcess, GetImageSizeCluster(ImageData), ext)
@@ -56,6 +56,8 @@ func TestStatsDMetricsUpdate(t *testing.T) {
 		mock.AnythingOfType("int64"),
 		mock.AnythingOfType("float32")).Return(nil)
 	instance.CountImageHandlerErrors("")
+	instance.CountCoalescedRequests("process")
+	instance.CountCacheEvents("memory", "hit")
 
 	mc.AssertExpectations(t)
 }
@@ -0,0 +1,32 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+)
+
+// Animation is an image made of several frames, eg: a decoded animated GIF. It behaves like its first frame
+// for the callers which don't know about animations, so that it can be passed around as an image.Image.
+type Animation struct {
+	// Frames are the fully composed frames of the animation, they all have the same bounds
+	Frames []image.Image
+	// Delays are the display times of the frames in 100ths of a second
+	Delays []int
+	// LoopCount controls the number of times the animation is played, 0 loops forever and -1 plays it once
+	LoopCount int
+}
+
+// ColorModel returns the color model of the first frame
+func (a *Animation) ColorModel() color.Model {
+	return a.Frames[0].ColorModel()
+}
+
+// Bounds returns the bounds of the first frame
+func (a *Animation) Bounds() image.Rectangle {
+	return a.Frames[0].Bounds()
+}
+
+// At returns the color of the pixel at (x, y) of the first frame
+func (a *Animation) At(x, y int) color.Color {
+	return a.Frames[0].At(x, y)
+}
@@ -22,9 +22,23 @@ const (
 	PointBottom Point = 8
 	// PointBottomRight crops an image with focus point at bottom-right
 	PointBottomRight Point = 9
+	// PointEntropy crops an image with focus on the region with the most detail, measured by the entropy
+	// of its luminance
+	PointEntropy Point = 10
+	// PointAttention crops an image with focus on the region which draws the most attention, measured by
+	// its density of edges, saturated colors and skin tones
+	PointAttention Point = 11
+	// PointFaces crops an image with focus on the union of its faces, or on a fallback Point if it has no face
+	PointFaces Point = 12
 
 	ExtensionWebP = "webp"
 	ExtensionPNG  = "png"
 	ExtensionJPG  = "jpg"
 	ExtensionJPEG = "jpeg"
+	ExtensionAVIF = "avif"
+	ExtensionGIF  = "gif"
+	ExtensionTIFF = "tiff"
+	ExtensionBMP  = "bmp"
+	ExtensionICO  = "ico"
+	ExtensionSVG  = "svg"
 )
@@ -1,8 +1,59 @@
 package processor
 
+import "image/color"
+
+// OverlayAttrs describes an image which is drawn on top of the base image
 type OverlayAttrs struct {
-	Img              []byte
-	Point            Point
+	// Img is the encoded overlay image
+	Img []byte
+	// Point is the side or the corner of the base image the overlay is anchored to
+	Point Point
+	// WidthPercentage and HeightPercentage bound the size of the overlay as a percentage of the size of the
+	// base image, the aspect ratio of the overlay is maintained. The overlay keeps its own size if both are 0.
 	WidthPercentage  float64
 	HeightPercentage float64
+	// Opacity of the overlay used by Overlay, 0 is treated the same as 255 so that the overlay is opaque by default
+	Opacity uint8
+	// OffsetX and OffsetY move the overlay from its anchor point by the given pixels
+	OffsetX int
+	OffsetY int
+}
+
+// TextAttrs describes a line of text which is drawn on top of the base image
+type TextAttrs struct {
+	// Text is the UTF-8 text to be drawn
+	Text string
+	// Size is the size of the font in pixels
+	Size float64
+	// Color of the text, it is black if not set
+	Color color.Color
+	// Opacity of the text, 0 is treated the same as 255 so that the text is opaque by default
+	Opacity uint8
+	// Point is the side or the corner of the base image the text is anchored to, it is ignored if Tile is set
+	Point Point
+	// Padding is the space between the text and the edges of the base image in pixels,
+	// or the space between the repetitions of the text if Tile is set
+	Padding int
+	// Tile repeats the text across the whole base image
+	Tile bool
+}
+
+// FocalPoint describes the point of interest of an image which the crop is centered on
+type FocalPoint struct {
+	// X and Y are the coordinates of the point normalized from 0 to 1, from the left and the top of the image
+	X float64
+	Y float64
+	// Z is the zoom of the crop, 1 crops the largest region of the image with the requested aspect ratio
+	// and 2 crops a region of half its width and height around the point
+	Z float64
+}
+
+// FillAttrs describes the background which pads an image to the requested size
+type FillAttrs struct {
+	// Color of the background, it is transparent if not set and ignored if Blur is set
+	Color color.Color
+	// Blur fills the background with a blurred copy of the image which covers it
+	Blur bool
+	// Point is the side or the corner of the background the image is aligned to, it is centered by default
+	Point Point
 }
@@ -0,0 +1,16 @@
+package processor
+
+import "fmt"
+
+// UnsupportedFormatError is returned by a Processor when it can't decode an image because of its format
+type UnsupportedFormatError struct {
+	// Format is the detected format (extension) or media type of the image, it is empty if it is not recognised
+	Format string
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	if e.Format == "" {
+		return "unsupported image format"
+	}
+	return fmt.Sprintf("unsupported image format: %s", e.Format)
+}
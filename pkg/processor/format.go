@@ -0,0 +1,48 @@
+package processor
+
+import "bytes"
+
+// DetectFormat takes an encoded image and returns its format (extension) by looking at the magic bytes,
+// an empty string is returned if the format is not recognised
+func DetectFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return ExtensionJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return ExtensionPNG
+	case bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a")):
+		return ExtensionGIF
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return ExtensionWebP
+	case len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) &&
+		(bytes.Equal(data[8:12], []byte("avif")) || bytes.Equal(data[8:12], []byte("avis"))):
+		return ExtensionAVIF
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		return ExtensionTIFF
+	case bytes.HasPrefix(data, []byte("BM")):
+		return ExtensionBMP
+	case bytes.HasPrefix(data, []byte("\x00\x00\x01\x00")):
+		return ExtensionICO
+	case isSVG(data):
+		return ExtensionSVG
+	default:
+		return ""
+	}
+}
+
+// svgSniffLen is the number of bytes in which the svg root element of an svg image is looked for
+const svgSniffLen = 4096
+
+// isSVG returns true if the data is an xml document, starting with the svg root element or with a prolog,
+// comments or a doctype followed by the svg root element in the first svgSniffLen bytes
+func isSVG(data []byte) bool {
+	data = bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(data) > svgSniffLen {
+		data = data[:svgSniffLen]
+	}
+	if bytes.HasPrefix(data, []byte("<svg")) {
+		return true
+	}
+	return (bytes.HasPrefix(data, []byte("<?xml")) || bytes.HasPrefix(data, []byte("<!"))) &&
+		bytes.Contains(data, []byte("<svg"))
+}
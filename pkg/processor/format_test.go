@@ -0,0 +1,39 @@
+package processor
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormat(t *testing.T) {
+	cases := []struct {
+		file     string
+		expected string
+	}{
+		{file: "native/_testdata/test.jpg", expected: ExtensionJPEG},
+		{file: "native/_testdata/test.png", expected: ExtensionPNG},
+		{file: "native/_testdata/test.webp", expected: ExtensionWebP},
+	}
+	for _, c := range cases {
+		data, err := ioutil.ReadFile(c.file)
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, DetectFormat(data))
+	}
+	assert.Equal(t, ExtensionGIF, DetectFormat([]byte("GIF89a\x01\x00\x01\x00")))
+	assert.Equal(t, ExtensionGIF, DetectFormat([]byte("GIF87a\x01\x00\x01\x00")))
+	assert.Equal(t, ExtensionAVIF, DetectFormat([]byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00")))
+	assert.Equal(t, ExtensionTIFF, DetectFormat([]byte("II*\x00\x08\x00\x00\x00")))
+	assert.Equal(t, ExtensionTIFF, DetectFormat([]byte("MM\x00*\x00\x00\x00\x08")))
+	assert.Equal(t, ExtensionBMP, DetectFormat([]byte("BM\x46\x00\x00\x00")))
+	assert.Equal(t, ExtensionICO, DetectFormat([]byte("\x00\x00\x01\x00\x01\x00")))
+	assert.Equal(t, "", DetectFormat([]byte("\x00\x00\x00\x1cftypheic\x00\x00\x00\x00")))
+	assert.Equal(t, ExtensionSVG, DetectFormat([]byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`)))
+	assert.Equal(t, ExtensionSVG, DetectFormat([]byte("\xef\xbb\xbf\n<?xml version=\"1.0\"?>\n<!-- comment -->\n<svg></svg>")))
+	assert.Equal(t, ExtensionSVG, DetectFormat([]byte(`<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "svg11.dtd"><svg/>`)))
+	assert.Equal(t, "", DetectFormat([]byte(`<?xml version="1.0"?><note></note>`)))
+	assert.Equal(t, "", DetectFormat([]byte(`<html><body><svg></svg></body></html>`)))
+	assert.Equal(t, "", DetectFormat([]byte("badImage.ext")))
+	assert.Equal(t, "", DetectFormat(nil))
+}
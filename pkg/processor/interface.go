@@ -6,19 +6,29 @@ import "image"
 type Processor interface {
 	// Crop takes an image.Image, width, height and a Point and returns the cropped image
 	Crop(image image.Image, width, height int, point Point) image.Image
+	// CropFocalPoint takes an image.Image, width, height and a FocalPoint and returns the cropped image, the crop
+	// is centered on the focal point as far as the bounds of the image allow
+	CropFocalPoint(image image.Image, width, height int, fp FocalPoint) image.Image
+	// Extract takes an image.Image and a rectangle relative to its top left corner, which is inside its bounds,
+	// and returns the region of the image in the rectangle
+	Extract(image image.Image, rect image.Rectangle) image.Image
 	// Resize takes an image.Image, width and height and returns the re-sized image
 	Resize(image image.Image, width, height int) image.Image
 	// Scale takes an input image, width and height and returns the re-sized
 	// image without maintaining the original aspect ratio
 	Scale(image image.Image, width, height int) image.Image
+	// Fill takes an image.Image, width, height and FillAttrs and returns the image resized to fit inside width and
+	// height, maintaining its aspect ratio, on top of a background of exactly width x height. It is the same as
+	// Resize if only one of width and height is set, and the image is returned as is if neither is set.
+	Fill(image image.Image, width, height int, fill *FillAttrs) image.Image
 	// GrayScale takes an input byte array and returns the grayscaled byte array or error
 	GrayScale(image image.Image) image.Image
 	// Blur takes an input byte array and returns the blurred byte array by the specified
 	// radius(<=1000) or error radius must be larger than 0
 	Blur(image image.Image, radius float64) image.Image
-	// Watermark takes an input byte array, overlay byte array and opacity value
-	// and returns the watermarked image bytes or error
-	Watermark(base []byte, overlay []byte, opacity uint8) ([]byte, error)
+	// Watermark takes an input image, the watermark as OverlayAttrs and opacity value
+	// and returns the watermarked image or error
+	Watermark(img image.Image, overlay *OverlayAttrs, opacity uint8) (image.Image, error)
 	// Flip takes an input image and returns the image flipped. The direction of flip
 	// is determined by the specified mode - 'v' for a vertical flip, 'h' for a horizontal flip and
 	// 'vh'(or 'hv') for both.
@@ -28,12 +38,26 @@ type Processor interface {
 	Rotate(image image.Image, angle float64) image.Image
 	// Decode takes a byte array and returns the image, extension, and error
 	Decode(data []byte) (img image.Image, format string, err error)
+	// Rasterize takes a vector image, width and height and returns the bitmap image, its format and error.
+	// The image keeps its aspect ratio and covers the width and height, a width or height of 0 is computed
+	// from the aspect ratio and the image gets its own size if both are 0.
+	Rasterize(data []byte, width, height int) (img image.Image, format string, err error)
 	// Encode takes an image and extension and return the encoded byte array or error
 	Encode(img image.Image, format string) ([]byte, error)
+	// EncodeWithQuality takes an image, extension and quality from 1 to 100 and returns the encoded byte array
+	// or error, the default quality of the format is used if quality is 0
+	EncodeWithQuality(img image.Image, format string, quality int) ([]byte, error)
+	// CanEncode returns true if the Processor can encode images in the format
+	CanEncode(format string) bool
 	// FixOrientation takes an image and it's EXIF orientation (if exist)
 	// and returns the image with its EXIF orientation fixed
 	FixOrientation(img image.Image, orientation int) image.Image
-	// Overlay takes an input byte array as the base image and
-	// an array of OverlayAttrs to be placed as overlays to the base image
-	Overlay(base []byte, overlays []*OverlayAttrs) ([]byte, error)
+	// Overlay takes an input image as the base image and an array of OverlayAttrs to be
+	// placed as overlays to the base image in their order, and returns the resulting image or error
+	Overlay(img image.Image, overlays []*OverlayAttrs) (image.Image, error)
+	// Text takes an input image and TextAttrs and returns the image with the text drawn on top of it or error
+	Text(img image.Image, text *TextAttrs) (image.Image, error)
+	// MarkFaces takes an input image and returns the image with the boxes of its detected faces drawn on top of it,
+	// to tune the crops with PointFaces
+	MarkFaces(img image.Image) image.Image
 }
@@ -0,0 +1,167 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+
+	"github.com/anthonynsimon/bild/clone"
+	"github.com/gojek/darkroom/pkg/processor"
+)
+
+// DefaultMaxAnimationPixels is the limit of the number of frames times the number of pixels of an animation
+// used by BildProcessor if it is created without WithMaxAnimationPixels, the composed frames of such an
+// animation take up to 200 MB
+const DefaultMaxAnimationPixels = 50000000
+
+// ErrAnimationTooLarge is returned when the number of frames times the number of pixels of an animation
+// exceeds the limit of the BildProcessor
+var ErrAnimationTooLarge = errors.New("animation is too large")
+
+// decodeGIF decodes all the frames of a GIF image, a GIF with a single frame is returned as an image.Paletted
+// while the frames of an animated GIF are composed into a processor.Animation if they fit in maxPixels.
+// The frames are counted by scanGIF before they are decoded, so that the ones which don't fit are never allocated.
+func decodeGIF(data []byte, maxPixels int64) (image.Image, error) {
+	if frames, pixels := scanGIF(data, maxPixels); frames > 1 && pixels > maxPixels {
+		return nil, fmt.Errorf("%w: the first %d frames have %d pixels which exceed the limit of %d pixels",
+			ErrAnimationTooLarge, frames, pixels, maxPixels)
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 1 {
+		return g.Image[0], nil
+	}
+	return composeGIF(g), nil
+}
+
+const (
+	gifHeaderLen          = 6
+	gifScreenDescLen      = 7
+	gifImageDescLen       = 10
+	gifColorTableFlag     = 0x80
+	gifExtensionIntro     = 0x21
+	gifImageSeparator     = 0x2C
+	gifColorTableSizeMask = 0x07
+)
+
+// scanGIF walks the blocks of a GIF image without decompressing them and returns the number of frames and the
+// number of pixels they take once composed, where every frame takes the size of the canvas or its own size if it
+// is larger. It stops once at least 2 frames are found and their pixels exceed maxPixels. The malformed images are
+// scanned as far as possible and left to gif.DecodeAll to report.
+func scanGIF(data []byte, maxPixels int64) (frames int, pixels int64) {
+	if len(data) < gifHeaderLen+gifScreenDescLen {
+		return 0, 0
+	}
+	canvas := int64(binary.LittleEndian.Uint16(data[6:])) * int64(binary.LittleEndian.Uint16(data[8:]))
+	i := gifHeaderLen + gifScreenDescLen + gifColorTableLen(data[10])
+	for i < len(data) && (pixels <= maxPixels || frames < 2) {
+		switch data[i] {
+		case gifExtensionIntro:
+			i = skipGIFSubBlocks(data, i+2)
+		case gifImageSeparator:
+			if i+gifImageDescLen > len(data) {
+				return frames, pixels
+			}
+			area := int64(binary.LittleEndian.Uint16(data[i+5:])) * int64(binary.LittleEndian.Uint16(data[i+7:]))
+			if area < canvas {
+				area = canvas
+			}
+			frames++
+			pixels += area
+			// the image data follows the local color table and starts with the LZW minimum code size
+			i = skipGIFSubBlocks(data, i+gifImageDescLen+gifColorTableLen(data[i+9])+1)
+		default:
+			// the trailer or a malformed block
+			return frames, pixels
+		}
+	}
+	return frames, pixels
+}
+
+// gifColorTableLen returns the length of the color table declared by the packed fields of a screen or an image
+// descriptor
+func gifColorTableLen(fields byte) int {
+	if fields&gifColorTableFlag == 0 {
+		return 0
+	}
+	return 3 << (fields&gifColorTableSizeMask + 1)
+}
+
+// skipGIFSubBlocks returns the index after the sub-blocks starting at i, which end with an empty sub-block
+func skipGIFSubBlocks(data []byte, i int) int {
+	for i < len(data) {
+		n := int(data[i])
+		i++
+		if n == 0 {
+			return i
+		}
+		i += n
+	}
+	return i
+}
+
+// composeGIF draws the frames of the GIF, which may only cover a part of the canvas, on top of each other
+// following their disposal methods so that every frame of the returned animation is complete on its own
+func composeGIF(g *gif.GIF) *processor.Animation {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		for _, frame := range g.Image {
+			bounds = bounds.Union(frame.Bounds())
+		}
+	}
+	a := &processor.Animation{
+		Frames:    make([]image.Image, len(g.Image)),
+		Delays:    make([]int, len(g.Image)),
+		LoopCount: g.LoopCount,
+	}
+	canvas := image.NewRGBA(bounds)
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = clone.AsRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		a.Frames[i] = clone.AsRGBA(canvas)
+		if i < len(g.Delay) {
+			a.Delays[i] = g.Delay[i]
+		}
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return a
+}
+
+// mapFrames returns a new animation with fn applied to every frame of the animation a
+func mapFrames(a *processor.Animation, fn func(frame image.Image) image.Image) *processor.Animation {
+	frames := make([]image.Image, len(a.Frames))
+	for i, frame := range a.Frames {
+		frames[i] = fn(frame)
+	}
+	return &processor.Animation{Frames: frames, Delays: a.Delays, LoopCount: a.LoopCount}
+}
+
+// drawOnFrames returns a copy of the image with fn applied to it, fn is applied to every frame of an animation
+func drawOnFrames(img image.Image, fn func(dst *image.RGBA)) image.Image {
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			return drawOnFrames(frame, fn)
+		})
+	}
+	dst := clone.AsRGBA(img)
+	fn(dst)
+	return dst
+}
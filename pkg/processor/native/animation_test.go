@@ -0,0 +1,231 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"runtime"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	red   = color.RGBA{R: 255, A: 255}
+	green = color.RGBA{G: 255, A: 255}
+	blue  = color.RGBA{B: 255, A: 255}
+)
+
+// newAnimatedGIF returns a 20x10 GIF with a red frame, a green frame covering the left half which is disposed
+// to the background and a blue frame covering the right half
+func newAnimatedGIF(t *testing.T) []byte {
+	pal := color.Palette{color.Transparent, red, green, blue}
+	frame := func(r image.Rectangle, c int) *image.Paletted {
+		p := image.NewPaletted(r, pal)
+		draw.Draw(p, r, image.NewUniform(pal[c]), image.Point{}, draw.Src)
+		return p
+	}
+	g := &gif.GIF{
+		Image:     []*image.Paletted{frame(image.Rect(0, 0, 20, 10), 1), frame(image.Rect(0, 0, 10, 10), 2), frame(image.Rect(10, 0, 20, 10), 3)},
+		Delay:     []int{10, 20, 30},
+		Disposal:  []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+		LoopCount: 2,
+		Config:    image.Config{ColorModel: pal, Width: 20, Height: 10},
+	}
+	buff := &bytes.Buffer{}
+	if err := gif.EncodeAll(buff, g); err != nil {
+		t.Fatal(err)
+	}
+	return buff.Bytes()
+}
+
+func rgbaAt(img image.Image, x, y int) color.RGBA {
+	b := img.Bounds()
+	return color.RGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+}
+
+func TestDecodeGIF(t *testing.T) {
+	img, err := decodeGIF(newAnimatedGIF(t), DefaultMaxAnimationPixels)
+	assert.NoError(t, err)
+	a, ok := img.(*processor.Animation)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Len(t, a.Frames, 3)
+	assert.Equal(t, []int{10, 20, 30}, a.Delays)
+	assert.Equal(t, 2, a.LoopCount)
+	assert.Equal(t, image.Rect(0, 0, 20, 10), a.Bounds())
+
+	assert.Equal(t, red, rgbaAt(a.Frames[0], 5, 5))
+	assert.Equal(t, red, rgbaAt(a.Frames[0], 15, 5))
+	assert.Equal(t, green, rgbaAt(a.Frames[1], 5, 5))
+	assert.Equal(t, red, rgbaAt(a.Frames[1], 15, 5))
+	assert.Equal(t, color.RGBA{}, rgbaAt(a.Frames[2], 5, 5))
+	assert.Equal(t, blue, rgbaAt(a.Frames[2], 15, 5))
+
+	_, err = decodeGIF([]byte("GIF89a"), DefaultMaxAnimationPixels)
+	assert.Error(t, err)
+}
+
+func TestBildProcessor_Decode_WithTooLargeAnimation(t *testing.T) {
+	_, _, err := NewBildProcessor(WithMaxAnimationPixels(599)).Decode(newAnimatedGIF(t))
+	assert.ErrorIs(t, err, ErrAnimationTooLarge)
+
+	_, _, err = NewBildProcessor(WithMaxAnimationPixels(600)).Decode(newAnimatedGIF(t))
+	assert.NoError(t, err)
+}
+
+// newGIFBomb returns a small GIF which declares the given number of frames of width x height pixels without
+// their image data
+func newGIFBomb(frames int, width, height uint16) []byte {
+	buff := &bytes.Buffer{}
+	buff.WriteString("GIF89a")
+	_ = binary.Write(buff, binary.LittleEndian, []uint16{width, height})
+	buff.Write([]byte{0, 0, 0})
+	for i := 0; i < frames; i++ {
+		buff.WriteByte(0x2C)
+		_ = binary.Write(buff, binary.LittleEndian, []uint16{0, 0, width, height})
+		buff.Write([]byte{0, 2, 1, 0x44, 0})
+	}
+	buff.WriteByte(0x3B)
+	return buff.Bytes()
+}
+
+func TestDecodeGIF_WithTooManyFrames(t *testing.T) {
+	data := newGIFBomb(100, 2000, 2000)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	_, err := decodeGIF(data, DefaultMaxAnimationPixels)
+
+	runtime.ReadMemStats(&after)
+	assert.ErrorIs(t, err, ErrAnimationTooLarge)
+	// a single frame takes 4 MB once decoded
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+}
+
+func TestScanGIF(t *testing.T) {
+	frames, pixels := scanGIF(newAnimatedGIF(t), DefaultMaxAnimationPixels)
+	assert.Equal(t, 3, frames)
+	assert.Equal(t, int64(600), pixels)
+
+	frames, pixels = scanGIF(newGIFBomb(100, 2000, 2000), DefaultMaxAnimationPixels)
+	assert.Equal(t, 13, frames)
+	assert.Equal(t, int64(52000000), pixels)
+
+	frames, _ = scanGIF(newGIFBomb(2, 10000, 10000), DefaultMaxAnimationPixels)
+	assert.Equal(t, 2, frames)
+
+	frames, _ = scanGIF([]byte("GIF89a"), DefaultMaxAnimationPixels)
+	assert.Equal(t, 0, frames)
+	frames, _ = scanGIF(newGIFBomb(3, 10, 10)[:30], DefaultMaxAnimationPixels)
+	assert.Equal(t, 1, frames)
+}
+
+func TestDecodeGIF_WithSingleFrame(t *testing.T) {
+	buff := &bytes.Buffer{}
+	_ = gif.Encode(buff, image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{red}), nil)
+
+	img, err := decodeGIF(buff.Bytes(), DefaultMaxAnimationPixels)
+
+	assert.NoError(t, err)
+	assert.IsType(t, &image.Paletted{}, img)
+}
+
+func TestBildProcessor_WithAnimation(t *testing.T) {
+	bp := NewBildProcessor()
+	img, f, err := bp.Decode(newAnimatedGIF(t))
+	assert.NoError(t, err)
+	assert.Equal(t, processor.ExtensionGIF, f)
+
+	cases := []struct {
+		name     string
+		fn       func(img image.Image) image.Image
+		bounds   image.Rectangle
+		expected []color.RGBA
+	}{
+		{name: "Resize", fn: func(img image.Image) image.Image { return bp.Resize(img, 10, 0) },
+			bounds: image.Rect(0, 0, 10, 5), expected: []color.RGBA{red, green, {}}},
+		{name: "Crop", fn: func(img image.Image) image.Image { return bp.Crop(img, 10, 10, processor.PointLeft) },
+			bounds: image.Rect(0, 0, 10, 10), expected: []color.RGBA{red, green, {}}},
+		{name: "Flip", fn: func(img image.Image) image.Image { return bp.Flip(img, "h") },
+			bounds: image.Rect(0, 0, 20, 10), expected: []color.RGBA{red, red, blue}},
+		{name: "Rotate", fn: func(img image.Image) image.Image { return bp.Rotate(img, 180) },
+			bounds: image.Rect(0, 0, 20, 10), expected: []color.RGBA{red, red, blue}},
+		{name: "GrayScale", fn: bp.GrayScale,
+			bounds: image.Rect(0, 0, 20, 10), expected: []color.RGBA{{76, 76, 76, 255}, {150, 150, 150, 255}, {}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out, ok := c.fn(img).(*processor.Animation)
+			if !assert.True(t, ok) {
+				return
+			}
+			assert.Equal(t, []int{10, 20, 30}, out.Delays)
+			assert.Equal(t, 2, out.LoopCount)
+			for i, frame := range out.Frames {
+				assert.Equal(t, c.bounds.Size(), frame.Bounds().Size())
+				actual := rgbaAt(frame, 1, 1)
+				for _, d := range []int{
+					int(actual.R) - int(c.expected[i].R), int(actual.G) - int(c.expected[i].G),
+					int(actual.B) - int(c.expected[i].B), int(actual.A) - int(c.expected[i].A),
+				} {
+					assert.InDelta(t, 0, d, 1, "frame %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestBildProcessor_Watermark_WithAnimation(t *testing.T) {
+	bp := NewBildProcessor()
+	img, _, _ := bp.Decode(newAnimatedGIF(t))
+	mark := &bytes.Buffer{}
+	_ = gif.Encode(mark, image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.White}), nil)
+
+	out, err := bp.Watermark(img, &processor.OverlayAttrs{Img: mark.Bytes(), Point: processor.PointTopLeft}, 255)
+
+	assert.NoError(t, err)
+	a, ok := out.(*processor.Animation)
+	if assert.True(t, ok) {
+		for _, frame := range a.Frames {
+			assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, rgbaAt(frame, 0, 0))
+		}
+	}
+}
+
+func TestGifEncoder_Encode_WithAnimation(t *testing.T) {
+	bp := NewBildProcessor()
+	img, _, _ := bp.Decode(newAnimatedGIF(t))
+	img = bp.Crop(img, 10, 10, processor.PointRight)
+
+	data, err := (&GifEncoder{}).Encode(img)
+
+	assert.NoError(t, err)
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Len(t, g.Image, 3)
+	assert.Equal(t, []int{10, 20, 30}, g.Delay)
+	assert.Equal(t, 2, g.LoopCount)
+	assert.Equal(t, image.Rect(0, 0, 10, 10), g.Image[0].Bounds())
+	assert.Equal(t, red, rgbaAt(g.Image[1], 5, 5))
+	assert.Equal(t, blue, rgbaAt(g.Image[2], 5, 5))
+}
+
+func TestGifEncoder_Encode_WithTransparency(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, red)
+
+	data, err := (&GifEncoder{}).Encode(img)
+
+	assert.NoError(t, err)
+	out, err := gif.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, red, rgbaAt(out, 1, 1))
+	assert.Equal(t, color.RGBA{}, rgbaAt(out, 0, 0))
+}
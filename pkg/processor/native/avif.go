@@ -0,0 +1,79 @@
+//go:build avif && cgo
+
+package native
+
+/*
+#cgo pkg-config: libavif
+#include <avif/avif.h>
+
+static avifResult encodeAVIF(uint8_t *pixels, uint32_t width, uint32_t height, uint32_t rowBytes,
+                             int quality, int speed, avifRWData *output) {
+	avifImage *image = avifImageCreate(width, height, 8, AVIF_PIXEL_FORMAT_YUV420);
+	if (image == NULL) {
+		return AVIF_RESULT_UNKNOWN_ERROR;
+	}
+	avifRGBImage rgb;
+	avifRGBImageSetDefaults(&rgb, image);
+	rgb.format = AVIF_RGB_FORMAT_RGBA;
+	rgb.depth = 8;
+	rgb.pixels = pixels;
+	rgb.rowBytes = rowBytes;
+	avifResult res = avifImageRGBToYUV(image, &rgb);
+	if (res != AVIF_RESULT_OK) {
+		avifImageDestroy(image);
+		return res;
+	}
+
+	avifEncoder *encoder = avifEncoderCreate();
+	if (encoder == NULL) {
+		avifImageDestroy(image);
+		return AVIF_RESULT_UNKNOWN_ERROR;
+	}
+	encoder->speed = speed;
+#if AVIF_VERSION >= 1000000
+	encoder->quality = quality;
+	encoder->qualityAlpha = quality;
+#else
+	// Versions older than 1.0.0 only take the quantizers, 0 is lossless and 63 is the worst quality
+	int quantizer = ((100 - quality) * AVIF_QUANTIZER_WORST_QUALITY + 50) / 100;
+	encoder->minQuantizer = quantizer;
+	encoder->maxQuantizer = quantizer;
+	encoder->minQuantizerAlpha = quantizer;
+	encoder->maxQuantizerAlpha = quantizer;
+#endif
+	res = avifEncoderWrite(encoder, image, output);
+	avifEncoderDestroy(encoder);
+	avifImageDestroy(image);
+	return res;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"unsafe"
+)
+
+// AVIFSupported tells whether darkroom can encode AVIF images, it is built with libavif when the avif build tag is set
+const AVIFSupported = true
+
+func encodeAVIF(img image.Image, opt *AvifOptions) ([]byte, error) {
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, errors.New("avif: cannot encode an empty image")
+	}
+	// libavif takes non-premultiplied RGBA pixels starting at the origin
+	rgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+
+	var output C.avifRWData
+	res := C.encodeAVIF((*C.uint8_t)(unsafe.Pointer(&rgba.Pix[0])), C.uint32_t(b.Dx()), C.uint32_t(b.Dy()),
+		C.uint32_t(rgba.Stride), C.int(opt.Quality), C.int(opt.Speed), &output)
+	if res != C.AVIF_RESULT_OK {
+		return nil, errors.New("avif: " + C.GoString(C.avifResultToString(res)))
+	}
+	defer C.avifRWDataFree(&output)
+	return C.GoBytes(unsafe.Pointer(output.data), C.int(output.size)), nil
+}
@@ -0,0 +1,18 @@
+//go:build !avif || !cgo
+
+package native
+
+import (
+	"errors"
+	"image"
+)
+
+// AVIFSupported tells whether darkroom can encode AVIF images, it is built with libavif when the avif build tag is set
+const AVIFSupported = false
+
+// ErrAVIFNotSupported is returned when an image is encoded as AVIF by a darkroom built without the avif build tag
+var ErrAVIFNotSupported = errors.New("avif: darkroom is built without the avif build tag")
+
+func encodeAVIF(image.Image, *AvifOptions) ([]byte, error) {
+	return nil, ErrAVIFNotSupported
+}
@@ -0,0 +1,16 @@
+//go:build !avif || !cgo
+
+package native
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvifEncoder_Encode_ReturnsErrorWithoutAvifBuildTag(t *testing.T) {
+	data, err := (&AvifEncoder{}).Encode(image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+	assert.Nil(t, data)
+	assert.Equal(t, ErrAVIFNotSupported, err)
+}
@@ -0,0 +1,17 @@
+//go:build avif && cgo
+
+package native
+
+import (
+	"image"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvifEncoder_Encode_ShouldEncodeToAvif(t *testing.T) {
+	data, err := (&AvifEncoder{}).Encode(image.NewNRGBA(image.Rect(0, 0, 16, 16)))
+	assert.NoError(t, err)
+	assert.Equal(t, processor.ExtensionAVIF, processor.DetectFormat(data))
+}
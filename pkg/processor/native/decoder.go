@@ -0,0 +1,8 @@
+package native
+
+import (
+	// The bmp and tiff decoders are registered with the image package for BildProcessor.Decode, the jpeg, png
+	// and webp decoders are registered by the imports of the encoders and the ico decoder by ico.go
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+)
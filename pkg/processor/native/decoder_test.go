@@ -0,0 +1,98 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/bmp"
+)
+
+// newGrayTIFF returns an uncompressed little-endian TIFF with a page for each of the gray levels, the pages are
+// square and the size of the first one is given, the size of each next page grows by a pixel
+func newGrayTIFF(size int, levels ...uint8) []byte {
+	buff := &bytes.Buffer{}
+	buff.WriteString("II*\x00")
+	_ = binary.Write(buff, binary.LittleEndian, uint32(8))
+	for i, level := range levels {
+		s := size + i
+		const entries = 8
+		ifdLen := 2 + entries*12 + 4
+		stripOffset := buff.Len() + ifdLen
+		next := uint32(0)
+		if i < len(levels)-1 {
+			next = uint32(stripOffset + s*s)
+		}
+		_ = binary.Write(buff, binary.LittleEndian, uint16(entries))
+		for _, e := range [][3]uint32{
+			{256, 3, uint32(s)},           // ImageWidth
+			{257, 3, uint32(s)},           // ImageLength
+			{258, 3, 8},                   // BitsPerSample
+			{259, 3, 1},                   // Compression: none
+			{262, 3, 1},                   // PhotometricInterpretation: BlackIsZero
+			{273, 4, uint32(stripOffset)}, // StripOffsets
+			{278, 3, uint32(s)},           // RowsPerStrip
+			{279, 4, uint32(s * s)},       // StripByteCounts
+		} {
+			_ = binary.Write(buff, binary.LittleEndian, uint16(e[0]))
+			_ = binary.Write(buff, binary.LittleEndian, uint16(e[1]))
+			_ = binary.Write(buff, binary.LittleEndian, uint32(1))
+			_ = binary.Write(buff, binary.LittleEndian, e[2])
+		}
+		_ = binary.Write(buff, binary.LittleEndian, next)
+		buff.Write(bytes.Repeat([]byte{level}, s*s))
+	}
+	return buff.Bytes()
+}
+
+func TestBildProcessor_Decode_WithTIFF(t *testing.T) {
+	img, f, err := NewBildProcessor().Decode(newGrayTIFF(2, 0x20, 0xc0))
+
+	assert.NoError(t, err)
+	assert.Equal(t, processor.ExtensionTIFF, f)
+	assert.Equal(t, image.Rect(0, 0, 2, 2), img.Bounds())
+	assert.Equal(t, color.Gray{Y: 0x20}, color.GrayModel.Convert(img.At(1, 1)))
+}
+
+func TestBildProcessor_Decode_WithBMP(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(1, 1, red)
+	buff := &bytes.Buffer{}
+	_ = bmp.Encode(buff, src)
+
+	img, f, err := NewBildProcessor().Decode(buff.Bytes())
+
+	assert.NoError(t, err)
+	assert.Equal(t, processor.ExtensionBMP, f)
+	assert.Equal(t, image.Rect(0, 0, 3, 2), img.Bounds())
+	assert.Equal(t, red, rgbaAt(img, 1, 1))
+}
+
+func TestBildProcessor_Decode_WithUnsupportedFormat(t *testing.T) {
+	cases := []struct {
+		data     []byte
+		expected string
+	}{
+		{data: []byte("%PDF-1.4 not an image"), expected: "application/pdf"},
+		{data: []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00"), expected: processor.ExtensionAVIF},
+		{data: []byte("badImage.ext"), expected: "text/plain"},
+	}
+	for _, c := range cases {
+		_, _, err := NewBildProcessor().Decode(c.data)
+		var ufe *processor.UnsupportedFormatError
+		if assert.ErrorAs(t, err, &ufe) {
+			assert.Equal(t, c.expected, ufe.Format)
+		}
+	}
+
+	// A known format with a corrupt body is not an unsupported format
+	_, _, err := NewBildProcessor().Decode([]byte("\x89PNG\r\n\x1a\ncorrupt"))
+	var ufe *processor.UnsupportedFormatError
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &ufe))
+}
@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"errors"
 	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
 
@@ -26,11 +30,40 @@ type PngEncoder struct {
 	Encoder *png.Encoder
 }
 
-// WebPEncoder is an object to encode image to byte array with webp format
+// WebPEncoder is an object to encode image to byte array with webp format, a processor.Animation is encoded
+// as an animated WebP image
 type WebPEncoder struct {
 	Option *webp.Options
+	// FrameOption are the encoding parameters of every frame of an animation, Option is used if it is nil
+	FrameOption *webp.Options
 }
 
+// GifEncoder is an object to encode image to byte array with gif format, a processor.Animation is encoded
+// with all its frames
+type GifEncoder struct {
+	Option *gif.Options
+}
+
+// AvifEncoder is an object to encode image to byte array with avif format
+type AvifEncoder struct {
+	Option *AvifOptions
+}
+
+// AvifOptions are the encoding parameters of AvifEncoder
+type AvifOptions struct {
+	// Quality ranges from 0 to 100, 100 is lossless
+	Quality int
+	// Speed ranges from 0 to 10, 0 is the slowest and gives the smallest images
+	Speed int
+}
+
+const (
+	// DefaultAvifQuality is the quality used by AvifEncoder if it is created without options
+	DefaultAvifQuality = 60
+	// DefaultAvifSpeed is the speed used by AvifEncoder if it is created without options
+	DefaultAvifSpeed = 6
+)
+
 // NopEncoder is a no-op encoder object for unsupported format and will return error
 type NopEncoder struct{}
 
@@ -46,12 +79,119 @@ func (e *JpegEncoder) Encode(img image.Image) ([]byte, error) {
 	return buff.Bytes(), err
 }
 
+// withQuality returns a copy of the encoder with the quality if it ranges from 1 to 100, and the encoder otherwise
+func (e *JpegEncoder) withQuality(quality int) *JpegEncoder {
+	if quality < 1 || quality > 100 {
+		return e
+	}
+	return &JpegEncoder{Option: &jpeg.Options{Quality: quality}}
+}
+
+// quality returns the quality of the encoded images, jpeg.DefaultQuality is used by jpeg.Encode if Option is nil
+func (e *JpegEncoder) quality() int {
+	if e.Option == nil {
+		return jpeg.DefaultQuality
+	}
+	return e.Option.Quality
+}
+
 func (e *WebPEncoder) Encode(img image.Image) ([]byte, error) {
+	if a, ok := img.(*processor.Animation); ok {
+		opt := e.FrameOption
+		if opt == nil {
+			opt = e.Option
+		}
+		return encodeAnimatedWebP(a, opt)
+	}
 	buff := &bytes.Buffer{}
 	err := webp.Encode(buff, img, e.Option)
 	return buff.Bytes(), err
 }
 
+// withQuality returns a copy of the encoder which encodes the still images and the frames of the animations
+// lossy with the quality if it ranges from 1 to 100, and the encoder otherwise
+func (e *WebPEncoder) withQuality(quality int) *WebPEncoder {
+	if quality < 1 || quality > 100 {
+		return e
+	}
+	opt := &webp.Options{}
+	if e.Option != nil {
+		*opt = *e.Option
+	}
+	opt.Lossless = false
+	opt.Quality = float32(quality)
+	return &WebPEncoder{Option: opt, FrameOption: opt}
+}
+
+// gifPalette is the palette used by GifEncoder if it is created without a Quantizer,
+// it is the Plan 9 palette with its last color replaced by a transparent one
+var gifPalette = append(append(color.Palette{}, palette.Plan9[:255]...), color.Transparent)
+
+func (e *GifEncoder) Encode(img image.Image) ([]byte, error) {
+	buff := &bytes.Buffer{}
+	a, ok := img.(*processor.Animation)
+	if !ok {
+		err := gif.Encode(buff, e.toPaletted(img), nil)
+		return buff.Bytes(), err
+	}
+	g := &gif.GIF{
+		Image:     make([]*image.Paletted, len(a.Frames)),
+		Delay:     a.Delays,
+		LoopCount: a.LoopCount,
+	}
+	for i, frame := range a.Frames {
+		g.Image[i] = e.toPaletted(frame)
+	}
+	err := gif.EncodeAll(buff, g)
+	return buff.Bytes(), err
+}
+
+// toPaletted converts the image to an image.Paletted anchored at (0, 0) as the frames of a GIF
+// are placed relative to the top left corner of the canvas
+func (e *GifEncoder) toPaletted(img image.Image) *image.Paletted {
+	b := img.Bounds()
+	if p, ok := img.(*image.Paletted); ok && b.Min == (image.Point{}) {
+		return p
+	}
+	pal, drawer := gifPalette, draw.Drawer(draw.FloydSteinberg)
+	if e.Option != nil {
+		if e.Option.Quantizer != nil {
+			numColors := e.Option.NumColors
+			if numColors < 1 || numColors > 256 {
+				numColors = 256
+			}
+			pal = e.Option.Quantizer.Quantize(make(color.Palette, 0, numColors), img)
+		}
+		if e.Option.Drawer != nil {
+			drawer = e.Option.Drawer
+		}
+	}
+	p := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), pal)
+	drawer.Draw(p, p.Bounds(), img, b.Min)
+	return p
+}
+
+// Encode encodes the image as AVIF, it returns ErrAVIFNotSupported if darkroom is built without the avif build tag
+func (e *AvifEncoder) Encode(img image.Image) ([]byte, error) {
+	opt := e.Option
+	if opt == nil {
+		opt = &AvifOptions{Quality: DefaultAvifQuality, Speed: DefaultAvifSpeed}
+	}
+	return encodeAVIF(img, opt)
+}
+
+// withQuality returns a copy of the encoder with the quality if it ranges from 1 to 100, and the encoder otherwise
+func (e *AvifEncoder) withQuality(quality int) *AvifEncoder {
+	if quality < 1 || quality > 100 {
+		return e
+	}
+	opt := &AvifOptions{Quality: quality, Speed: DefaultAvifSpeed}
+	if e.Option != nil {
+		opt.Speed = e.Option.Speed
+	}
+	return &AvifEncoder{Option: opt}
+}
+
 func (e *NopEncoder) Encode(img image.Image) ([]byte, error) {
 	return nil, errors.New("unknown format: failed to encode image")
 }
@@ -62,6 +202,8 @@ type Encoders struct {
 	pngEncoder  *PngEncoder
 	noOpEncoder *NopEncoder
 	webPEncoder *WebPEncoder
+	gifEncoder  *GifEncoder
+	avifEncoder *AvifEncoder
 }
 
 // EncodersOption represents builder function for Encoders
@@ -69,21 +211,46 @@ type EncodersOption func(*Encoders)
 
 // GetEncoder takes an input of image and extension and return the appropriate Encoder for encoding the image
 func (e *Encoders) GetEncoder(img image.Image, ext string) Encoder {
+	return e.GetEncoderWithQuality(img, ext, 0)
+}
+
+// GetEncoderWithQuality takes an input of image, extension and quality from 1 to 100 and returns the appropriate
+// Encoder for encoding the image with that quality. The jpeg, webp and avif Encoders are copied for the call if the
+// quality is set, so that the shared ones are never modified, while the quality is ignored by the other Encoders.
+// Opaque png images are encoded as jpeg with that quality, unless the quality is 100.
+func (e *Encoders) GetEncoderWithQuality(img image.Image, ext string, quality int) Encoder {
 	switch ext {
 	case processor.ExtensionJPG, processor.ExtensionJPEG:
-		return e.jpegEncoder
+		return e.jpegEncoder.withQuality(quality)
 	case processor.ExtensionPNG:
-		if e.jpegEncoder.Option.Quality != 100 && isOpaque(img) {
-			return e.jpegEncoder
+		if jpegEncoder := e.jpegEncoder.withQuality(quality); jpegEncoder.quality() != 100 && isOpaque(img) {
+			return jpegEncoder
 		}
 		return e.pngEncoder
 	case processor.ExtensionWebP:
-		return e.webPEncoder
+		return e.webPEncoder.withQuality(quality)
+	case processor.ExtensionGIF:
+		return e.gifEncoder
+	case processor.ExtensionAVIF:
+		return e.avifEncoder.withQuality(quality)
 	default:
 		return e.noOpEncoder
 	}
 }
 
+// CanEncode returns true if there is an Encoder for the extension
+func (e *Encoders) CanEncode(ext string) bool {
+	switch ext {
+	case processor.ExtensionJPG, processor.ExtensionJPEG, processor.ExtensionPNG, processor.ExtensionWebP,
+		processor.ExtensionGIF:
+		return true
+	case processor.ExtensionAVIF:
+		return AVIFSupported
+	default:
+		return false
+	}
+}
+
 // WithJpegEncoder is a builder function for setting custom JpegEncoder
 func WithJpegEncoder(jpegEncoder *JpegEncoder) EncodersOption {
 	return func(e *Encoders) {
@@ -105,6 +272,20 @@ func WithWebPEncoder(webPEncoder *WebPEncoder) EncodersOption {
 	}
 }
 
+// WithGifEncoder is a builder function for setting custom GifEncoder
+func WithGifEncoder(gifEncoder *GifEncoder) EncodersOption {
+	return func(e *Encoders) {
+		e.gifEncoder = gifEncoder
+	}
+}
+
+// WithAvifEncoder is a builder function for setting custom AvifEncoder
+func WithAvifEncoder(avifEncoder *AvifEncoder) EncodersOption {
+	return func(e *Encoders) {
+		e.avifEncoder = avifEncoder
+	}
+}
+
 // NewEncoders creates a new Encoders, if called without parameter (builder), all encoders option will be default
 func NewEncoders(opts ...EncodersOption) *Encoders {
 	e := &Encoders{
@@ -114,6 +295,8 @@ func NewEncoders(opts ...EncodersOption) *Encoders {
 		},
 		noOpEncoder: &NopEncoder{},
 		webPEncoder: &WebPEncoder{},
+		gifEncoder:  &GifEncoder{},
+		avifEncoder: &AvifEncoder{Option: &AvifOptions{Quality: DefaultAvifQuality, Speed: DefaultAvifSpeed}},
 	}
 	for _, opt := range opts {
 		opt(e)
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/chai2010/webp"
 	"github.com/gojek/darkroom/pkg/processor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -54,14 +55,31 @@ func TestNewEncoders(t *testing.T) {
 	jpegEncoder := &JpegEncoder{}
 	pngEncoder := &PngEncoder{}
 	webPEncoder := &WebPEncoder{}
+	gifEncoder := &GifEncoder{}
+	avifEncoder := &AvifEncoder{}
 	e := NewEncoders(
+		WithGifEncoder(gifEncoder),
 		WithJpegEncoder(jpegEncoder),
 		WithPngEncoder(pngEncoder),
 		WithWebPEncoder(webPEncoder),
+		WithAvifEncoder(avifEncoder),
 	)
 	assert.Equal(t, jpegEncoder, e.jpegEncoder)
 	assert.Equal(t, pngEncoder, e.pngEncoder)
 	assert.Equal(t, webPEncoder, e.webPEncoder)
+	assert.Equal(t, gifEncoder, e.gifEncoder)
+	assert.Equal(t, avifEncoder, e.avifEncoder)
+}
+
+func TestEncoders_CanEncode(t *testing.T) {
+	e := NewEncoders()
+	assert.True(t, e.CanEncode("jpg"))
+	assert.True(t, e.CanEncode("jpeg"))
+	assert.True(t, e.CanEncode("png"))
+	assert.True(t, e.CanEncode("webp"))
+	assert.True(t, e.CanEncode("gif"))
+	assert.Equal(t, AVIFSupported, e.CanEncode("avif"))
+	assert.False(t, e.CanEncode("unknown"))
 }
 
 func (s *EncoderSuite) TestEncoders_GetEncoder_GivenJpgExtensionShouldReturnJpegEncoder() {
@@ -94,6 +112,67 @@ func (s *EncoderSuite) TestEncoders_GetEncoder_GivenWebPExtensionShouldReturnWeb
 	assert.IsType(s.T(), &WebPEncoder{}, s.encoders.GetEncoder(s.transparentImage, "webp"))
 }
 
+func (s *EncoderSuite) TestEncoders_GetEncoder_GivenGifExtensionShouldReturnGifEncoder() {
+	assert.IsType(s.T(), &GifEncoder{}, s.encoders.GetEncoder(s.transparentImage, "gif"))
+}
+
+func (s *EncoderSuite) TestEncoders_GetEncoder_GivenAvifExtensionShouldReturnAvifEncoder() {
+	assert.IsType(s.T(), &AvifEncoder{}, s.encoders.GetEncoder(s.transparentImage, "avif"))
+}
+
+func (s *EncoderSuite) TestEncoders_GetEncoderWithQuality_ShouldCopyTheEncoders() {
+	jpegEncoder := &JpegEncoder{Option: &jpeg.Options{Quality: 80}}
+	webPEncoder := &WebPEncoder{Option: &webp.Options{Lossless: true, Exact: true}}
+	avifEncoder := &AvifEncoder{Option: &AvifOptions{Quality: 60, Speed: 8}}
+	encoders := NewEncoders(WithJpegEncoder(jpegEncoder), WithWebPEncoder(webPEncoder), WithAvifEncoder(avifEncoder))
+
+	assert.Equal(s.T(), &JpegEncoder{Option: &jpeg.Options{Quality: 30}},
+		encoders.GetEncoderWithQuality(s.opaqueImage, "jpg", 30))
+	assert.Equal(s.T(), &JpegEncoder{Option: &jpeg.Options{Quality: 30}},
+		encoders.GetEncoderWithQuality(s.opaqueImage, "png", 30))
+	webPOption := &webp.Options{Quality: 40, Exact: true}
+	assert.Equal(s.T(), &WebPEncoder{Option: webPOption, FrameOption: webPOption},
+		encoders.GetEncoderWithQuality(s.transparentImage, "webp", 40))
+	assert.Equal(s.T(), &AvifEncoder{Option: &AvifOptions{Quality: 70, Speed: 8}},
+		encoders.GetEncoderWithQuality(s.transparentImage, "avif", 70))
+
+	assert.Equal(s.T(), &JpegEncoder{Option: &jpeg.Options{Quality: 80}}, jpegEncoder)
+	assert.Equal(s.T(), &WebPEncoder{Option: &webp.Options{Lossless: true, Exact: true}}, webPEncoder)
+	assert.Equal(s.T(), &AvifEncoder{Option: &AvifOptions{Quality: 60, Speed: 8}}, avifEncoder)
+}
+
+func (s *EncoderSuite) TestEncoders_GetEncoderWithQuality_ShouldReturnTheSharedEncodersWithoutQuality() {
+	encoders := NewEncoders()
+	for _, q := range []int{0, -1, 101} {
+		assert.Same(s.T(), encoders.jpegEncoder, encoders.GetEncoderWithQuality(s.opaqueImage, "jpg", q))
+		assert.Same(s.T(), encoders.jpegEncoder, encoders.GetEncoderWithQuality(s.opaqueImage, "png", q))
+		assert.Same(s.T(), encoders.webPEncoder, encoders.GetEncoderWithQuality(s.opaqueImage, "webp", q))
+		assert.Same(s.T(), encoders.avifEncoder, encoders.GetEncoderWithQuality(s.opaqueImage, "avif", q))
+	}
+	assert.Same(s.T(), encoders.pngEncoder, encoders.GetEncoderWithQuality(s.transparentImage, "png", 50))
+	assert.Same(s.T(), encoders.gifEncoder, encoders.GetEncoderWithQuality(s.opaqueImage, "gif", 50))
+}
+
+func (s *EncoderSuite) TestEncoders_GetEncoderWithQuality_GivenOpaqueImageAndPngExtension() {
+	encoders := NewEncoders(WithJpegEncoder(&JpegEncoder{Option: &jpeg.Options{Quality: 100}}))
+	assert.IsType(s.T(), &PngEncoder{}, encoders.GetEncoderWithQuality(s.opaqueImage, "png", 0))
+	assert.IsType(s.T(), &JpegEncoder{}, encoders.GetEncoderWithQuality(s.opaqueImage, "png", 90))
+
+	encoders = NewEncoders(WithJpegEncoder(&JpegEncoder{}))
+	assert.IsType(s.T(), &JpegEncoder{}, encoders.GetEncoderWithQuality(s.opaqueImage, "png", 0))
+	assert.IsType(s.T(), &PngEncoder{}, encoders.GetEncoderWithQuality(s.opaqueImage, "png", 100))
+}
+
+func (s *EncoderSuite) TestBildProcessor_EncodeWithQuality_QualityShouldAffectFileSize() {
+	for _, f := range []string{"jpg", "webp"} {
+		low, err := s.processor.(*BildProcessor).EncodeWithQuality(s.srcImage, f, 10)
+		assert.NoError(s.T(), err)
+		high, err := s.processor.(*BildProcessor).EncodeWithQuality(s.srcImage, f, 95)
+		assert.NoError(s.T(), err)
+		assert.True(s.T(), len(low) < len(high), "%s: %d >= %d", f, len(low), len(high))
+	}
+}
+
 func (s *EncoderSuite) TestJpgEncoder_Encode_ShouldEncodeToJpeg() {
 	encoder := JpegEncoder{Option: nil}
 	data, err := encoder.Encode(s.srcImage)
@@ -0,0 +1,253 @@
+package native
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/anthonynsimon/bild/transform"
+)
+
+const (
+	// faceAnalysisSize is the largest side of the downscaled image in which the faces are detected
+	faceAnalysisSize = 512
+	// minFaceSize is the smallest side of the detected faces in the downscaled image
+	minFaceSize = 20
+	// faceShiftFactor is the step of the sliding windows relative to their size
+	faceShiftFactor = 0.1
+	// faceScaleFactor is the growth of the size of the sliding windows between two scans of the image
+	faceScaleFactor = 1.1
+	// faceIoUThreshold is the intersection over union above which two detections are the same face
+	faceIoUThreshold = 0.2
+	// minFaceQuality is the sum of the scores of the clustered detections above which they are a face
+	minFaceQuality = 5.0
+)
+
+var (
+	errInvalidCascade = errors.New("faces: invalid cascade")
+	// faceBoxColor is the color of the boxes drawn by MarkFaces
+	faceBoxColor = color.RGBA{G: 255, A: 255}
+
+	defaultFaceDetector     *FaceDetector
+	defaultFaceDetectorOnce sync.Once
+)
+
+// facefinder is the frontal face cascade of pico as distributed by pigo, see cascade/LICENSE
+//
+//go:embed cascade/facefinder
+var facefinder []byte
+
+// getDefaultFaceDetector returns the FaceDetector of the bundled facefinder cascade which is used to detect faces
+// if no FaceDetector is configured
+func getDefaultFaceDetector() *FaceDetector {
+	defaultFaceDetectorOnce.Do(func() {
+		// The bundled cascade is known to be valid
+		defaultFaceDetector, _ = NewFaceDetector(facefinder)
+	})
+	return defaultFaceDetector
+}
+
+// FaceDetector detects faces with a cascade of pixel intensity comparison trees as described in
+// https://arxiv.org/abs/1305.4537, it runs the cascades trained for pico and pigo, eg: pigo's facefinder
+type FaceDetector struct {
+	treeDepth  int
+	treeNum    int
+	treeCodes  []int8
+	treePreds  []float32
+	thresholds []float32
+}
+
+// faceDetection is a square region of the image centered on row, col with a side of size
+type faceDetection struct {
+	row, col, size float64
+	quality        float64
+}
+
+// NewFaceDetector creates a new FaceDetector from a cascade in the binary format of pico, which starts with
+// 8 reserved bytes, the depth and the number of the trees, followed by the binary tests of the internal nodes,
+// the predictions of the leaves and the threshold of every tree
+func NewFaceDetector(cascade []byte) (*FaceDetector, error) {
+	if len(cascade) < 16 {
+		return nil, errInvalidCascade
+	}
+	d := &FaceDetector{
+		treeDepth: int(binary.LittleEndian.Uint32(cascade[8:])),
+		treeNum:   int(binary.LittleEndian.Uint32(cascade[12:])),
+	}
+	if d.treeDepth < 1 || d.treeDepth > 16 || d.treeNum < 1 {
+		return nil, errInvalidCascade
+	}
+	leaves := 1 << d.treeDepth
+	codesLen, treeLen := 4*leaves-4, 4*leaves-4+4*leaves+4
+	if (len(cascade)-16)/treeLen < d.treeNum {
+		return nil, errInvalidCascade
+	}
+	pos := 16
+	for t := 0; t < d.treeNum; t++ {
+		// The codes of the root node are unused, they are set so that the node i of every tree is at 4*i
+		d.treeCodes = append(d.treeCodes, 0, 0, 0, 0)
+		for _, c := range cascade[pos : pos+codesLen] {
+			d.treeCodes = append(d.treeCodes, int8(c))
+		}
+		pos += codesLen
+		for i := 0; i < leaves; i++ {
+			d.treePreds = append(d.treePreds, math.Float32frombits(binary.LittleEndian.Uint32(cascade[pos:])))
+			pos += 4
+		}
+		d.thresholds = append(d.thresholds, math.Float32frombits(binary.LittleEndian.Uint32(cascade[pos:])))
+		pos += 4
+	}
+	return d, nil
+}
+
+// Detect returns the bounding boxes of the faces of the image, ordered by their quality
+func (d *FaceDetector) Detect(img image.Image) []image.Rectangle {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	s := math.Min(1, faceAnalysisSize/math.Max(float64(w), float64(h)))
+	aw, ah := clampInt(int(math.Round(float64(w)*s)), 1, w), clampInt(int(math.Round(float64(h)*s)), 1, h)
+	var gray *image.Gray
+	if aw != w || ah != h {
+		gray = toGray(transform.Resize(img, aw, ah, transform.Linear))
+	} else {
+		gray = toGray(img)
+	}
+
+	var detections []faceDetection
+	maxSize := math.Min(float64(aw), float64(ah))
+	for size := float64(minFaceSize); size <= maxSize; size *= faceScaleFactor {
+		step := int(math.Max(faceShiftFactor*size, 1))
+		offset := int(size)/2 + 1
+		for row := offset; row <= ah-offset; row += step {
+			for col := offset; col <= aw-offset; col += step {
+				if q := d.classifyRegion(gray, row, col, int(size)); q > 0 {
+					detections = append(detections, faceDetection{
+						row: float64(row), col: float64(col), size: float64(int(size)), quality: float64(q),
+					})
+				}
+			}
+		}
+	}
+
+	var faces []image.Rectangle
+	for _, f := range clusterFaceDetections(detections) {
+		if f.quality < minFaceQuality {
+			continue
+		}
+		faces = append(faces, image.Rect(
+			int(math.Round((f.col-f.size/2)/s)), int(math.Round((f.row-f.size/2)/s)),
+			int(math.Round((f.col+f.size/2)/s)), int(math.Round((f.row+f.size/2)/s)),
+		).Add(b.Min).Intersect(b))
+	}
+	return faces
+}
+
+// classifyRegion runs the cascade on the square of the image centered on row, col with a side of size, it returns
+// a positive score if the square is a face and -1 otherwise
+func (d *FaceDetector) classifyRegion(gray *image.Gray, row, col, size int) float32 {
+	leaves := 1 << d.treeDepth
+	root := 0
+	r, c := row*256, col*256
+	var out float32
+	for t := 0; t < d.treeNum; t++ {
+		idx := 1
+		for j := 0; j < d.treeDepth; j++ {
+			code := d.treeCodes[root+4*idx : root+4*idx+4 : root+4*idx+4]
+			p1 := gray.Pix[((r+int(code[0])*size)>>8)*gray.Stride+((c+int(code[1])*size)>>8)]
+			p2 := gray.Pix[((r+int(code[2])*size)>>8)*gray.Stride+((c+int(code[3])*size)>>8)]
+			idx = 2 * idx
+			if p1 <= p2 {
+				idx++
+			}
+		}
+		out += d.treePreds[leaves*t+idx-leaves]
+		if out <= d.thresholds[t] {
+			return -1
+		}
+		root += 4 * leaves
+	}
+	return out - d.thresholds[d.treeNum-1]
+}
+
+// clusterFaceDetections merges the detections which overlap, starting from the ones with the best quality,
+// into their average square with the sum of their qualities
+func clusterFaceDetections(detections []faceDetection) []faceDetection {
+	sort.SliceStable(detections, func(i, j int) bool {
+		return detections[i].quality > detections[j].quality
+	})
+	assigned := make([]bool, len(detections))
+	var clusters []faceDetection
+	for i := range detections {
+		if assigned[i] {
+			continue
+		}
+		var sum faceDetection
+		n := 0.0
+		for j := i; j < len(detections); j++ {
+			if !assigned[j] && getFaceIoU(detections[i], detections[j]) > faceIoUThreshold {
+				assigned[j] = true
+				sum.row += detections[j].row
+				sum.col += detections[j].col
+				sum.size += detections[j].size
+				sum.quality += detections[j].quality
+				n++
+			}
+		}
+		clusters = append(clusters, faceDetection{row: sum.row / n, col: sum.col / n, size: sum.size / n, quality: sum.quality})
+	}
+	return clusters
+}
+
+// getFaceIoU returns the intersection over union of two detections
+func getFaceIoU(a, b faceDetection) float64 {
+	overRow := math.Max(0, math.Min(a.row+a.size/2, b.row+b.size/2)-math.Max(a.row-a.size/2, b.row-b.size/2))
+	overCol := math.Max(0, math.Min(a.col+a.size/2, b.col+b.size/2)-math.Max(a.col-a.size/2, b.col-b.size/2))
+	inter := overRow * overCol
+	return inter / (a.size*a.size + b.size*b.size - inter)
+}
+
+// toGray returns the Rec. 601 luma of the image anchored at (0, 0)
+func toGray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(gray, gray.Bounds(), img, b.Min, draw.Src)
+	return gray
+}
+
+// getFaceCropOffset returns the starting point of the width x height window of img centered on the union of the
+// faces and true, or false if no face is detected
+func getFaceCropOffset(d *FaceDetector, img image.Image, width, height int) (int, int, bool) {
+	faces := d.Detect(img)
+	if len(faces) == 0 {
+		return 0, 0, false
+	}
+	union := faces[0]
+	for _, f := range faces[1:] {
+		union = union.Union(f)
+	}
+	b := img.Bounds()
+	cx, cy := (union.Min.X+union.Max.X)/2-b.Min.X, (union.Min.Y+union.Max.Y)/2-b.Min.Y
+	return clampInt(cx-width/2, 0, b.Dx()-width), clampInt(cy-height/2, 0, b.Dy()-height), true
+}
+
+// drawFaceBoxes draws the outline of the boxes on the image
+func drawFaceBoxes(dst *image.RGBA, boxes []image.Rectangle) {
+	const thickness = 2
+	src := image.NewUniform(faceBoxColor)
+	for _, r := range boxes {
+		for _, edge := range []image.Rectangle{
+			image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+thickness),
+			image.Rect(r.Min.X, r.Max.Y-thickness, r.Max.X, r.Max.Y),
+			image.Rect(r.Min.X, r.Min.Y, r.Min.X+thickness, r.Max.Y),
+			image.Rect(r.Max.X-thickness, r.Min.Y, r.Max.X, r.Max.Y),
+		} {
+			draw.Draw(dst, edge.Intersect(r), src, image.Point{}, draw.Src)
+		}
+	}
+}
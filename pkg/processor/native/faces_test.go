@@ -0,0 +1,161 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"io/ioutil"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+// newDarkSquareCascade returns a cascade of 4 trees of depth 1 which detects the dark squares on a bright background,
+// each tree checks that the middle of a side of the window is brighter than its center
+func newDarkSquareCascade() []byte {
+	buff := &bytes.Buffer{}
+	buff.Write(make([]byte, 8))
+	_ = binary.Write(buff, binary.LittleEndian, []uint32{1, 4})
+	for i, side := range [][2]int8{{0, -120}, {0, 120}, {-120, 0}, {120, 0}} {
+		buff.Write([]byte{byte(side[0]), byte(side[1]), 0, 0})
+		_ = binary.Write(buff, binary.LittleEndian, []float32{1, -1, float32(i)})
+	}
+	return buff.Bytes()
+}
+
+func newDarkSquareImage(w, h int, square image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, square, image.Black, image.Point{}, draw.Src)
+	return img
+}
+
+func TestNewFaceDetector(t *testing.T) {
+	d, err := NewFaceDetector(newDarkSquareCascade())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, d.treeDepth)
+	assert.Equal(t, 4, d.treeNum)
+	assert.Equal(t, []int8{0, 0, 0, 0, 0, -120, 0, 0}, d.treeCodes[:8])
+	assert.Equal(t, []float32{1, -1, 1, -1}, d.treePreds[:4])
+	assert.Equal(t, []float32{0, 1, 2, 3}, d.thresholds)
+
+	cascade := newDarkSquareCascade()
+	for _, data := range [][]byte{nil, cascade[:15], cascade[:len(cascade)-1]} {
+		_, err = NewFaceDetector(data)
+		assert.Equal(t, errInvalidCascade, err)
+	}
+	binary.LittleEndian.PutUint32(cascade[8:], 0)
+	_, err = NewFaceDetector(cascade)
+	assert.Equal(t, errInvalidCascade, err)
+}
+
+func TestFaceDetector_Detect(t *testing.T) {
+	d, _ := NewFaceDetector(newDarkSquareCascade())
+	cases := []struct {
+		name   string
+		img    image.Image
+		center image.Point
+	}{
+		{name: "Small", img: newDarkSquareImage(300, 200, image.Rect(180, 80, 220, 120)), center: image.Pt(200, 100)},
+		{name: "Downscaled", img: newDarkSquareImage(1200, 800, image.Rect(200, 500, 360, 660)), center: image.Pt(280, 580)},
+		{name: "SubImage", img: newDarkSquareImage(400, 300, image.Rect(280, 180, 320, 220)).SubImage(image.Rect(100, 100, 400, 300)),
+			center: image.Pt(300, 200)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			faces := d.Detect(c.img)
+			assert.NotEmpty(t, faces)
+			for _, f := range faces {
+				assert.True(t, c.center.In(f), "face %v", f)
+				assert.True(t, f.In(c.img.Bounds()), "face %v", f)
+			}
+			assert.Equal(t, faces, d.Detect(c.img))
+		})
+	}
+
+	assert.Empty(t, d.Detect(newDarkSquareImage(300, 200, image.Rectangle{})))
+}
+
+func TestBildProcessor_CropWithFaces(t *testing.T) {
+	d, _ := NewFaceDetector(newDarkSquareCascade())
+	img := newDarkSquareImage(300, 100, image.Rect(230, 30, 270, 70))
+
+	// The window is centered on the square, up to the step of the detection windows
+	x, y := NewBildProcessor(WithFaceDetector(d)).getCropOffset(img, 100, 100, processor.PointFaces)
+	assert.InDelta(t, 200, x, 5)
+	assert.Equal(t, 0, y)
+	out := NewBildProcessor(WithFaceDetector(d)).Crop(img, 50, 50, processor.PointFaces)
+	assert.True(t, image.Rect(115, 15, 135, 35).In(out.Bounds()), "crop %v", out.Bounds())
+
+	// The crop falls back to the configured Point if no face is detected
+	blank := newDarkSquareImage(300, 100, image.Rectangle{})
+	x, y = NewBildProcessor(WithFaceDetector(d)).getCropOffset(blank, 100, 100, processor.PointFaces)
+	assert.Equal(t, image.Pt(100, 0), image.Pt(x, y))
+	x, y = NewBildProcessor(WithFaceDetector(d), WithFaceCropFallback(processor.PointRight)).
+		getCropOffset(blank, 100, 100, processor.PointFaces)
+	assert.Equal(t, image.Pt(200, 0), image.Pt(x, y))
+	x, y = NewBildProcessor(WithFaceDetector(nil), WithFaceCropFallback(processor.PointLeft)).
+		getCropOffset(img, 100, 100, processor.PointFaces)
+	assert.Equal(t, image.Pt(0, 0), image.Pt(x, y))
+	x, y = NewBildProcessor(WithFaceDetector(nil), WithFaceCropFallback(processor.PointFaces)).
+		getCropOffset(img, 100, 100, processor.PointFaces)
+	assert.Equal(t, image.Pt(100, 0), image.Pt(x, y))
+}
+
+func TestBildProcessor_MarkFaces(t *testing.T) {
+	d, _ := NewFaceDetector(newDarkSquareCascade())
+	img := newDarkSquareImage(300, 200, image.Rect(180, 80, 220, 120))
+
+	out := NewBildProcessor(WithFaceDetector(d)).MarkFaces(img)
+	faces := d.Detect(img)
+	if assert.NotEmpty(t, faces) {
+		assert.Equal(t, faceBoxColor, rgbaAt(out, faces[0].Min.X, faces[0].Min.Y))
+		assert.Equal(t, faceBoxColor, rgbaAt(out, faces[0].Max.X-1, faces[0].Max.Y-1))
+	}
+	assert.Equal(t, color.RGBA{A: 255}, rgbaAt(out, 200, 100))
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, rgbaAt(img, faces[0].Min.X, faces[0].Min.Y))
+
+	assert.Same(t, img, NewBildProcessor(WithFaceDetector(nil)).MarkFaces(img))
+}
+
+func newFacesImage(t *testing.T) image.Image {
+	data, err := ioutil.ReadFile("_testdata/faces.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestFaceDetector_DetectWithDefaultCascade(t *testing.T) {
+	// The face of the 320x400 photo spans from the forehead to the chin
+	img := newFacesImage(t)
+
+	faces := getDefaultFaceDetector().Detect(img)
+
+	if assert.Len(t, faces, 1) {
+		assert.True(t, image.Rect(90, 150, 230, 300).In(faces[0]), "face %v", faces[0])
+		assert.True(t, faces[0].In(img.Bounds()), "face %v", faces[0])
+	}
+	assert.Empty(t, getDefaultFaceDetector().Detect(newDarkSquareImage(300, 200, image.Rect(130, 80, 170, 120))))
+}
+
+func TestBildProcessor_CropWithFacesWithDefaultCascade(t *testing.T) {
+	// The photo is on the right of a white canvas, so that the crop around the face is far from the center
+	photo := newFacesImage(t)
+	img := image.NewRGBA(image.Rect(0, 0, 960, 400))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, photo.Bounds().Add(image.Pt(640, 0)), photo, image.Point{}, draw.Src)
+
+	x, y := NewBildProcessor().getCropOffset(img, 300, 300, processor.PointFaces)
+
+	assert.InDelta(t, 645, x, 20)
+	assert.InDelta(t, 53, y, 20)
+}
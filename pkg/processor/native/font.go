@@ -0,0 +1,53 @@
+package native
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+var (
+	defaultFont     *opentype.Font
+	defaultFontOnce sync.Once
+)
+
+// getDefaultFont returns the bundled Go Regular font which is used to render text if no font is configured
+func getDefaultFont() *opentype.Font {
+	defaultFontOnce.Do(func() {
+		// The bundled font is known to be valid
+		defaultFont, _ = opentype.Parse(goregular.TTF)
+	})
+	return defaultFont
+}
+
+// ParseFont takes the contents of a TTF or an OTF file and returns the font which can be passed to WithFont
+func ParseFont(data []byte) (*opentype.Font, error) {
+	return opentype.Parse(data)
+}
+
+// drawString draws s like font.Drawer.DrawString, but only the glyphs which overlap the bounds of d.Dst are
+// rasterized, so that the glyphs of a text clipped to the base image are skipped
+func drawString(d *font.Drawer, s string) {
+	bounds := d.Dst.Bounds()
+	prevC := rune(-1)
+	for _, c := range s {
+		if prevC >= 0 {
+			d.Dot.X += d.Face.Kern(prevC, c)
+		}
+		gb, advance, ok := d.Face.GlyphBounds(c)
+		r := image.Rect((d.Dot.X + gb.Min.X).Floor(), (d.Dot.Y + gb.Min.Y).Floor(),
+			(d.Dot.X + gb.Max.X).Ceil(), (d.Dot.Y + gb.Max.Y).Ceil())
+		if !ok || r.Overlaps(bounds) {
+			dr, mask, maskp, _, _ := d.Face.Glyph(d.Dot, c)
+			if !dr.Empty() {
+				draw.DrawMask(d.Dst, dr, d.Src, image.Point{}, mask, maskp, draw.Over)
+			}
+		}
+		d.Dot.X += advance
+		prevC = c
+	}
+}
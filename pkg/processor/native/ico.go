@@ -0,0 +1,192 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	icoHeaderLen      = 6
+	icoEntryLen       = 16
+	dibInfoHeaderLen  = 40
+	icoMagic          = "\x00\x00\x01\x00"
+	maxICOImageLength = 64 << 20
+)
+
+var errInvalidICO = errors.New("ico: invalid format")
+
+func init() {
+	image.RegisterFormat("ico", icoMagic, decodeICO, decodeICOConfig)
+}
+
+type icoEntry struct {
+	width, height int
+	bitCount      int
+	size, offset  int
+}
+
+// decodeICO decodes the largest image of an ICO file, the images are either PNG images or
+// Windows bitmaps without the file header
+func decodeICO(r io.Reader) (image.Image, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	e, err := getLargestICOEntry(data)
+	if err != nil {
+		return nil, err
+	}
+	img := data[e.offset : e.offset+e.size]
+	if bytes.HasPrefix(img, []byte("\x89PNG\r\n\x1a\n")) {
+		return png.Decode(bytes.NewReader(img))
+	}
+	return decodeDIB(img)
+}
+
+func decodeICOConfig(r io.Reader) (image.Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	e, err := getLargestICOEntry(data)
+	if err != nil {
+		return image.Config{}, err
+	}
+	img := data[e.offset : e.offset+e.size]
+	if bytes.HasPrefix(img, []byte("\x89PNG\r\n\x1a\n")) {
+		return png.DecodeConfig(bytes.NewReader(img))
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: e.width, Height: e.height}, nil
+}
+
+// getLargestICOEntry returns the directory entry of the image with the most pixels, and the most bits per pixel
+// among the images of the same size
+func getLargestICOEntry(data []byte) (icoEntry, error) {
+	if len(data) < icoHeaderLen || string(data[:4]) != icoMagic {
+		return icoEntry{}, errInvalidICO
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+	if count == 0 || len(data) < icoHeaderLen+count*icoEntryLen {
+		return icoEntry{}, errInvalidICO
+	}
+	var best icoEntry
+	for i := 0; i < count; i++ {
+		b := data[icoHeaderLen+i*icoEntryLen:]
+		e := icoEntry{
+			width:    int(b[0]),
+			height:   int(b[1]),
+			bitCount: int(binary.LittleEndian.Uint16(b[6:8])),
+			size:     int(binary.LittleEndian.Uint32(b[8:12])),
+			offset:   int(binary.LittleEndian.Uint32(b[12:16])),
+		}
+		// A width or a height of 0 stands for 256 pixels
+		if e.width == 0 {
+			e.width = 256
+		}
+		if e.height == 0 {
+			e.height = 256
+		}
+		if e.size <= 0 || e.size > maxICOImageLength || e.offset < 0 || e.offset+e.size > len(data) {
+			return icoEntry{}, errInvalidICO
+		}
+		pixels, bestPixels := e.width*e.height, best.width*best.height
+		if pixels > bestPixels || (pixels == bestPixels && e.bitCount > best.bitCount) {
+			best = e
+		}
+	}
+	return best, nil
+}
+
+// decodeDIB decodes a bottom-up Windows bitmap of an ICO file. Its height covers the color bitmap followed by
+// a 1-bit AND mask which makes the pixels transparent, the mask is ignored if the 32-bit pixels have alpha values.
+func decodeDIB(data []byte) (image.Image, error) {
+	if len(data) < dibInfoHeaderLen {
+		return nil, errInvalidICO
+	}
+	headerLen := int(binary.LittleEndian.Uint32(data[0:4]))
+	w := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	h := int(int32(binary.LittleEndian.Uint32(data[8:12]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(data[14:16]))
+	compression := binary.LittleEndian.Uint32(data[16:20])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:36]))
+	if headerLen < dibInfoHeaderLen || headerLen > len(data) || w <= 0 || h <= 0 || w > 256 || h > 256 || compression != 0 {
+		return nil, errInvalidICO
+	}
+
+	var pal color.Palette
+	offset := headerLen
+	switch bitCount {
+	case 1, 4, 8:
+		if colorsUsed == 0 || colorsUsed > 1<<bitCount {
+			colorsUsed = 1 << bitCount
+		}
+		if offset+colorsUsed*4 > len(data) {
+			return nil, errInvalidICO
+		}
+		pal = make(color.Palette, colorsUsed)
+		for i := range pal {
+			c := data[offset+i*4:]
+			pal[i] = color.NRGBA{R: c[2], G: c[1], B: c[0], A: 0xff}
+		}
+		offset += colorsUsed * 4
+	case 24, 32:
+	default:
+		return nil, errInvalidICO
+	}
+
+	// The rows of both the bitmap and the mask are padded to 4 bytes
+	stride := (w*bitCount + 31) / 32 * 4
+	maskStride := (w + 31) / 32 * 4
+	if offset+stride*h > len(data) {
+		return nil, errInvalidICO
+	}
+	pixels := data[offset : offset+stride*h]
+	var mask []byte
+	if offset+stride*h+maskStride*h <= len(data) {
+		mask = data[offset+stride*h : offset+stride*h+maskStride*h]
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	var hasAlpha bool
+	for y := 0; y < h; y++ {
+		row := pixels[(h-1-y)*stride:]
+		for x := 0; x < w; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 32:
+				c = color.NRGBA{R: row[x*4+2], G: row[x*4+1], B: row[x*4], A: row[x*4+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 0xff}
+			default:
+				bit := x * bitCount
+				i := int(row[bit/8]>>(8-bitCount-bit%8)) & (1<<bitCount - 1)
+				if i < len(pal) {
+					c = pal[i].(color.NRGBA)
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// The AND mask is only used if the pixels don't have alpha values
+	if bitCount == 32 && hasAlpha {
+		return img, nil
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a := uint8(0xff)
+			if mask != nil && mask[(h-1-y)*maskStride+x/8]&(0x80>>(x%8)) != 0 {
+				a = 0
+			}
+			img.Pix[img.PixOffset(x, y)+3] = a
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,132 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+type icoImage struct {
+	width, height int
+	bitCount      int
+	data          []byte
+}
+
+func newICO(images ...icoImage) []byte {
+	buff := &bytes.Buffer{}
+	buff.WriteString(icoMagic)
+	_ = binary.Write(buff, binary.LittleEndian, uint16(len(images)))
+	offset := icoHeaderLen + len(images)*icoEntryLen
+	for _, img := range images {
+		buff.Write([]byte{byte(img.width), byte(img.height), 0, 0})
+		_ = binary.Write(buff, binary.LittleEndian, uint16(1))
+		_ = binary.Write(buff, binary.LittleEndian, uint16(img.bitCount))
+		_ = binary.Write(buff, binary.LittleEndian, uint32(len(img.data)))
+		_ = binary.Write(buff, binary.LittleEndian, uint32(offset))
+		offset += len(img.data)
+	}
+	for _, img := range images {
+		buff.Write(img.data)
+	}
+	return buff.Bytes()
+}
+
+// newDIB returns a bottom-up bitmap with the rows of pixels and the AND mask given top-down
+func newDIB(w, h, bitCount int, pal []color.RGBA, rows [][]byte, mask [][]byte) []byte {
+	buff := &bytes.Buffer{}
+	for _, v := range []uint32{dibInfoHeaderLen, uint32(w), uint32(h * 2)} {
+		_ = binary.Write(buff, binary.LittleEndian, v)
+	}
+	_ = binary.Write(buff, binary.LittleEndian, uint16(1))
+	_ = binary.Write(buff, binary.LittleEndian, uint16(bitCount))
+	for _, v := range []uint32{0, 0, 0, 0, uint32(len(pal)), 0} {
+		_ = binary.Write(buff, binary.LittleEndian, v)
+	}
+	for _, c := range pal {
+		buff.Write([]byte{c.B, c.G, c.R, 0})
+	}
+	for _, rs := range [][][]byte{rows, mask} {
+		for y := len(rs) - 1; y >= 0; y-- {
+			row := rs[y]
+			buff.Write(row)
+			buff.Write(make([]byte, (4-len(row)%4)%4))
+		}
+	}
+	return buff.Bytes()
+}
+
+func TestDecodeICO(t *testing.T) {
+	pngImg := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	pngImg.Set(1, 1, blue)
+	pngData := &bytes.Buffer{}
+	_ = png.Encode(pngData, pngImg)
+	// 2x2 32-bit bitmap with alpha: red, transparent / green, blue
+	bgra := newDIB(2, 2, 32, nil, [][]byte{{0, 0, 255, 255, 0, 0, 0, 0}, {0, 255, 0, 255, 255, 0, 0, 255}},
+		[][]byte{{0}, {0}})
+	// 2x2 24-bit bitmap: red, red / red, red with the top right pixel masked
+	bgr := newDIB(2, 2, 24, nil, [][]byte{{0, 0, 255, 0, 0, 255}, {0, 0, 255, 0, 0, 255}},
+		[][]byte{{0x40}, {0}})
+	// 3x1 1-bit bitmap: green, red, green with the last pixel masked
+	mono := newDIB(3, 1, 1, []color.RGBA{red, green}, [][]byte{{0xa0}}, [][]byte{{0x20}})
+	// 2x1 4-bit and 8-bit bitmaps: blue, red
+	nibbles := newDIB(2, 1, 4, []color.RGBA{red, blue}, [][]byte{{0x10}}, [][]byte{{0}})
+	octets := newDIB(2, 1, 8, []color.RGBA{red, blue}, [][]byte{{1, 0}}, [][]byte{{0}})
+
+	cases := []struct {
+		name     string
+		data     []byte
+		bounds   image.Rectangle
+		expected map[image.Point]color.RGBA
+	}{
+		{name: "PNG", data: newICO(icoImage{2, 2, 32, bgra}, icoImage{32, 32, 32, pngData.Bytes()}),
+			bounds: image.Rect(0, 0, 32, 32), expected: map[image.Point]color.RGBA{{1, 1}: blue, {0, 0}: {}}},
+		{name: "32Bit", data: newICO(icoImage{2, 2, 24, bgr}, icoImage{2, 2, 32, bgra}),
+			bounds: image.Rect(0, 0, 2, 2), expected: map[image.Point]color.RGBA{{0, 0}: red, {1, 0}: {}, {0, 1}: green, {1, 1}: blue}},
+		{name: "24Bit", data: newICO(icoImage{2, 2, 24, bgr}),
+			bounds: image.Rect(0, 0, 2, 2), expected: map[image.Point]color.RGBA{{0, 0}: red, {1, 0}: {}, {1, 1}: red}},
+		{name: "1Bit", data: newICO(icoImage{3, 1, 1, mono}),
+			bounds: image.Rect(0, 0, 3, 1), expected: map[image.Point]color.RGBA{{0, 0}: green, {1, 0}: red, {2, 0}: {}}},
+		{name: "4Bit", data: newICO(icoImage{2, 1, 4, nibbles}),
+			bounds: image.Rect(0, 0, 2, 1), expected: map[image.Point]color.RGBA{{0, 0}: blue, {1, 0}: red}},
+		{name: "8Bit", data: newICO(icoImage{2, 1, 8, octets}),
+			bounds: image.Rect(0, 0, 2, 1), expected: map[image.Point]color.RGBA{{0, 0}: blue, {1, 0}: red}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			img, f, err := NewBildProcessor().Decode(c.data)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, processor.ExtensionICO, f)
+			assert.Equal(t, c.bounds, img.Bounds())
+			for p, expected := range c.expected {
+				assert.Equal(t, expected, rgbaAt(img, p.X, p.Y), "pixel %v", p)
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(c.data))
+			assert.NoError(t, err)
+			assert.Equal(t, c.bounds.Dx(), cfg.Width)
+		})
+	}
+}
+
+func TestDecodeICO_WithInvalidData(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte(icoMagic),
+		[]byte(icoMagic + "\x00\x00"),
+		newICO(icoImage{2, 2, 32, []byte("short")}),
+		newICO(icoImage{2, 2, 16, newDIB(2, 2, 16, nil, [][]byte{{0, 0, 0, 0}, {0, 0, 0, 0}}, [][]byte{{0}, {0}})}),
+	} {
+		_, err := decodeICO(bytes.NewReader(data))
+		assert.Error(t, err)
+	}
+	data := newICO(icoImage{2, 2, 32, []byte("data")})
+	binary.LittleEndian.PutUint32(data[icoHeaderLen+12:], 1000)
+	_, err := decodeICO(bytes.NewReader(data))
+	assert.Error(t, err)
+}
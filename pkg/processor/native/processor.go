@@ -2,10 +2,13 @@ package native
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
+	"math"
+	"net/http"
 	"strings"
 
 	"github.com/anthonynsimon/bild/blur"
@@ -13,15 +16,30 @@ import (
 	"github.com/anthonynsimon/bild/effect"
 	"github.com/anthonynsimon/bild/transform"
 	"github.com/gojek/darkroom/pkg/processor"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
 )
 
 var resizeBoundOption = &transform.RotationOptions{
 	ResizeBounds: true,
 }
 
+const (
+	// fillBlurScale is the factor by which the blurred background of Fill is downscaled before it is blurred
+	fillBlurScale = 8
+	// fillBlurRadius is the radius of the blur of the downscaled background of Fill
+	fillBlurRadius = 4
+)
+
 // BildProcessor uses bild library to process images using native Golang image.Image interface
 type BildProcessor struct {
-	encoders *Encoders
+	encoders             *Encoders
+	font                 *opentype.Font
+	maxAnimationPixels   int64
+	allowSVGExternalRefs bool
+	faceDetector         *FaceDetector
+	faceCropFallback     processor.Point
 }
 
 // ProcessorOption represents builder function for BildProcessor
@@ -37,16 +55,132 @@ func (bp *BildProcessor) Crop(img image.Image, width, height int, point processo
 	}
 
 	w, h := getResizeWidthAndHeightForCrop(width, height, img.Bounds().Dx(), img.Bounds().Dy())
+	if a, ok := img.(*processor.Animation); ok {
+		// Every frame is cropped at the same offset, which is found on the first frame for the content aware crops
+		x0, y0, found := 0, 0, false
+		return mapFrames(a, func(frame image.Image) image.Image {
+			resized := transform.Resize(frame, w, h, transform.Linear)
+			if !found {
+				x0, y0 = bp.getCropOffset(resized, width, height, point)
+				found = true
+			}
+			return resized.SubImage(image.Rect(x0, y0, width+x0, height+y0))
+		})
+	}
 	img = transform.Resize(img, w, h, transform.Linear)
-	x0, y0 := getStartingPointForCrop(w, h, width, height, point)
+	x0, y0 := bp.getCropOffset(img, width, height, point)
 	rect := image.Rect(x0, y0, width+x0, height+y0)
 	img = (clone.AsRGBA(img)).SubImage(rect)
 
 	return img
 }
 
+// CropFocalPoint takes an input image, width, height and a FocalPoint and returns the cropped image, the region
+// with the aspect ratio of width x height is centered on the focal point as far as the bounds of the image allow
+func (bp *BildProcessor) CropFocalPoint(img image.Image, width, height int, fp processor.FocalPoint) image.Image {
+	if width == 0 || height == 0 {
+		return bp.Crop(img, width, height, processor.PointCenter)
+	}
+
+	b := img.Bounds()
+	cw, ch := getFocalPointCropSize(width, height, b.Dx(), b.Dy(), fp.Z)
+	x0, y0 := getStartingPointForFocalPoint(b.Dx(), b.Dy(), cw, ch, fp.X, fp.Y)
+	rect := image.Rect(x0, y0, cw+x0, ch+y0)
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			region := clone.AsShallowRGBA(frame).SubImage(rect.Add(frame.Bounds().Min))
+			return transform.Resize(region, width, height, transform.Linear)
+		})
+	}
+	region := clone.AsShallowRGBA(img).SubImage(rect.Add(b.Min))
+	return transform.Resize(region, width, height, transform.Linear)
+}
+
+// Extract takes an input image and a rectangle relative to its top left corner and returns the region of the image
+// in the rectangle, anchored at (0, 0)
+func (bp *BildProcessor) Extract(img image.Image, rect image.Rectangle) image.Image {
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			return bp.Extract(frame, rect)
+		})
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min.Add(img.Bounds().Min), draw.Src)
+	return dst
+}
+
+// Fill takes an input image, width, height and FillAttrs and returns the image resized to fit inside width and
+// height, maintaining its aspect ratio, and aligned to the Point of a width x height background
+func (bp *BildProcessor) Fill(img image.Image, width, height int, fill *processor.FillAttrs) image.Image {
+	if width == 0 || height == 0 {
+		if width == 0 && height == 0 {
+			return img
+		}
+		return bp.Resize(img, width, height)
+	}
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			return bp.Fill(frame, width, height, fill)
+		})
+	}
+
+	var dst *image.RGBA
+	if fill.Blur {
+		// The background is blurred at a fraction of its size, which is much faster and looks the same once scaled up
+		bw, bh := clampInt(width/fillBlurScale, 1, width), clampInt(height/fillBlurScale, 1, height)
+		background := blur.Gaussian(bp.Crop(img, bw, bh, processor.PointCenter), fillBlurRadius)
+		if isOpaque(img) {
+			// The blur rounds the alpha of opaque pixels down, which would keep the image from being encoded as jpeg
+			for i := 3; i < len(background.Pix); i += 4 {
+				background.Pix[i] = 0xff
+			}
+		}
+		dst = transform.Resize(background, width, height, transform.Linear)
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, width, height))
+		if fill.Color != nil {
+			draw.Draw(dst, dst.Bounds(), image.NewUniform(fill.Color), image.Point{}, draw.Src)
+		}
+	}
+
+	w, h := getResizeWidthAndHeight(width, height, img.Bounds().Dx(), img.Bounds().Dy())
+	w, h = clampInt(w, 1, width), clampInt(h, 1, height)
+	if w != img.Bounds().Dx() || h != img.Bounds().Dy() {
+		img = transform.Resize(img, w, h, transform.Linear)
+	}
+	x, y := getStartingPointForCrop(width, height, w, h, fill.Point)
+	draw.Draw(dst, image.Rect(x, y, x+w, y+h), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+// getCropOffset returns the starting point of the width x height crop of the resized image img, the content of the
+// image is analysed to find it for processor.PointEntropy, processor.PointAttention and processor.PointFaces.
+// The crop falls back to the Point set by WithFaceCropFallback if no face is detected.
+func (bp *BildProcessor) getCropOffset(img image.Image, width, height int, point processor.Point) (int, int) {
+	switch point {
+	case processor.PointEntropy, processor.PointAttention:
+		return getSmartCropOffset(img, width, height, point)
+	case processor.PointFaces:
+		if bp.faceDetector != nil {
+			if x, y, ok := getFaceCropOffset(bp.faceDetector, img, width, height); ok {
+				return x, y
+			}
+		}
+		if bp.faceCropFallback == processor.PointFaces {
+			return bp.getCropOffset(img, width, height, processor.PointCenter)
+		}
+		return bp.getCropOffset(img, width, height, bp.faceCropFallback)
+	}
+	return getStartingPointForCrop(img.Bounds().Dx(), img.Bounds().Dy(), width, height, point)
+}
+
 // Resize takes an input image, width and height and returns the re-sized image
 func (bp *BildProcessor) Resize(img image.Image, width, height int) image.Image {
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			return bp.Resize(frame, width, height)
+		})
+	}
 
 	initW := img.Bounds().Dx()
 	initH := img.Bounds().Dy()
@@ -62,17 +196,32 @@ func (bp *BildProcessor) Resize(img image.Image, width, height int) image.Image
 // Scale takes an input image, width and height and returns the re-sized
 // image without maintaining the original aspect ratio
 func (bp *BildProcessor) Scale(img image.Image, width, height int) image.Image {
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			return bp.Scale(frame, width, height)
+		})
+	}
 	return transform.Resize(img, width, height, transform.Linear)
 }
 
 // GrayScale takes an input image and returns the grayscaled image
 func (bp *BildProcessor) GrayScale(img image.Image) image.Image {
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			return bp.GrayScale(frame)
+		})
+	}
 	// Rec. 601 Luma formula (https://en.wikipedia.org/wiki/Luma_%28video%29#Rec._601_luma_versus_Rec._709_luma_coefficients)
 	return effect.GrayscaleWithWeights(img, 0.299, 0.587, 0.114)
 }
 
 // Blur takes an input image and blur radius and returns the Gausian blurred image
 func (bp *BildProcessor) Blur(img image.Image, radius float64) image.Image {
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			return bp.Blur(frame, radius)
+		})
+	}
 	return blur.Gaussian(img, radius)
 }
 
@@ -80,6 +229,11 @@ func (bp *BildProcessor) Blur(img image.Image, radius float64) image.Image {
 // is determined by the specified mode - 'v' for a vertical flip, 'h' for a
 // horizontal flip and 'vh'(or 'hv') for both.
 func (bp *BildProcessor) Flip(img image.Image, mode string) image.Image {
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			return bp.Flip(frame, mode)
+		})
+	}
 	mode = strings.ToLower(mode)
 	for _, op := range mode {
 		switch op {
@@ -95,26 +249,69 @@ func (bp *BildProcessor) Flip(img image.Image, mode string) image.Image {
 // Rotate takes an input image and returns a image rotated by the specified degrees.
 // The rotation is applied clockwise, and fractional angles are also supported.
 func (bp *BildProcessor) Rotate(img image.Image, angle float64) image.Image {
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			return bp.Rotate(frame, angle)
+		})
+	}
 	return transform.Rotate(img, angle, nil)
 }
 
-// Decode takes a byte array and returns the decoded image, format, or the error
+// Decode takes a byte array and returns the decoded image, format, or the error.
+// Animated GIF images are decoded with all their frames as a processor.Animation, only the first page of
+// multi-page TIFF images is decoded and SVG images are rasterized at their own size.
+// A processor.UnsupportedFormatError is returned for the other formats.
 func (bp *BildProcessor) Decode(data []byte) (image.Image, string, error) {
+	switch processor.DetectFormat(data) {
+	case processor.ExtensionGIF:
+		img, err := decodeGIF(data, bp.maxAnimationPixels)
+		if err != nil {
+			return nil, "", err
+		}
+		return img, processor.ExtensionGIF, nil
+	case processor.ExtensionSVG:
+		return bp.Rasterize(data, 0, 0)
+	}
 	img, f, err := image.Decode(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		f = processor.DetectFormat(data)
+		if f == "" {
+			f, _, _ = strings.Cut(http.DetectContentType(data), ";")
+		}
+		return nil, "", &processor.UnsupportedFormatError{Format: f}
+	}
 	return img, f, err
 }
 
 // Encode takes an image and the preferred format (extension) of the output
-// Current supported format are "png", "jpg" and "jpeg"
+// Current supported format are "png", "jpg", "jpeg", "webp", "gif" and "avif"
 func (bp *BildProcessor) Encode(img image.Image, fmt string) ([]byte, error) {
-	enc := bp.encoders.GetEncoder(img, fmt)
+	return bp.EncodeWithQuality(img, fmt, 0)
+}
+
+// EncodeWithQuality takes an image, the preferred format (extension) of the output and a quality from 1 to 100,
+// which applies to the "jpg", "jpeg", "webp" and "avif" formats and to the "png" images encoded as jpeg.
+// The default quality of the format is used if quality is 0.
+func (bp *BildProcessor) EncodeWithQuality(img image.Image, fmt string, quality int) ([]byte, error) {
+	enc := bp.encoders.GetEncoderWithQuality(img, fmt, quality)
 	data, err := enc.Encode(img)
 	return data, err
 }
 
+// CanEncode returns true if the BildProcessor has an encoder for the format, the avif format
+// is only supported if darkroom is built with the avif build tag
+func (bp *BildProcessor) CanEncode(format string) bool {
+	return bp.encoders.CanEncode(format)
+}
+
 // FixOrientation takes an image and it's EXIF orientation
 // To get the orientation of the image see GetOrientation (exif.go)
 func (bp *BildProcessor) FixOrientation(img image.Image, orientation int) image.Image {
+	if a, ok := img.(*processor.Animation); ok {
+		return mapFrames(a, func(frame image.Image) image.Image {
+			return bp.FixOrientation(frame, orientation)
+		})
+	}
 	switch orientation {
 	case 2:
 		return transform.FlipH(img)
@@ -149,20 +346,25 @@ func (bp *BildProcessor) transformOverlay(i, w, h int, oa *processor.OverlayAttr
 	overlayImg, _, err := bp.Decode(oa.Img)
 	if err != nil {
 		*c <- overlayResult{index: i, err: err}
+		return
 	}
 	if overlayImg == nil {
 		*c <- overlayResult{index: i, err: fmt.Errorf("overlay byte cannot be decoded into image")}
+		return
 	}
 
-	ratio := float64(overlayImg.Bounds().Dy()) / float64(overlayImg.Bounds().Dx())
-	dWidth := float64(w) * (oa.WidthPercentage / 100.0)
-
-	// Resizing overlay image according to base image
-	overlayImg = transform.Resize(overlayImg, int(dWidth), int(dWidth*ratio), transform.Linear)
+	// Resizing overlay image according to base image while maintaining its aspect ratio,
+	// it is kept as is if no percentage is given
+	rw := int(float64(w) * oa.WidthPercentage / 100.0)
+	rh := int(float64(h) * oa.HeightPercentage / 100.0)
+	if rw > 0 || rh > 0 {
+		dw, dh := getResizeWidthAndHeight(rw, rh, overlayImg.Bounds().Dx(), overlayImg.Bounds().Dy())
+		overlayImg = transform.Resize(overlayImg, dw, dh, transform.Linear)
+	}
 
 	// Anchor point for overlaying
 	x, y := getStartingPointForCrop(w, h, overlayImg.Bounds().Dx(), overlayImg.Bounds().Dy(), oa.Point)
-	offset := image.Pt(x, y)
+	offset := image.Pt(x+oa.OffsetX, y+oa.OffsetY)
 	*c <- overlayResult{
 		overlayImg: overlayImg,
 		offset:     offset,
@@ -171,72 +373,178 @@ func (bp *BildProcessor) transformOverlay(i, w, h int, oa *processor.OverlayAttr
 	}
 }
 
-// Watermark takes an input byte array, overlay byte array and opacity value
-// and returns the watermarked image bytes or error
-func (bp *BildProcessor) Watermark(base []byte, overlay []byte, opacity uint8) ([]byte, error) {
-	baseImg, f, err := bp.Decode(base)
-	if err != nil {
-		return nil, err
-	}
-	if f != processor.ExtensionPNG {
-		baseImg = clone.AsRGBA(baseImg)
-	}
+// drawOverlay draws the transformed overlay on top of the base image with the given opacity
+func drawOverlay(baseImg draw.Image, cr overlayResult, opacity uint8) {
+	// Mask image (that is just a solid light gray image)
+	mask := image.NewUniform(color.Alpha{A: opacity})
+	r := cr.overlayImg.Bounds().Add(cr.offset).Add(baseImg.Bounds().Min)
+	draw.DrawMask(baseImg, r, cr.overlayImg, cr.overlayImg.Bounds().Min, mask, image.Point{}, draw.Over)
+}
 
-	oa := processor.OverlayAttrs{
-		Img:              overlay,
-		Point:            processor.PointCenter,
-		WidthPercentage:  50.0,
-		HeightPercentage: 50.0,
-	}
-	c := make(chan overlayResult)
-	w := baseImg.Bounds().Dx()
-	h := baseImg.Bounds().Dy()
-	go bp.transformOverlay(0, w, h, &oa, &c)
+// Watermark takes an input image, the watermark as OverlayAttrs and opacity value
+// and returns the watermarked image or error. The input image is not modified.
+func (bp *BildProcessor) Watermark(img image.Image, overlay *processor.OverlayAttrs, opacity uint8) (image.Image, error) {
+	c := make(chan overlayResult, 1)
+	bp.transformOverlay(0, img.Bounds().Dx(), img.Bounds().Dy(), overlay, &c)
 	cr := <-c
-
 	if cr.err != nil {
 		return nil, cr.err
 	}
 
-	// Mask image (that is just a solid light gray image)
-	mask := image.NewUniform(color.Alpha{A: opacity})
+	return drawOnFrames(img, func(baseImg *image.RGBA) {
+		drawOverlay(baseImg, cr, opacity)
+	}), nil
+}
 
-	// Performing overlay
-	draw.DrawMask(baseImg.(draw.Image), cr.overlayImg.Bounds().Add(cr.offset), cr.overlayImg, image.ZP, mask, image.ZP, draw.Over)
+// Overlay takes a base image and array of overlays and returns the image with the overlays drawn on top of it
+// in the order of the array or error. The overlays are transformed in parallel. The input image is not modified.
+func (bp *BildProcessor) Overlay(img image.Image, overlays []*processor.OverlayAttrs) (image.Image, error) {
+	if len(overlays) == 0 {
+		return img, nil
+	}
 
-	return bp.Encode(baseImg, f)
+	c := make(chan overlayResult, len(overlays))
+	w := img.Bounds().Dx()
+	h := img.Bounds().Dy()
+	for i, overlay := range overlays {
+		go bp.transformOverlay(i, w, h, overlay, &c)
+	}
+	results := make([]overlayResult, len(overlays))
+	for range overlays {
+		cr := <-c
+		results[cr.index] = cr
+	}
+
+	for _, cr := range results {
+		if cr.err != nil {
+			return nil, cr.err
+		}
+	}
+	return drawOnFrames(img, func(baseImg *image.RGBA) {
+		for i, cr := range results {
+			opacity := overlays[i].Opacity
+			if opacity == 0 {
+				opacity = math.MaxUint8
+			}
+			// Performing overlay
+			drawOverlay(baseImg, cr, opacity)
+		}
+	}), nil
 }
 
-// Overlay takes a base image and array of overlay images and returns the final overlayed image bytes or error
-func (bp *BildProcessor) Overlay(base []byte, overlays []*processor.OverlayAttrs) ([]byte, error) {
-	if len(overlays) == 0 {
-		return base, nil
+// MarkFaces takes an input image and returns a copy of it with the boxes of the faces detected by the FaceDetector
+// drawn on it, the image is returned as it is if the face detection is disabled with WithFaceDetector(nil)
+func (bp *BildProcessor) MarkFaces(img image.Image) image.Image {
+	if bp.faceDetector == nil {
+		return img
 	}
+	return drawOnFrames(img, func(dst *image.RGBA) {
+		drawFaceBoxes(dst, bp.faceDetector.Detect(dst))
+	})
+}
 
-	baseImg, f, err := bp.Decode(base)
+// Text takes an input image and TextAttrs and returns the image with the text drawn on top of it or error.
+// The text is rendered with the font set by WithFont or the bundled Go Regular font. The input image is not modified.
+func (bp *BildProcessor) Text(img image.Image, ta *processor.TextAttrs) (image.Image, error) {
+	if ta.Text == "" {
+		return img, nil
+	}
+	f := bp.font
+	if f == nil {
+		f = getDefaultFont()
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: ta.Size, DPI: 72, Hinting: font.HintingFull})
 	if err != nil {
 		return nil, err
 	}
-	if f != processor.ExtensionPNG {
-		baseImg = clone.AsRGBA(baseImg)
+	defer face.Close()
+
+	// Rendering the text once, it is then drawn like an overlay
+	m := face.Metrics()
+	d := &font.Drawer{Face: face}
+	w, h := d.MeasureString(ta.Text).Ceil(), (m.Ascent + m.Descent).Ceil()
+	if w <= 0 || h <= 0 {
+		return img, nil
+	}
+	// Only the part of the text which is visible on the base image is rendered, so that a long text or a large font
+	// never allocates more than the base image. The tiles are all clipped like the first one at the top left corner.
+	bw, bh := img.Bounds().Dx(), img.Bounds().Dy()
+	offset := image.Point{}
+	if !ta.Tile {
+		x, y := getStartingPointForCrop(bw, bh, w, h, ta.Point)
+		offset.X, offset.Y = padPoint(x, y, ta.Padding, ta.Point)
 	}
+	visible := image.Rect(0, 0, w, h).Add(offset).Intersect(image.Rect(0, 0, bw, bh))
+	if visible.Empty() {
+		return img, nil
+	}
+	clip := visible.Sub(offset)
+	c := ta.Color
+	if c == nil {
+		c = color.Black
+	}
+	label := image.NewRGBA(image.Rect(0, 0, clip.Dx(), clip.Dy()))
+	d.Dst = label
+	d.Src = image.NewUniform(c)
+	d.Dot = fixed.Point26_6{X: fixed.I(-clip.Min.X), Y: m.Ascent - fixed.I(clip.Min.Y)}
+	drawString(d, ta.Text)
+
+	opacity := ta.Opacity
+	if opacity == 0 {
+		opacity = math.MaxUint8
+	}
+	return drawOnFrames(img, func(baseImg *image.RGBA) {
+		if !ta.Tile {
+			drawOverlay(baseImg, overlayResult{overlayImg: label, offset: visible.Min}, opacity)
+			return
+		}
+		for y := ta.Padding; y < bh; y += h + ta.Padding {
+			for x := ta.Padding; x < bw; x += w + ta.Padding {
+				drawOverlay(baseImg, overlayResult{overlayImg: label, offset: image.Pt(x, y)}, opacity)
+			}
+		}
+	}), nil
+}
 
-	c := make(chan overlayResult, len(overlays))
-	w := baseImg.Bounds().Dx()
-	h := baseImg.Bounds().Dy()
-	for i, overlay := range overlays {
-		go bp.transformOverlay(i, w, h, overlay, &c)
+// WithFont is a builder function to set the font which is used by BildProcessor to render text
+func WithFont(f *opentype.Font) ProcessorOption {
+	return func(bp *BildProcessor) {
+		bp.font = f
 	}
+}
 
-	for i := 0; i < len(overlays); i++ {
-		cr := <-c
-		if cr.err == nil {
-			// Performing overlay
-			draw.DrawMask(baseImg.(draw.Image), cr.overlayImg.Bounds().Add(cr.offset), cr.overlayImg, image.ZP, nil, image.ZP, draw.Over)
-		}
+// WithMaxAnimationPixels is a builder function to set the limit of the number of frames times the number of
+// pixels of the animations decoded by BildProcessor, larger animations fail to decode with ErrAnimationTooLarge
+func WithMaxAnimationPixels(maxPixels int64) ProcessorOption {
+	return func(bp *BildProcessor) {
+		bp.maxAnimationPixels = maxPixels
 	}
+}
 
-	return bp.Encode(baseImg, processor.ExtensionPNG)
+// WithSVGExternalReferences is a builder function to allow the SVG images decoded by BildProcessor to reference
+// external resources, which are rejected with ErrSVGExternalReference by default. The referenced resources are
+// never loaded, the elements which use them are rasterized without them.
+func WithSVGExternalReferences(allow bool) ProcessorOption {
+	return func(bp *BildProcessor) {
+		bp.allowSVGExternalRefs = allow
+	}
+}
+
+// WithFaceDetector is a builder function to set the FaceDetector which is used by BildProcessor to crop images
+// around their faces with processor.PointFaces, instead of the one of the bundled facefinder cascade of pigo.
+// The face detection is disabled if it is nil.
+func WithFaceDetector(detector *FaceDetector) ProcessorOption {
+	return func(bp *BildProcessor) {
+		bp.faceDetector = detector
+	}
+}
+
+// WithFaceCropFallback is a builder function to set the Point of the crops with processor.PointFaces of the images
+// in which no face is detected, it is processor.PointCenter by default
+func WithFaceCropFallback(point processor.Point) ProcessorOption {
+	return func(bp *BildProcessor) {
+		bp.faceCropFallback = point
+	}
 }
 
 // WithEncoders is a builder function to set custom Encoders for BildProcessor
@@ -248,7 +556,12 @@ func WithEncoders(encoders *Encoders) ProcessorOption {
 
 // NewBildProcessor creates a new BildProcessor, if called without parameters encoders will be default
 func NewBildProcessor(opts ...ProcessorOption) *BildProcessor {
-	bp := &BildProcessor{encoders: NewEncoders()}
+	bp := &BildProcessor{
+		encoders:           NewEncoders(),
+		maxAnimationPixels: DefaultMaxAnimationPixels,
+		faceDetector:       getDefaultFaceDetector(),
+		faceCropFallback:   processor.PointCenter,
+	}
 	for _, opt := range opts {
 		opt(bp)
 	}
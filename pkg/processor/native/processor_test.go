@@ -3,12 +3,17 @@ package native
 import (
 	"bytes"
 	"image"
+	"image/color"
+	"image/draw"
 	"io/ioutil"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/gojek/darkroom/pkg/processor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/image/font/gofont/goregular"
 )
 
 type BildProcessorSuite struct {
@@ -102,6 +107,124 @@ func (s *BildProcessorSuite) TestBildProcessor_Crop() {
 	}
 }
 
+func (s *BildProcessorSuite) TestBildProcessor_CropFocalPoint() {
+	// The left half of the image is red and the right half is blue
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	draw.Draw(img, image.Rect(0, 0, 200, 200), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(200, 0, 400, 200), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	cases := []struct {
+		fp       processor.FocalPoint
+		expected color.RGBA
+	}{
+		{fp: processor.FocalPoint{X: 0.1, Y: 0.5, Z: 1}, expected: red},
+		{fp: processor.FocalPoint{X: 0.9, Y: 0.5, Z: 1}, expected: blue},
+		{fp: processor.FocalPoint{X: 0.3, Y: 0, Z: 2}, expected: red},
+		{fp: processor.FocalPoint{X: 0.7, Y: 1, Z: 2}, expected: blue},
+	}
+	for _, c := range cases {
+		out := s.processor.CropFocalPoint(img, 100, 100, c.fp)
+
+		assert.Equal(s.T(), 100, out.Bounds().Dx())
+		assert.Equal(s.T(), 100, out.Bounds().Dy())
+		for _, p := range []image.Point{{0, 0}, {50, 50}, {99, 99}} {
+			assert.Equal(s.T(), c.expected, out.At(p.X, p.Y), "%+v at %v", c.fp, p)
+		}
+	}
+
+	out := s.processor.CropFocalPoint(img, 200, 0, processor.FocalPoint{X: 0.1, Y: 0.5, Z: 1})
+	assert.Equal(s.T(), 200, out.Bounds().Dx())
+	assert.Equal(s.T(), 100, out.Bounds().Dy())
+
+	a := &processor.Animation{Frames: []image.Image{img, img}, Delays: []int{10, 10}}
+	out = s.processor.CropFocalPoint(a, 100, 100, processor.FocalPoint{X: 0.9, Y: 0.5, Z: 1})
+	assert.Len(s.T(), out.(*processor.Animation).Frames, 2)
+	for _, frame := range out.(*processor.Animation).Frames {
+		assert.Equal(s.T(), image.Rect(0, 0, 100, 100), frame.Bounds())
+		assert.Equal(s.T(), blue, frame.At(50, 50))
+	}
+}
+
+func (s *BildProcessorSuite) TestBildProcessor_Extract() {
+	// The left half of the image is red and the right half is blue
+	img := image.NewRGBA(image.Rect(10, 10, 410, 210))
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	draw.Draw(img, image.Rect(10, 10, 210, 210), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(210, 10, 410, 210), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	out := s.processor.Extract(img, image.Rect(150, 50, 250, 100))
+
+	assert.Equal(s.T(), image.Rect(0, 0, 100, 50), out.Bounds())
+	assert.Equal(s.T(), red, out.At(49, 25))
+	assert.Equal(s.T(), blue, out.At(50, 25))
+
+	a := &processor.Animation{Frames: []image.Image{img, img}, Delays: []int{10, 10}}
+	out = s.processor.Extract(a, image.Rect(300, 0, 400, 200))
+	assert.Len(s.T(), out.(*processor.Animation).Frames, 2)
+	for _, frame := range out.(*processor.Animation).Frames {
+		assert.Equal(s.T(), image.Rect(0, 0, 100, 200), frame.Bounds())
+		assert.Equal(s.T(), blue, frame.At(50, 100))
+	}
+}
+
+func (s *BildProcessorSuite) TestBildProcessor_Fill() {
+	red := color.RGBA{R: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	draw.Draw(img, img.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	bg := color.NRGBA{B: 255, A: 255}
+
+	cases := []struct {
+		point  processor.Point
+		inside image.Rectangle
+	}{
+		{point: processor.PointCenter, inside: image.Rect(0, 50, 200, 150)},
+		{point: processor.PointTop, inside: image.Rect(0, 0, 200, 100)},
+		{point: processor.PointBottomRight, inside: image.Rect(0, 100, 200, 200)},
+	}
+	for _, c := range cases {
+		out := s.processor.Fill(img, 200, 200, &processor.FillAttrs{Color: bg, Point: c.point})
+
+		assert.Equal(s.T(), image.Rect(0, 0, 200, 200), out.Bounds())
+		for _, p := range []image.Point{{0, 0}, {100, 100}, {199, 199}, {100, 25}, {100, 175}} {
+			expected := color.Color(color.RGBA{B: 255, A: 255})
+			if p.In(c.inside) {
+				expected = red
+			}
+			assert.Equal(s.T(), expected, out.At(p.X, p.Y), "%v at %v", c.point, p)
+		}
+	}
+
+	// The background is transparent if no color is set, and the image is drawn on top of a translucent one
+	out := s.processor.Fill(img, 100, 200, &processor.FillAttrs{})
+	assert.Equal(s.T(), color.RGBA{}, out.At(50, 10))
+	assert.Equal(s.T(), red, out.At(50, 100))
+	out = s.processor.Fill(img, 100, 200, &processor.FillAttrs{Color: color.NRGBA{B: 255, A: 128}})
+	assert.Equal(s.T(), color.RGBA{B: 128, A: 128}, out.At(50, 10))
+
+	// The blurred background covers the whole size with the colors of the image
+	out = s.processor.Fill(img, 200, 300, &processor.FillAttrs{Blur: true})
+	assert.Equal(s.T(), image.Rect(0, 0, 200, 300), out.Bounds())
+	assert.True(s.T(), isOpaque(out))
+	for _, p := range []image.Point{{0, 0}, {199, 299}, {100, 150}} {
+		c := out.At(p.X, p.Y).(color.RGBA)
+		assert.InDelta(s.T(), 255, c.R, 1, "at %v", p)
+		assert.Equal(s.T(), []uint8{0, 0}, []uint8{c.G, c.B}, "at %v", p)
+	}
+
+	out = s.processor.Fill(img, 200, 0, &processor.FillAttrs{Color: bg})
+	assert.Equal(s.T(), image.Rect(0, 0, 200, 100), out.Bounds())
+	assert.Same(s.T(), img, s.processor.Fill(img, 0, 0, &processor.FillAttrs{Color: bg}))
+
+	a := &processor.Animation{Frames: []image.Image{img, img}, Delays: []int{10, 10}}
+	out = s.processor.Fill(a, 200, 200, &processor.FillAttrs{Color: bg})
+	assert.Len(s.T(), out.(*processor.Animation).Frames, 2)
+	for _, frame := range out.(*processor.Animation).Frames {
+		assert.Equal(s.T(), image.Rect(0, 0, 200, 200), frame.Bounds())
+		assert.Equal(s.T(), red, frame.At(100, 100))
+	}
+}
+
 func (s *BildProcessorSuite) TestBildProcessor_Grayscale() {
 	var actual, expected []byte
 	var err error
@@ -215,25 +338,143 @@ func (s *BildProcessorSuite) TestBildProcessor_Rotate() {
 }
 
 func (s *BildProcessorSuite) TestBildProcessor_Watermark() {
-	output, err := s.processor.Watermark(s.badData, s.watermarkData, 255)
+	overlay := func(data []byte) *processor.OverlayAttrs {
+		return &processor.OverlayAttrs{Img: data, Point: processor.PointCenter, WidthPercentage: 50.0}
+	}
+	output, err := s.processor.Watermark(s.srcImage, overlay(s.badData), 255)
 	assert.NotNil(s.T(), err)
 	assert.Nil(s.T(), output)
 
-	output, err = s.processor.Watermark(s.srcPNGData, s.badData, 255)
+	output, err = s.processor.Watermark(s.srcImage, overlay(s.watermarkData), 200)
+	assert.Nil(s.T(), err)
+	encoded, _ := s.processor.Encode(output, "png")
+	expectedRes, _ := ioutil.ReadFile("_testdata/test_watermark_result.png")
+	assert.Equal(s.T(), expectedRes, encoded)
+
+	jpgImage, _, _ := s.processor.Decode(s.srcJPGData)
+	output, err = s.processor.Watermark(jpgImage, overlay(s.watermarkData), 200)
+	assert.Nil(s.T(), err)
+	encoded, _ = s.processor.Encode(output, "jpeg")
+	expectedRes, _ = ioutil.ReadFile("_testdata/test_watermark_result.jpg")
+	assert.Equal(s.T(), expectedRes, encoded)
+}
+
+func (s *BildProcessorSuite) TestBildProcessor_WatermarkWithPointAndNaturalSize() {
+	markImage, _, _ := s.processor.Decode(s.watermarkData)
+	mw, mh := markImage.Bounds().Dx(), markImage.Bounds().Dy()
+	base := s.processor.Crop(s.srcImage, 400, 200, processor.PointCenter)
+	assert.NotEqual(s.T(), image.Point{}, base.Bounds().Min)
+
+	output, err := s.processor.Watermark(base, &processor.OverlayAttrs{
+		Img:   s.watermarkData,
+		Point: processor.PointBottomRight,
+	}, 255)
+
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), base.Bounds(), output.Bounds())
+	min := output.Bounds().Min
+	// The pixels outside of the watermark are left untouched
+	rgba := color.RGBAModel.Convert
+	assert.Equal(s.T(), rgba(base.At(min.X, min.Y)), rgba(output.At(min.X, min.Y)))
+	x, y := min.X+400-mw+mw/2, min.Y+200-mh+mh/2
+	assert.Equal(s.T(), rgba(markImage.At(mw/2, mh/2)), rgba(output.At(x, y)))
+}
+
+func (s *BildProcessorSuite) TestBildProcessor_Text() {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	base := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	draw.Draw(base, base.Bounds(), image.NewUniform(white), image.Point{}, draw.Src)
+	// inked counts the pixels of the rectangle which are not white anymore
+	inked := func(img image.Image, r image.Rectangle) int {
+		n := 0
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if color.RGBAModel.Convert(img.At(x, y)) != white {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	out, err := s.processor.Text(base, &processor.TextAttrs{Text: ""})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), base, out)
+
+	out, err = s.processor.Text(base, &processor.TextAttrs{
+		Text:    "SAMPLE",
+		Size:    20,
+		Color:   color.RGBA{R: 255, A: 255},
+		Point:   processor.PointBottomRight,
+		Padding: 10,
+	})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, inked(base, base.Bounds()), "base image should not be modified")
+	assert.Greater(s.T(), inked(out, image.Rect(100, 50, 190, 90)), 0)
+	assert.Equal(s.T(), 0, inked(out, image.Rect(0, 0, 200, 50)))
+	assert.Equal(s.T(), 0, inked(out, image.Rect(190, 0, 200, 100)))
+	assert.Equal(s.T(), 0, inked(out, image.Rect(0, 90, 200, 100)))
+
+	out, err = s.processor.Text(base, &processor.TextAttrs{Text: "SAMPLE", Size: 12, Padding: 5, Tile: true})
+	assert.Nil(s.T(), err)
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 100, 50), image.Rect(100, 0, 200, 50),
+		image.Rect(0, 50, 100, 100), image.Rect(100, 50, 200, 100),
+	} {
+		assert.Greater(s.T(), inked(out, r), 0, "text should be tiled over %v", r)
+	}
+}
+
+func (s *BildProcessorSuite) TestBildProcessor_TextLargerThanImage() {
+	base := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for _, tile := range []bool{false, true} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		out, err := s.processor.Text(base, &processor.TextAttrs{
+			Text:  strings.Repeat("SAMPLE ", 100),
+			Size:  1000,
+			Point: processor.PointCenter,
+			Tile:  tile,
+		})
+		runtime.ReadMemStats(&after)
+
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), base.Bounds(), out.Bounds())
+		// The whole label would take gigabytes, only the part of it on top of the image is allocated
+		assert.Less(s.T(), after.TotalAlloc-before.TotalAlloc, uint64(10<<20), "tile: %v", tile)
+	}
+
+	// The clipped label is drawn at the same place as the whole of it
+	out, err := s.processor.Text(base, &processor.TextAttrs{Text: "SAMPLE", Size: 100, Point: processor.PointRight})
+	assert.Nil(s.T(), err)
+	wide, err := s.processor.Text(image.NewRGBA(image.Rect(0, 0, 2000, 100)), &processor.TextAttrs{
+		Text: "SAMPLE", Size: 100, Point: processor.PointRight,
+	})
+	assert.Nil(s.T(), err)
+	mismatches := 0
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			if wide.At(1800+x, y) != out.At(x, y) {
+				mismatches++
+			}
+		}
+	}
+	assert.Equal(s.T(), 0, mismatches)
+}
+
+func (s *BildProcessorSuite) TestBildProcessor_TextWithFont() {
+	_, err := ParseFont(s.badData)
 	assert.NotNil(s.T(), err)
-	assert.Nil(s.T(), output)
 
-	output, err = s.processor.Watermark(s.srcPNGData, s.watermarkData, 200)
-	expectedRes, _ := ioutil.ReadFile("_testdata/test_watermark_result.png")
-	assert.NotNil(s.T(), output)
+	f, err := ParseFont(goregular.TTF)
 	assert.Nil(s.T(), err)
-	assert.Equal(s.T(), expectedRes, output)
+	bp := NewBildProcessor(WithFont(f))
+	assert.Equal(s.T(), f, bp.font)
 
-	output, err = s.processor.Watermark(s.srcJPGData, s.watermarkData, 200)
-	expectedRes, _ = ioutil.ReadFile("_testdata/test_watermark_result.jpg")
-	assert.NotNil(s.T(), output)
+	out, err := bp.Text(s.srcImage, &processor.TextAttrs{Text: "SAMPLE", Size: 20, Opacity: 128})
 	assert.Nil(s.T(), err)
-	assert.Equal(s.T(), expectedRes, output)
+	assert.Equal(s.T(), s.srcImage.Bounds(), out.Bounds())
+	assert.NotEqual(s.T(), s.srcImage, out)
 }
 
 func (s *BildProcessorSuite) TestBildProcessor_FixOrientation() {
@@ -279,19 +520,20 @@ func (s *BildProcessorSuite) TestBildProcessor_Decode_GivenWebPImageShouldBeAble
 }
 
 func (s *BildProcessorSuite) TestBildProcessor_Overlay() {
-	baseImg, _ := ioutil.ReadFile("./_testdata/test.jpg")
+	baseData, _ := ioutil.ReadFile("./_testdata/test.jpg")
+	baseImg, _, _ := s.processor.Decode(baseData)
 	overlay, _ := ioutil.ReadFile("./_testdata/overlay.png")
 
+	o, err := s.processor.Overlay(baseImg, []*processor.OverlayAttrs{})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), baseImg, o)
+
 	type testCase struct {
 		expected string
 		overlays []*processor.OverlayAttrs
 	}
 
 	testCases := []testCase{
-		{
-			expected: "./_testdata/test.jpg",
-			overlays: []*processor.OverlayAttrs{},
-		},
 		{
 			expected: "./_testdata/overlay/overlay_1.png",
 			overlays: []*processor.OverlayAttrs{
@@ -412,8 +654,55 @@ func (s *BildProcessorSuite) TestBildProcessor_Overlay() {
 
 	for _, tc := range testCases {
 		o, err := s.processor.Overlay(baseImg, tc.overlays)
-		e, _ := ioutil.ReadFile(tc.expected)
-		assert.Equal(s.T(), e, o)
 		assert.Nil(s.T(), err)
+		encoded, _ := s.processor.Encode(o, processor.ExtensionPNG)
+		e, _ := ioutil.ReadFile(tc.expected)
+		assert.Equal(s.T(), e, encoded)
+	}
+
+	o, err = s.processor.Overlay(baseImg, []*processor.OverlayAttrs{{Img: overlay}, {Img: s.badData}})
+	assert.NotNil(s.T(), err)
+	assert.Nil(s.T(), o)
+}
+
+func (s *BildProcessorSuite) TestBildProcessor_OverlayLayers() {
+	solid := func(w, h int, c color.Color) []byte {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		data, _ := s.processor.Encode(img, processor.ExtensionPNG)
+		return data
+	}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	base := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	draw.Draw(base, base.Bounds(), image.NewUniform(white), image.Point{}, draw.Src)
+	rgba := color.RGBAModel.Convert
+
+	out, err := s.processor.Overlay(base, []*processor.OverlayAttrs{
+		// Bound by the height, 40% of 50 is 20, the width follows the aspect ratio
+		{Img: solid(20, 10, red), Point: processor.PointTopLeft, WidthPercentage: 50, HeightPercentage: 40},
+		// Drawn on top of the previous layer, moved by the offsets
+		{Img: solid(10, 10, blue), Point: processor.PointTopLeft, OffsetX: 30, OffsetY: 5},
+		{Img: solid(10, 10, blue), Point: processor.PointBottomRight, Opacity: 128, OffsetX: -5},
+	})
+
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), white, rgba(base.At(0, 0)), "base image should not be modified")
+	// The resized layers may be off by one because of the interpolation
+	assertColor := func(expected color.Color, x, y int) {
+		er, eg, eb, _ := expected.RGBA()
+		ar, ag, ab, _ := out.At(x, y).RGBA()
+		assert.InDelta(s.T(), er>>8, ar>>8, 1, "red at %d,%d", x, y)
+		assert.InDelta(s.T(), eg>>8, ag>>8, 1, "green at %d,%d", x, y)
+		assert.InDelta(s.T(), eb>>8, ab>>8, 1, "blue at %d,%d", x, y)
 	}
+	assertColor(red, 0, 0)
+	assertColor(red, 39, 19)
+	assertColor(white, 40, 0)
+	assertColor(white, 0, 20)
+	assertColor(blue, 30, 5)
+	assertColor(blue, 39, 14)
+	assertColor(white, 99, 49)
+	assertColor(color.RGBA{R: 127, G: 127, B: 255, A: 255}, 90, 49)
 }
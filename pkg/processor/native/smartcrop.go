@@ -0,0 +1,174 @@
+package native
+
+import (
+	"image"
+	"math"
+
+	"github.com/anthonynsimon/bild/clone"
+	"github.com/anthonynsimon/bild/transform"
+	"github.com/gojek/darkroom/pkg/processor"
+)
+
+const (
+	// smartCropAnalysisSize is the largest side of the downscaled image on which the crop windows are scored
+	smartCropAnalysisSize = 256
+	// entropyBins is the number of bins of the luminance histograms of the entropy crop
+	entropyBins = 32
+	// saturationWeight and skinWeight are the weights of the saturation and the skin tones relative to the
+	// edges in the saliency of the attention crop
+	saturationWeight = 0.5
+	skinWeight       = 0.5
+)
+
+// getSmartCropOffset returns the top left corner of the most interesting width x height window of img, which covers
+// the window on one side as it is resized by Crop. The windows which slide along the other side are scored by the
+// entropy of their luminance for processor.PointEntropy, and by their density of edges, saturated colors and skin
+// tones for processor.PointAttention. The ties are broken towards the center so that the result is deterministic.
+func getSmartCropOffset(img image.Image, width, height int, point processor.Point) (int, int) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= width && h <= height {
+		return 0, 0
+	}
+	s := math.Min(1, smartCropAnalysisSize/math.Max(float64(w), float64(h)))
+	aw, ah := clampInt(int(math.Round(float64(w)*s)), 1, w), clampInt(int(math.Round(float64(h)*s)), 1, h)
+	var rgba *image.RGBA
+	if aw != w || ah != h {
+		rgba = transform.Resize(img, aw, ah, transform.Linear)
+	} else {
+		rgba = clone.AsRGBA(img)
+	}
+	horizontal := w-width >= h-height
+	n, window, slack := ah, int(math.Round(float64(height)*s)), h-height
+	if horizontal {
+		n, window, slack = aw, int(math.Round(float64(width)*s)), w-width
+	}
+	window = clampInt(window, 1, n)
+
+	var score func(start, end int) float64
+	if point == processor.PointEntropy {
+		score = getEntropyScorer(rgba, horizontal)
+	} else {
+		score = getSaliencyScorer(rgba, horizontal)
+	}
+	best, bestScore := 0, math.Inf(-1)
+	center := float64(n-window) / 2
+	for start := 0; start <= n-window; start++ {
+		sc := score(start, start+window)
+		if sc > bestScore || (sc == bestScore && math.Abs(float64(start)-center) < math.Abs(float64(best)-center)) {
+			best, bestScore = start, sc
+		}
+	}
+
+	// The window is centered if the centered one scores as well as the best one
+	offset := slack / 2
+	if n > window && score((n-window)/2, (n-window)/2+window) < bestScore {
+		offset = int(math.Round(float64(best) * float64(slack) / float64(n-window)))
+	}
+	if horizontal {
+		return offset, (h - height) / 2
+	}
+	return (w - width) / 2, offset
+}
+
+// getEntropyScorer returns a function which scores the lines from start to end of img, the columns if horizontal is
+// true and the rows otherwise, by the Shannon entropy of the histogram of their luminance
+func getEntropyScorer(img *image.RGBA, horizontal bool) func(start, end int) float64 {
+	n, lineLen := getLines(img, horizontal)
+	// hist[i] is the histogram of the lines before i, so that the histogram of a window is a difference
+	hist := make([][entropyBins]int, n+1)
+	for i := 0; i < n; i++ {
+		hist[i+1] = hist[i]
+		for j := 0; j < lineLen; j++ {
+			r, g, b, _ := getLinePixel(img, horizontal, i, j)
+			hist[i+1][int(luminance(r, g, b))*entropyBins/256]++
+		}
+	}
+	return func(start, end int) float64 {
+		total := float64((end - start) * lineLen)
+		e := 0.0
+		for k := 0; k < entropyBins; k++ {
+			if c := hist[end][k] - hist[start][k]; c > 0 {
+				p := float64(c) / total
+				e -= p * math.Log2(p)
+			}
+		}
+		return e
+	}
+}
+
+// getSaliencyScorer returns a function which scores the lines from start to end of img, the columns if horizontal is
+// true and the rows otherwise, by the sum of the saliency of their pixels. The saliency is rounded so that the
+// windows with the same content get exactly the same score.
+func getSaliencyScorer(img *image.RGBA, horizontal bool) func(start, end int) float64 {
+	n, lineLen := getLines(img, horizontal)
+	sums := make([]int64, n+1)
+	for i := 0; i < n; i++ {
+		sums[i+1] = sums[i]
+		for j := 0; j < lineLen; j++ {
+			sums[i+1] += int64(math.Round(getSaliency(img, horizontal, i, j)))
+		}
+	}
+	return func(start, end int) float64 {
+		return float64(sums[end] - sums[start])
+	}
+}
+
+// getSaliency returns the saliency of the pixel j of the line i of img: the magnitude of the Laplacian of the
+// luminance, which is 0 in smooth gradients, plus its weighted saturation and skin tone scaled by its opacity
+func getSaliency(img *image.RGBA, horizontal bool, i, j int) float64 {
+	x, y := i, j
+	if !horizontal {
+		x, y = j, i
+	}
+	lum := func(dx, dy int) float64 {
+		b := img.Bounds()
+		r, g, bl, _ := getPixel(img, clampInt(x+dx, 0, b.Dx()-1), clampInt(y+dy, 0, b.Dy()-1))
+		return luminance(r, g, bl)
+	}
+	edge := math.Min(math.Abs(4*lum(0, 0)-lum(-1, 0)-lum(1, 0)-lum(0, -1)-lum(0, 1)), 255)
+
+	r, g, b, a := getPixel(img, x, y)
+	maxC, minC := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	saturation := 0.0
+	if maxC > 0 {
+		saturation = (maxC - minC) / maxC * 255
+	}
+	skin := 0.0
+	// The RGB skin tone rule of Peer et al. for uniform daylight illumination
+	if r > 95 && g > 40 && b > 20 && maxC-minC > 15 && math.Abs(r-g) > 15 && r > g && r > b {
+		skin = 255
+	}
+	return edge + (saturationWeight*saturation+skinWeight*skin)*a/255
+}
+
+// getLines returns the number of lines of img and their length, the lines are the columns if horizontal is true
+// and the rows otherwise
+func getLines(img *image.RGBA, horizontal bool) (int, int) {
+	b := img.Bounds()
+	if horizontal {
+		return b.Dx(), b.Dy()
+	}
+	return b.Dy(), b.Dx()
+}
+
+// getLinePixel returns the components of the pixel j of the line i of img
+func getLinePixel(img *image.RGBA, horizontal bool, i, j int) (r, g, b, a float64) {
+	if horizontal {
+		return getPixel(img, i, j)
+	}
+	return getPixel(img, j, i)
+}
+
+// getPixel returns the premultiplied components of the pixel at x, y relative to the top left corner of img
+func getPixel(img *image.RGBA, x, y int) (r, g, b, a float64) {
+	o := img.PixOffset(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+	p := img.Pix[o : o+4 : o+4]
+	return float64(p[0]), float64(p[1]), float64(p[2]), float64(p[3])
+}
+
+// luminance returns the Rec. 601 luma of the color, as used by GrayScale, it is premultiplied by the alpha
+// of premultiplied colors
+func luminance(r, g, b float64) float64 {
+	return 0.299*r + 0.587*g + 0.114*b
+}
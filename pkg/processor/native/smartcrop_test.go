@@ -0,0 +1,113 @@
+package native
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+// readFixture decodes an image of _testdata, the smartcrop fixtures are:
+//   - smartcrop_horizontal.png: 300x100, a smooth gray gradient on the left third, which has the most entropy, and
+//     a red square at 220,30-260,70 on a flat gray background, which draws the most attention
+//   - smartcrop_vertical.png: 100x300, a skin toned face with eyes and a mouth at 25,18-75,82 on a flat background
+func readFixture(t *testing.T, name string) image.Image {
+	data, err := ioutil.ReadFile("_testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := NewBildProcessor().Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestGetSmartCropOffset(t *testing.T) {
+	cases := []struct {
+		name     string
+		fixture  string
+		point    processor.Point
+		width    int
+		height   int
+		contains image.Rectangle
+		within   image.Rectangle
+	}{
+		{name: "EntropyHorizontal", fixture: "smartcrop_horizontal.png", point: processor.PointEntropy, width: 100, height: 100,
+			contains: image.Rect(0, 0, 100, 100)},
+		{name: "AttentionHorizontal", fixture: "smartcrop_horizontal.png", point: processor.PointAttention, width: 100, height: 100,
+			contains: image.Rect(220, 30, 260, 70)},
+		{name: "EntropyVertical", fixture: "smartcrop_vertical.png", point: processor.PointEntropy, width: 100, height: 100,
+			contains: image.Rect(25, 18, 75, 82)},
+		{name: "AttentionVertical", fixture: "smartcrop_vertical.png", point: processor.PointAttention, width: 100, height: 100,
+			contains: image.Rect(25, 18, 75, 82)},
+		{name: "AttentionNarrowWindow", fixture: "smartcrop_horizontal.png", point: processor.PointAttention, width: 60, height: 100,
+			contains: image.Rect(220, 30, 260, 70)},
+		{name: "EntropyNarrowWindow", fixture: "smartcrop_horizontal.png", point: processor.PointEntropy, width: 60, height: 100,
+			within: image.Rect(0, 0, 100, 100)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			img := readFixture(t, c.fixture)
+			x, y := getSmartCropOffset(img, c.width, c.height, c.point)
+			window := image.Rect(x, y, x+c.width, y+c.height)
+			assert.True(t, c.contains.In(window), "window %v", window)
+			assert.True(t, window.In(img.Bounds()), "window %v", window)
+			if !c.within.Empty() {
+				assert.True(t, window.In(c.within), "window %v", window)
+			}
+		})
+	}
+}
+
+func TestGetSmartCropOffset_IsDeterministic(t *testing.T) {
+	img := readFixture(t, "test.jpg")
+	for _, p := range []processor.Point{processor.PointEntropy, processor.PointAttention} {
+		x, y := getSmartCropOffset(img, 200, img.Bounds().Dy(), p)
+		for i := 0; i < 3; i++ {
+			x1, y1 := getSmartCropOffset(img, 200, img.Bounds().Dy(), p)
+			assert.Equal(t, []int{x, y}, []int{x1, y1})
+		}
+	}
+
+	// The windows of a flat image have the same score, the centered one is picked
+	flat := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.RGBA{R: 10, G: 200, B: 30, A: 255}), image.Point{}, draw.Src)
+	for _, p := range []processor.Point{processor.PointEntropy, processor.PointAttention} {
+		x, y := getSmartCropOffset(flat, 100, 100, p)
+		assert.Equal(t, []int{100, 0}, []int{x, y})
+		x, y = getSmartCropOffset(flat, 300, 100, p)
+		assert.Equal(t, []int{0, 0}, []int{x, y})
+	}
+}
+
+func TestBildProcessor_CropWithSmartCropPoints(t *testing.T) {
+	bp := NewBildProcessor()
+	img := readFixture(t, "smartcrop_horizontal.png")
+
+	out := bp.Crop(img, 50, 50, processor.PointAttention)
+	assert.Equal(t, 50, out.Bounds().Dx())
+	assert.Equal(t, 50, out.Bounds().Dy())
+	// The fixture is resized to 150x50 and the red square to 110,15-130,35, the window is the closest to the center
+	// which contains it, ie: 80,0-130,50
+	assert.Equal(t, color.RGBA{R: 220, G: 20, B: 30, A: 255}, rgbaAt(out, 40, 25))
+
+	out = bp.Crop(img, 50, 50, processor.PointEntropy)
+	c := rgbaAt(out, 0, 25)
+	assert.True(t, c.R == c.G && c.R < 64, "color %v", c)
+
+	// The frames of an animation are cropped at the offset found on the first one
+	red := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{R: 128, G: 128, B: 128, A: 255}), image.Point{}, draw.Src)
+	a := &processor.Animation{Frames: []image.Image{img, red}, Delays: []int{10, 10}}
+	cropped, ok := bp.Crop(a, 50, 50, processor.PointAttention).(*processor.Animation)
+	if assert.True(t, ok) {
+		assert.Equal(t, cropped.Frames[0].Bounds(), cropped.Frames[1].Bounds())
+		assert.Equal(t, color.RGBA{R: 220, G: 20, B: 30, A: 255}, rgbaAt(cropped.Frames[0], 40, 25))
+		assert.Equal(t, color.RGBA{R: 128, G: 128, B: 128, A: 255}, rgbaAt(cropped.Frames[1], 40, 25))
+	}
+}
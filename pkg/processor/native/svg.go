@@ -0,0 +1,157 @@
+package native
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"golang.org/x/net/html/charset"
+)
+
+// maxSVGPixels is the limit of the number of pixels of a rasterized SVG image, it takes up to 200 MB
+const maxSVGPixels = 50000000
+
+var (
+	// ErrSVGExternalReference is returned when an SVG image references an external resource or declares
+	// entities and BildProcessor is not created with WithSVGExternalReferences
+	ErrSVGExternalReference = errors.New("svg external references are not allowed")
+	// ErrSVGTooLarge is returned when an SVG image would be rasterized with more than 50 million pixels
+	ErrSVGTooLarge = errors.New("svg is too large")
+	errSVGNoSize   = errors.New("svg has no size")
+)
+
+// rasterizeSVG draws an SVG image on an image.RGBA which keeps its aspect ratio and covers width and height,
+// the external resources referenced by the image are never loaded
+func rasterizeSVG(data []byte, width, height int, allowExternalRefs bool) (img image.Image, err error) {
+	if !allowExternalRefs {
+		if err := checkSVGReferences(data); err != nil {
+			return nil, err
+		}
+	}
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.IgnoreErrorMode)
+	if err != nil {
+		return nil, fmt.Errorf("svg: %w", err)
+	}
+	w, h, err := getSVGRasterSize(icon.ViewBox.W, icon.ViewBox.H, width, height)
+	if err != nil {
+		return nil, err
+	}
+	// The parsed paths are drawn by rasterx which may panic on degenerate input
+	defer func() {
+		if r := recover(); r != nil {
+			img, err = nil, fmt.Errorf("svg: %v", r)
+		}
+	}()
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	icon.SetTarget(0, 0, float64(w), float64(h))
+	icon.Draw(rasterx.NewDasher(w, h, rasterx.NewScannerGV(w, h, rgba, rgba.Bounds())), 1)
+	return rgba, nil
+}
+
+// getSVGRasterSize returns the size of the smallest bitmap which keeps the aspect ratio of an SVG image
+// of size iw x ih and covers width x height
+func getSVGRasterSize(iw, ih float64, width, height int) (int, int, error) {
+	if iw <= 0 || ih <= 0 || math.IsInf(iw, 0) || math.IsInf(ih, 0) {
+		return 0, 0, errSVGNoSize
+	}
+	s := 1.0
+	if width != 0 || height != 0 {
+		s = math.Max(float64(width)/iw, float64(height)/ih)
+	}
+	w := math.Max(math.Round(iw*s), math.Max(float64(width), 1))
+	h := math.Max(math.Round(ih*s), math.Max(float64(height), 1))
+	if w*h > maxSVGPixels {
+		return 0, 0, ErrSVGTooLarge
+	}
+	return int(w), int(h), nil
+}
+
+// checkSVGReferences returns an ErrSVGExternalReference if the SVG image declares entities, or has an href,
+// a url() or a style sheet @import which neither points to an element of the image nor embeds a data URL.
+// The external DTD of a doctype is allowed as it is never loaded by the xml decoder.
+func checkSVGReferences(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	inStyle := false
+	for {
+		t, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("svg: %w", err)
+		}
+		switch tok := t.(type) {
+		case xml.Directive:
+			if bytes.HasPrefix(tok, []byte("DOCTYPE")) && bytes.Contains(tok, []byte("<!ENTITY")) {
+				return fmt.Errorf("%w: entity declaration", ErrSVGExternalReference)
+			}
+		case xml.StartElement:
+			inStyle = tok.Name.Local == "style"
+			for _, attr := range tok.Attr {
+				ref := getExternalURL(attr.Value)
+				if attr.Name.Local == "href" {
+					ref = attr.Value
+				}
+				if ref != "" && !isInternalReference(ref) {
+					return fmt.Errorf("%w: %s", ErrSVGExternalReference, ref)
+				}
+			}
+		case xml.EndElement:
+			inStyle = false
+		case xml.CharData:
+			if !inStyle {
+				continue
+			}
+			if strings.Contains(string(tok), "@import") {
+				return fmt.Errorf("%w: @import", ErrSVGExternalReference)
+			}
+			if ref := getExternalURL(string(tok)); ref != "" {
+				return fmt.Errorf("%w: %s", ErrSVGExternalReference, ref)
+			}
+		}
+	}
+}
+
+// getExternalURL returns the first url() of a style value which is not an internal reference
+func getExternalURL(v string) string {
+	for {
+		i := strings.Index(v, "url(")
+		if i < 0 {
+			return ""
+		}
+		v = v[i+len("url("):]
+		ref, _, _ := strings.Cut(v, ")")
+		if !isInternalReference(ref) {
+			return ref
+		}
+	}
+}
+
+// isInternalReference returns true if the reference points to an element of the image or embeds a data URL
+func isInternalReference(ref string) bool {
+	ref = strings.Trim(ref, " \t\r\n'\"")
+	return strings.HasPrefix(ref, "#") || strings.HasPrefix(strings.ToLower(ref), "data:")
+}
+
+// Rasterize takes an SVG image, width and height and returns the image rasterized at the smallest size which
+// keeps its aspect ratio and covers the width and height, its format or the error. It is rasterized at its own
+// size if width and height are 0.
+func (bp *BildProcessor) Rasterize(data []byte, width, height int) (image.Image, string, error) {
+	if processor.DetectFormat(data) != processor.ExtensionSVG {
+		return bp.Decode(data)
+	}
+	img, err := rasterizeSVG(data, width, height, bp.allowSVGExternalRefs)
+	if err != nil {
+		return nil, "", err
+	}
+	return img, processor.ExtensionSVG, nil
+}
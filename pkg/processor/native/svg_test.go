@@ -0,0 +1,103 @@
+package native
+
+import (
+	"errors"
+	"image"
+	"io/ioutil"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+const halvesSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<svg xmlns="http://www.w3.org/2000/svg" width="40" height="20" viewBox="0 0 4 2">
+  <rect x="0" y="0" width="2" height="2" fill="#ff0000"/>
+  <rect x="2" y="0" width="2" height="2" fill="blue"/>
+</svg>`
+
+func TestBildProcessor_Rasterize(t *testing.T) {
+	bp := NewBildProcessor()
+	cases := []struct {
+		width, height int
+		expected      image.Rectangle
+	}{
+		{expected: image.Rect(0, 0, 4, 2)},
+		{width: 400, expected: image.Rect(0, 0, 400, 200)},
+		{height: 100, expected: image.Rect(0, 0, 200, 100)},
+		{width: 100, height: 100, expected: image.Rect(0, 0, 200, 100)},
+		{width: 300, height: 50, expected: image.Rect(0, 0, 300, 150)},
+	}
+	for _, c := range cases {
+		img, f, err := bp.Rasterize([]byte(halvesSVG), c.width, c.height)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, processor.ExtensionSVG, f)
+		assert.Equal(t, c.expected, img.Bounds())
+		assert.Equal(t, red, rgbaAt(img, 0, 0))
+		assert.Equal(t, blue, rgbaAt(img, c.expected.Dx()-1, c.expected.Dy()-1))
+	}
+
+	img, f, err := bp.Decode([]byte(halvesSVG))
+	assert.NoError(t, err)
+	assert.Equal(t, processor.ExtensionSVG, f)
+	assert.Equal(t, image.Rect(0, 0, 4, 2), img.Bounds())
+
+	data, _ := ioutil.ReadFile("_testdata/test.png")
+	img, f, err = bp.Rasterize(data, 10, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, processor.ExtensionPNG, f)
+	assert.NotNil(t, img)
+}
+
+func TestBildProcessor_RasterizeWithInvalidSVG(t *testing.T) {
+	bp := NewBildProcessor()
+	_, _, err := bp.Rasterize([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"/></svg>`), 10, 10)
+	assert.Equal(t, errSVGNoSize, err)
+	_, _, err = bp.Rasterize([]byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1">`), 10, 10)
+	assert.Error(t, err)
+	_, _, err = bp.Rasterize([]byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100000 100000"></svg>`), 0, 0)
+	assert.Equal(t, ErrSVGTooLarge, err)
+	_, _, err = bp.Rasterize([]byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"></svg>`), 10000, 10000)
+	assert.Equal(t, ErrSVGTooLarge, err)
+}
+
+func TestBildProcessor_RasterizeWithExternalReferences(t *testing.T) {
+	cases := []struct {
+		name     string
+		svg      string
+		external bool
+	}{
+		{name: "Image", external: true,
+			svg: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"><image href="http://169.254.169.254/" width="1" height="1"/></svg>`},
+		{name: "XLinkImage", external: true,
+			svg: `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 1 1"><image xlink:href="file:///etc/passwd"/></svg>`},
+		{name: "Use", external: true,
+			svg: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"><use href="other.svg#shape"/></svg>`},
+		{name: "FillURL", external: true,
+			svg: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"><rect fill="url('http://example.com/a.svg#g')" width="1" height="1"/></svg>`},
+		{name: "StyleURL", external: true,
+			svg: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"><style>rect { fill: url(http://example.com/a.svg#g) }</style></svg>`},
+		{name: "StyleImport", external: true,
+			svg: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"><style>@import "http://example.com/a.css";</style></svg>`},
+		{name: "Entity", external: true,
+			svg: `<!DOCTYPE svg [<!ENTITY a SYSTEM "file:///etc/passwd">]><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"></svg>`},
+		{name: "InternalReferences",
+			svg: `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 1 1">
+<defs><linearGradient id="g"><stop offset="0" stop-color="red"/></linearGradient><rect id="r" width="1" height="1"/></defs>
+<use xlink:href="#r" fill="url(#g)"/><image href="data:image/png;base64,iVBORw0KGgo=" width="1" height="1"/>
+<text>url(http://example.com) @import</text></svg>`},
+		{name: "ExternalDTD", svg: halvesSVG},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, _, err := NewBildProcessor().Rasterize([]byte(c.svg), 10, 10)
+			assert.Equal(t, c.external, errors.Is(err, ErrSVGExternalReference), "error %v", err)
+
+			_, _, err = NewBildProcessor(WithSVGExternalReferences(true)).Rasterize([]byte(c.svg), 10, 10)
+			assert.False(t, errors.Is(err, ErrSVGExternalReference))
+		})
+	}
+}
@@ -2,6 +2,7 @@ package native
 
 import (
 	"image"
+	"math"
 
 	"github.com/anthonynsimon/bild/parallel"
 	"github.com/gojek/darkroom/pkg/config"
@@ -106,3 +107,47 @@ func getStartingPointForCrop(w, h, rw, rh int, cropPoint processor.Point) (int,
 	}
 	return x, y
 }
+
+// getFocalPointCropSize returns the size of the largest region of an image of size aw x ah with the aspect ratio
+// of rw x rh, divided by the zoom z
+func getFocalPointCropSize(rw, rh, aw, ah int, z float64) (int, int) {
+	w, h := aw, aw*rh/rw
+	if h > ah {
+		w, h = ah*rw/rh, ah
+	}
+	return clampInt(int(math.Round(float64(w)/z)), 1, aw), clampInt(int(math.Round(float64(h)/z)), 1, ah)
+}
+
+// w: actual width, h: actual height, rw: crop width, rh: crop height, fx and fy: normalized focal point
+func getStartingPointForFocalPoint(w, h, rw, rh int, fx, fy float64) (int, int) {
+	x := int(math.Round(fx*float64(w) - float64(rw)/2))
+	y := int(math.Round(fy*float64(h) - float64(rh)/2))
+	return clampInt(x, 0, w-rw), clampInt(y, 0, h-rh)
+}
+
+// padPoint moves the starting point of an overlay which is anchored to a side or a corner away from the edges by padding
+func padPoint(x, y, padding int, point processor.Point) (int, int) {
+	switch point {
+	case processor.PointTopLeft, processor.PointLeft, processor.PointBottomLeft:
+		x += padding
+	case processor.PointTopRight, processor.PointRight, processor.PointBottomRight:
+		x -= padding
+	}
+	switch point {
+	case processor.PointTopLeft, processor.PointTop, processor.PointTopRight:
+		y += padding
+	case processor.PointBottomLeft, processor.PointBottom, processor.PointBottomRight:
+		y -= padding
+	}
+	return x, y
+}
+
+// clampInt returns n clamped to the range from min to max
+func clampInt(n, min, max int) int {
+	if n < min {
+		return min
+	} else if n > max {
+		return max
+	}
+	return n
+}
@@ -102,6 +102,42 @@ func TestGetStartingPointForCrop(t *testing.T) {
 	assert.Equal(t, 0, y)
 }
 
+func TestGetFocalPointCropSize(t *testing.T) {
+	w, h := getFocalPointCropSize(100, 100, 400, 200, 1)
+	assert.Equal(t, 200, w)
+	assert.Equal(t, 200, h)
+
+	w, h = getFocalPointCropSize(400, 100, 400, 200, 1)
+	assert.Equal(t, 400, w)
+	assert.Equal(t, 100, h)
+
+	w, h = getFocalPointCropSize(100, 100, 400, 200, 2)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 100, h)
+
+	w, h = getFocalPointCropSize(100, 100, 400, 200, 1000)
+	assert.Equal(t, 1, w)
+	assert.Equal(t, 1, h)
+}
+
+func TestGetStartingPointForFocalPoint(t *testing.T) {
+	x, y := getStartingPointForFocalPoint(400, 200, 100, 100, 0.5, 0.5)
+	assert.Equal(t, 150, x)
+	assert.Equal(t, 50, y)
+
+	x, y = getStartingPointForFocalPoint(400, 200, 100, 100, 0.3, 0.6)
+	assert.Equal(t, 70, x)
+	assert.Equal(t, 70, y)
+
+	x, y = getStartingPointForFocalPoint(400, 200, 100, 100, 0, 0)
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+
+	x, y = getStartingPointForFocalPoint(400, 200, 100, 100, 1, 1)
+	assert.Equal(t, 300, x)
+	assert.Equal(t, 100, y)
+}
+
 func Test_isOpaqueWithFastOpaqueMethod(t *testing.T) {
 	r := image.Rect(0, 0, 640, 480)
 	gray, gray16, cmyk := image.NewGray(r), image.NewGray16(r), image.NewCMYK(r)
@@ -187,3 +223,24 @@ func (im *MockImage) At(x, y int) color.Color {
 func (im *MockImage) Set(x, y int, c color.Color) {
 	im.points[y][x] = c
 }
+
+func TestPadPoint(t *testing.T) {
+	cases := []struct {
+		point    processor.Point
+		expected image.Point
+	}{
+		{point: processor.PointTopLeft, expected: image.Pt(60, 60)},
+		{point: processor.PointTop, expected: image.Pt(50, 60)},
+		{point: processor.PointTopRight, expected: image.Pt(40, 60)},
+		{point: processor.PointLeft, expected: image.Pt(60, 50)},
+		{point: processor.PointCenter, expected: image.Pt(50, 50)},
+		{point: processor.PointRight, expected: image.Pt(40, 50)},
+		{point: processor.PointBottomLeft, expected: image.Pt(60, 40)},
+		{point: processor.PointBottom, expected: image.Pt(50, 40)},
+		{point: processor.PointBottomRight, expected: image.Pt(40, 40)},
+	}
+	for _, c := range cases {
+		x, y := padPoint(50, 50, 10, c.point)
+		assert.Equal(t, c.expected, image.Pt(x, y))
+	}
+}
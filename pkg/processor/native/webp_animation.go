@@ -0,0 +1,142 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/chai2010/webp"
+	"github.com/gojek/darkroom/pkg/processor"
+)
+
+// The flags of the VP8X and ANMF chunks, see https://developers.google.com/speed/webp/docs/riff_container
+const (
+	vp8xFlagAlpha     = 0x10
+	vp8xFlagAnimation = 0x02
+	anmfFlagNoBlend   = 0x02
+)
+
+// encodeAnimatedWebP encodes every frame of the animation as a still WebP image with the options and wraps their
+// bitstreams into the ANMF chunks of an animated WebP image. The frames cover the whole canvas, so they are neither
+// blended with nor disposed to the previous frames.
+func encodeAnimatedWebP(a *processor.Animation, opt *webp.Options) ([]byte, error) {
+	b := a.Bounds()
+	if b.Empty() {
+		return nil, errors.New("webp: cannot encode an empty animation")
+	}
+	var hasAlpha bool
+	frames := &bytes.Buffer{}
+	for i, frame := range a.Frames {
+		still := &bytes.Buffer{}
+		if err := webp.Encode(still, frame, opt); err != nil {
+			return nil, err
+		}
+		bitstream, alpha, err := getWebPBitstream(still.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		hasAlpha = hasAlpha || alpha
+
+		var duration int
+		if i < len(a.Delays) {
+			// The delays are in 100ths of a second while the durations are in milliseconds
+			duration = a.Delays[i] * 10
+		}
+		fb := frame.Bounds()
+		anmf := make([]byte, 16, 16+len(bitstream))
+		putUint24(anmf[6:], fb.Dx()-1)
+		putUint24(anmf[9:], fb.Dy()-1)
+		putUint24(anmf[12:], duration)
+		anmf[15] = anmfFlagNoBlend
+		writeRIFFChunk(frames, "ANMF", append(anmf, bitstream...))
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = vp8xFlagAnimation
+	if hasAlpha {
+		vp8x[0] |= vp8xFlagAlpha
+	}
+	putUint24(vp8x[4:], b.Dx()-1)
+	putUint24(vp8x[7:], b.Dy()-1)
+	anim := make([]byte, 6)
+	binary.LittleEndian.PutUint16(anim[4:], uint16(getWebPLoopCount(a.LoopCount)))
+
+	body := &bytes.Buffer{}
+	body.WriteString("WEBP")
+	writeRIFFChunk(body, "VP8X", vp8x)
+	writeRIFFChunk(body, "ANIM", anim)
+	body.Write(frames.Bytes())
+
+	out := &bytes.Buffer{}
+	writeRIFFChunk(out, "RIFF", body.Bytes())
+	return out.Bytes(), nil
+}
+
+// getWebPBitstream returns the ALPH, VP8 and VP8L chunks of a still WebP image, which make up the data of a frame
+// in an animated WebP image, and whether the image has an alpha channel
+func getWebPBitstream(data []byte) ([]byte, bool, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, false, errors.New("webp: invalid still image")
+	}
+	var bitstream []byte
+	var hasAlpha bool
+	for rest := data[12:]; len(rest) >= 8; {
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		end := 8 + size + size&1
+		if 8+size > len(rest) {
+			return nil, false, errors.New("webp: truncated still image")
+		}
+		if end > len(rest) {
+			end = len(rest)
+		}
+		switch string(rest[:4]) {
+		case "ALPH":
+			hasAlpha = true
+			bitstream = append(bitstream, rest[:end]...)
+		case "VP8L":
+			// The alpha_is_used bit of the VP8L header
+			hasAlpha = hasAlpha || (size >= 5 && rest[12]&0x10 != 0)
+			bitstream = append(bitstream, rest[:end]...)
+		case "VP8 ":
+			bitstream = append(bitstream, rest[:end]...)
+		}
+		rest = rest[end:]
+	}
+	if len(bitstream) == 0 {
+		return nil, false, errors.New("webp: still image has no bitstream")
+	}
+	return bitstream, hasAlpha, nil
+}
+
+// getWebPLoopCount converts the loop count of a processor.Animation, which follows image/gif, to the number of
+// times an animated WebP image is played where 0 is forever
+func getWebPLoopCount(loopCount int) int {
+	switch {
+	case loopCount == 0:
+		return 0
+	case loopCount < 0:
+		return 1
+	case loopCount >= math.MaxUint16:
+		return math.MaxUint16
+	default:
+		return loopCount + 1
+	}
+}
+
+func writeRIFFChunk(w *bytes.Buffer, fourCC string, payload []byte) {
+	header := make([]byte, 8)
+	copy(header, fourCC)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(payload)))
+	w.Write(header)
+	w.Write(payload)
+	if len(payload)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+func putUint24(b []byte, v int) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
@@ -0,0 +1,112 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io/ioutil"
+	"testing"
+
+	"github.com/chai2010/webp"
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+type riffChunk struct {
+	fourCC  string
+	payload []byte
+}
+
+func readRIFFChunks(t *testing.T, data []byte) []riffChunk {
+	var chunks []riffChunk
+	for len(data) >= 8 {
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if !assert.LessOrEqual(t, 8+size, len(data)) {
+			return nil
+		}
+		chunks = append(chunks, riffChunk{fourCC: string(data[:4]), payload: data[8 : 8+size]})
+		data = data[8+size+size&1:]
+	}
+	return chunks
+}
+
+type color4 [4]uint32
+
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+func TestWebPEncoder_Encode_WithAnimation(t *testing.T) {
+	bp := NewBildProcessor()
+	img, _, _ := bp.Decode(newAnimatedGIF(t))
+
+	data, err := (&WebPEncoder{FrameOption: &webp.Options{Lossless: true}}).Encode(img)
+
+	assert.NoError(t, err)
+	assert.Equal(t, processor.ExtensionWebP, processor.DetectFormat(data))
+	assert.Equal(t, len(data)-8, int(binary.LittleEndian.Uint32(data[4:8])))
+	chunks := readRIFFChunks(t, data[12:])
+	if !assert.Len(t, chunks, 5) {
+		return
+	}
+	assert.Equal(t, "VP8X", chunks[0].fourCC)
+	assert.Equal(t, byte(vp8xFlagAnimation|vp8xFlagAlpha), chunks[0].payload[0])
+	assert.Equal(t, 19, uint24(chunks[0].payload[4:]))
+	assert.Equal(t, 9, uint24(chunks[0].payload[7:]))
+	assert.Equal(t, "ANIM", chunks[1].fourCC)
+	assert.Equal(t, uint16(3), binary.LittleEndian.Uint16(chunks[1].payload[4:]))
+
+	expected := []color4{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 0, 0}}
+	for i, c := range chunks[2:] {
+		assert.Equal(t, "ANMF", c.fourCC)
+		assert.Equal(t, 19, uint24(c.payload[6:]))
+		assert.Equal(t, 9, uint24(c.payload[9:]))
+		assert.Equal(t, (i+1)*100, uint24(c.payload[12:]))
+		assert.Equal(t, byte(anmfFlagNoBlend), c.payload[15])
+
+		// The frame data of a lossless frame is a VP8L chunk which is a still image on its own
+		still := &bytes.Buffer{}
+		body := &bytes.Buffer{}
+		body.WriteString("WEBP")
+		body.Write(c.payload[16:])
+		writeRIFFChunk(still, "RIFF", body.Bytes())
+		frame, err := webp.Decode(bytes.NewReader(still.Bytes()))
+		if assert.NoError(t, err) {
+			r, g, b, a := frame.At(5, 5).RGBA()
+			assert.Equal(t, expected[i], color4{r >> 8, g >> 8, b >> 8, a >> 8}, "frame %d", i)
+		}
+	}
+}
+
+func TestWebPEncoder_Encode_FrameOptionShouldAffectFileSize(t *testing.T) {
+	bp := NewBildProcessor()
+	data, _ := ioutil.ReadFile("_testdata/test.png")
+	src, _, _ := bp.Decode(data)
+	a := &processor.Animation{Frames: []image.Image{src, bp.Flip(src, "h")}, Delays: []int{10, 10}}
+
+	low, err := (&WebPEncoder{FrameOption: &webp.Options{Quality: 10}}).Encode(a)
+	assert.NoError(t, err)
+	high, err := (&WebPEncoder{Option: &webp.Options{Quality: 10}, FrameOption: &webp.Options{Quality: 90}}).Encode(a)
+	assert.NoError(t, err)
+	assert.True(t, len(low) < len(high))
+
+	still, err := (&WebPEncoder{Option: &webp.Options{Quality: 10}}).Encode(a)
+	assert.NoError(t, err)
+	assert.Equal(t, len(low), len(still))
+}
+
+func TestGetWebPBitstream(t *testing.T) {
+	_, _, err := getWebPBitstream([]byte("RIFF\x00\x00\x00\x00WEBP"))
+	assert.Error(t, err)
+	_, _, err = getWebPBitstream([]byte("badImage.ext"))
+	assert.Error(t, err)
+	_, _, err = getWebPBitstream([]byte("RIFF\x00\x00\x00\x00WEBPVP8 \xff\x00\x00\x00"))
+	assert.Error(t, err)
+}
+
+func TestGetWebPLoopCount(t *testing.T) {
+	assert.Equal(t, 0, getWebPLoopCount(0))
+	assert.Equal(t, 1, getWebPLoopCount(-1))
+	assert.Equal(t, 3, getWebPLoopCount(2))
+	assert.Equal(t, 65535, getWebPLoopCount(70000))
+}
@@ -2,6 +2,7 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/pprof"
 
@@ -19,6 +20,7 @@ import (
 // NewRouter takes in handler Dependencies and returns mux.Router with default routes
 // and if debug mode is enabled then it also adds pprof routes.
 // It also, adds a PathPrefix to catch all route if config.DataSource().PathPrefix is set
+// and a /p/{preset} route if presets are configured
 func NewRouter(deps *service.Dependencies, registry *prometheus.Registry) *mux.Router {
 	r := mux.NewRouter().StrictSlash(true)
 
@@ -30,13 +32,18 @@ func NewRouter(deps *service.Dependencies, registry *prometheus.Registry) *mux.R
 	r.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
 	// Catch all handler
 	s := config.DataSource()
+	pathPrefix := "/"
 	if (regex.S3Matcher.MatchString(s.Kind) ||
 		regex.CloudfrontMatcher.MatchString(s.Kind)) &&
 		s.PathPrefix != "" {
-		r.Methods(http.MethodGet).PathPrefix(s.PathPrefix).Handler(handler.ImageHandler(deps))
-	} else {
-		r.Methods(http.MethodGet).PathPrefix("/").Handler(handler.ImageHandler(deps))
+		pathPrefix = s.PathPrefix
 	}
+	h := handler.ImageHandler(deps)
+	if deps.Presets != nil {
+		presetPathPrefix := fmt.Sprintf("%s{%s}%s", handler.PresetPathPrefix, service.PresetParam, pathPrefix)
+		r.Methods(http.MethodGet, http.MethodHead).PathPrefix(presetPathPrefix).Handler(h)
+	}
+	r.Methods(http.MethodGet, http.MethodHead).PathPrefix(pathPrefix).Handler(h)
 
 	return r
 }
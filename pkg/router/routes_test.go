@@ -11,6 +11,7 @@ import (
 	"github.com/gojek/darkroom/pkg/config"
 	"github.com/gojek/darkroom/pkg/service"
 	"github.com/gojek/darkroom/pkg/storage"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -44,6 +45,41 @@ func TestNewRouterWithPathPrefix(t *testing.T) {
 	assert.NotNil(t, router)
 }
 
+func TestNewRouterMatchesImageRouteForGetAndHead(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	router := NewRouter(&service.Dependencies{Storage: &mockStorage{}, Manipulator: &service.MockManipulator{},
+		MetricService: metrics.NewPrometheus(registry)}, registry)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		r, _ := http.NewRequest(method, "/path/to/folder/image.jpg", nil)
+		assert.True(t, router.Match(r, &mux.RouteMatch{}), method)
+	}
+	r, _ := http.NewRequest(http.MethodPost, "/path/to/folder/image.jpg", nil)
+	assert.False(t, router.Match(r, &mux.RouteMatch{}))
+}
+
+func TestNewRouterWithPresets(t *testing.T) {
+	v := config.Viper()
+	v.Set("source.kind", "s3")
+	v.Set("source.pathPrefix", "/path/to/folder")
+	config.Update()
+
+	presets, _ := service.NewPresets(map[string]string{"thumbnail": "w=200&h=200&fit=crop"}, false)
+	registry := prometheus.NewRegistry()
+	router := NewRouter(&service.Dependencies{Storage: &mockStorage{}, Manipulator: &service.MockManipulator{},
+		MetricService: metrics.NewPrometheus(registry), Presets: presets}, registry)
+
+	r, _ := http.NewRequest(http.MethodGet, "/p/thumbnail/path/to/folder/image.jpg", nil)
+	match := &mux.RouteMatch{}
+	assert.True(t, router.Match(r, match))
+	assert.Equal(t, map[string]string{"preset": "thumbnail"}, match.Vars)
+
+	r, _ = http.NewRequest(http.MethodGet, "/path/to/folder/image.jpg", nil)
+	match = &mux.RouteMatch{}
+	assert.True(t, router.Match(r, match))
+	assert.Empty(t, match.Vars)
+}
+
 type mockStorage struct {
 }
 
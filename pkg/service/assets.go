@@ -0,0 +1,34 @@
+package service
+
+import (
+	"path"
+)
+
+// AssetPaths returns the paths of the assets referenced by the params, eg: the watermark and the overlay images. The assets are
+// fetched from the Storage by the caller and passed to the Manipulator with SpecBuilder.WithAssets.
+func AssetPaths(params map[string]string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if p = cleanAssetPath(p); !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	if params[mark] != "" {
+		add(params[mark])
+	}
+	// The request is rejected by the Manipulator if there are too many overlays, they aren't worth fetching
+	if keys := overlayKeys(params); len(keys) <= maxOverlays {
+		for _, k := range keys {
+			add(params[k])
+		}
+	}
+	return paths
+}
+
+// cleanAssetPath returns the path an asset is fetched and looked up with, the path of an asset is always
+// absolute just like the path of a requested image
+func cleanAssetPath(p string) string {
+	return path.Clean("/" + p)
+}
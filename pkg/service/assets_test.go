@@ -0,0 +1,33 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssetPaths(t *testing.T) {
+	assert.Nil(t, AssetPaths(map[string]string{width: "100"}))
+	assert.Equal(t, []string{"/logos/brand.png"}, AssetPaths(map[string]string{mark: "/logos/brand.png"}))
+	assert.Equal(t, []string{"/logos/brand.png"}, AssetPaths(map[string]string{mark: "logos/brand.png"}))
+	assert.Equal(t, []string{"/brand.png"}, AssetPaths(map[string]string{mark: "../../brand.png"}))
+}
+
+func TestAssetPathsWithOverlays(t *testing.T) {
+	params := map[string]string{
+		mark:         "/logos/brand.png",
+		"overlay2":   "/badges/sale.png",
+		"overlay1":   "/logos/brand.png",
+		"overlay10":  "/frames/border.png",
+		"overlay1w":  "20",
+		"overlayxyz": "/ignored.png",
+	}
+	assert.Equal(t, []string{"/logos/brand.png", "/badges/sale.png", "/frames/border.png"}, AssetPaths(params))
+
+	params = map[string]string{}
+	for i := 0; i <= maxOverlays; i++ {
+		params[fmt.Sprintf("overlay%d", i)] = fmt.Sprintf("/overlay%d.png", i)
+	}
+	assert.Nil(t, AssetPaths(params))
+}
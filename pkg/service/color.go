@@ -0,0 +1,33 @@
+package service
+
+import (
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// parseHexColor takes a color as a RGB, RGBA, RRGGBB or RRGGBBAA hex code, with or without a leading #,
+// and returns the color and true if it is valid, it returns false otherwise
+func parseHexColor(input string) (color.NRGBA, bool) {
+	input = strings.TrimPrefix(input, "#")
+	if len(input) == 3 || len(input) == 4 {
+		// Expanding the short form, eg: f80 is ff8800
+		var sb strings.Builder
+		for _, r := range input {
+			sb.WriteRune(r)
+			sb.WriteRune(r)
+		}
+		input = sb.String()
+	}
+	if len(input) == 6 {
+		input += "ff"
+	}
+	if len(input) != 8 {
+		return color.NRGBA{}, false
+	}
+	v, err := strconv.ParseUint(input, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, true
+}
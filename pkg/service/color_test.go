@@ -0,0 +1,32 @@
+package service
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHexColor(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected color.NRGBA
+		valid    bool
+	}{
+		{input: "ff8800", expected: color.NRGBA{R: 0xff, G: 0x88, A: 0xff}, valid: true},
+		{input: "#FF8800", expected: color.NRGBA{R: 0xff, G: 0x88, A: 0xff}, valid: true},
+		{input: "ff880080", expected: color.NRGBA{R: 0xff, G: 0x88, A: 0x80}, valid: true},
+		{input: "f80", expected: color.NRGBA{R: 0xff, G: 0x88, A: 0xff}, valid: true},
+		{input: "f808", expected: color.NRGBA{R: 0xff, G: 0x88, A: 0x88}, valid: true},
+		{input: ""},
+		{input: "ff88"[:2]},
+		{input: "ff88001"},
+		{input: "gg8800"},
+		{input: "+f8800"},
+	}
+	for _, c := range cases {
+		actual, valid := parseHexColor(c.input)
+		assert.Equal(t, c.valid, valid, c.input)
+		assert.Equal(t, c.expected, actual, c.input)
+	}
+}
@@ -3,18 +3,24 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"image/jpeg"
+	"io/ioutil"
 	"strings"
 	"time"
 
+	"github.com/chai2010/webp"
 	"github.com/gojektech/heimdall"
 	"github.com/gojektech/heimdall/hystrix"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/gojek/darkroom/pkg/cache"
 	"github.com/gojek/darkroom/pkg/config"
 	"github.com/gojek/darkroom/pkg/logger"
 	"github.com/gojek/darkroom/pkg/metrics"
 	"github.com/gojek/darkroom/pkg/processor/native"
 	"github.com/gojek/darkroom/pkg/regex"
+	"github.com/gojek/darkroom/pkg/signature"
 	base "github.com/gojek/darkroom/pkg/storage"
 	"github.com/gojek/darkroom/pkg/storage/aws/cloudfront"
 	"github.com/gojek/darkroom/pkg/storage/aws/s3"
@@ -22,11 +28,26 @@ import (
 	"github.com/gojek/darkroom/pkg/storage/webfolder"
 )
 
+const assetCacheName = "assets"
+
 // Dependencies struct holds the reference to the Storage and the Manipulator interface implementations
 type Dependencies struct {
 	Storage       base.Storage
 	Manipulator   Manipulator
 	MetricService metrics.MetricService
+	// Verifier is used to check the signature of the requested URLs, it is nil if signed URLs are not required
+	Verifier *signature.Verifier
+	// Presets holds the named param sets which can be selected by the clients, it is nil if no preset is configured
+	Presets *Presets
+	// Cache holds the processed images, it is nil if caching is disabled
+	Cache cache.Cache
+	// AssetCache holds the assets fetched from the Storage while processing, eg: the watermark images
+	AssetCache cache.Cache
+	// ResultStorage is the bucket where the processed images are stored, it is nil if it is not configured
+	ResultStorage base.ReadWriter
+	// ResultMaxAge is how long the results are served from the ResultStorage after they are written, they are
+	// processed again once they are older. They are served until they are deleted if it is 0.
+	ResultMaxAge time.Duration
 }
 
 // NewDependencies constructs new Dependencies based on the config.DataSource().Kind
@@ -42,9 +63,33 @@ func NewDependencies(registry *prometheus.Registry) (deps *Dependencies, err err
 		metricService = metrics.NoOpMetricService{}
 		logger.Warn("NoOpMetricService is being used since metric system is not specified")
 	}
+	p, err := newProcessor()
+	if err != nil {
+		return nil, err
+	}
+	var opts []ManipulatorOption
+	if maxPixels := config.Animation().MaxPixels; maxPixels > 0 {
+		opts = append(opts, WithMaxAnimationPixels(maxPixels))
+	}
 	deps = &Dependencies{
-		Manipulator:   NewManipulator(native.NewBildProcessor(), getDefaultParams(), metricService),
+		Manipulator:   NewManipulator(p, getDefaultParams(), metricService, opts...),
 		MetricService: metricService,
+		AssetCache:    cache.NewNamedMemory(assetCacheName, config.AssetCacheSize(), metricService),
+	}
+	if keys := config.SignatureKeys(); len(keys) > 0 {
+		deps.Verifier = signature.NewVerifier(keys...)
+	}
+	if deps.Cache, err = newCache(metricService); err != nil {
+		return nil, err
+	}
+	if deps.ResultStorage, err = newResultStorage(config.ResultStorage()); err != nil {
+		return nil, err
+	}
+	deps.ResultMaxAge = config.ResultMaxAge()
+	if len(config.Presets()) > 0 || config.PresetsOnly() {
+		if deps.Presets, err = NewPresets(config.Presets(), config.PresetsOnly()); err != nil {
+			return nil, err
+		}
 	}
 	s := config.DataSource()
 	if regex.WebFolderMatcher.MatchString(s.Kind) {
@@ -62,6 +107,87 @@ func NewDependencies(registry *prometheus.Registry) (deps *Dependencies, err err
 	return deps, err
 }
 
+func newProcessor() (*native.BildProcessor, error) {
+	avif := config.AvifEncoder()
+	animation := config.Animation()
+	webPEncoder := &native.WebPEncoder{Option: &webp.Options{Quality: float32(config.WebPEncoder().Quality)}}
+	if animation.WebPFrameQuality > 0 {
+		webPEncoder.FrameOption = &webp.Options{Quality: float32(animation.WebPFrameQuality)}
+	}
+	opts := []native.ProcessorOption{native.WithEncoders(native.NewEncoders(
+		native.WithJpegEncoder(&native.JpegEncoder{Option: &jpeg.Options{Quality: config.JpegEncoder().Quality}}),
+		native.WithWebPEncoder(webPEncoder),
+		native.WithAvifEncoder(&native.AvifEncoder{
+			Option: &native.AvifOptions{Quality: avif.Quality, Speed: avif.Speed},
+		}),
+	))}
+	if animation.MaxPixels > 0 {
+		opts = append(opts, native.WithMaxAnimationPixels(animation.MaxPixels))
+	}
+	if config.SVGExternalReferencesAllowed() {
+		opts = append(opts, native.WithSVGExternalReferences(true))
+	}
+	faces := config.Faces()
+	opts = append(opts, native.WithFaceCropFallback(GetCropPoint(faces.Fallback)))
+	if faces.Cascade != "" {
+		data, err := ioutil.ReadFile(faces.Cascade)
+		if err != nil {
+			return nil, err
+		}
+		d, err := native.NewFaceDetector(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing face detection cascade %s: %w", faces.Cascade, err)
+		}
+		opts = append(opts, native.WithFaceDetector(d))
+	}
+	if path := config.TextFont(); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := native.ParseFont(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing font %s: %w", path, err)
+		}
+		opts = append(opts, native.WithFont(f))
+	}
+	return native.NewBildProcessor(opts...), nil
+}
+
+func newCache(metricService metrics.MetricService) (cache.Cache, error) {
+	var caches []cache.Cache
+	if size := config.MemoryCacheSize(); size > 0 {
+		caches = append(caches, cache.NewMemory(size, metricService))
+	}
+	if dc := config.DiskCache(); dc.Size > 0 && dc.Path != "" {
+		d, err := cache.NewDisk(dc.Path, dc.Size, metricService)
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, d)
+	}
+	switch len(caches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return caches[0], nil
+	default:
+		return cache.NewLayered(caches...), nil
+	}
+}
+
+func newResultStorage(s *config.Source) (base.ReadWriter, error) {
+	if s.Kind == "" {
+		return nil, nil
+	}
+	if regex.S3Matcher.MatchString(s.Kind) {
+		return NewS3Storage(s.Value.(config.S3Bucket), s.HystrixCommand), nil
+	} else if regex.GoogleCloudStorageMatcher.MatchString(s.Kind) {
+		return NewWritableGoogleCloudStorage(s.Value.(config.GoogleCloudStorage), s.HystrixCommand)
+	}
+	return nil, fmt.Errorf("result storage of kind %s is not supported", s.Kind)
+}
+
 func getDefaultParams() map[string]string {
 	params := make(map[string]string)
 	for _, param := range config.DefaultParams() {
@@ -94,6 +220,17 @@ func NewGoogleCloudStorage(b config.GoogleCloudStorage, hc base.HystrixCommand)
 	})
 }
 
+// NewWritableGoogleCloudStorage create a new gcs.Storage struct which can also Put objects in the bucket
+// from the config.GoogleCloudStorage and the HystrixCommand
+func NewWritableGoogleCloudStorage(b config.GoogleCloudStorage, hc base.HystrixCommand) (*gcs.Storage, error) {
+	return gcs.NewStorage(gcs.Options{
+		BucketName:      b.Name,
+		CredentialsJSON: []byte(b.CredentialsJSON),
+		Client:          newHystrixClient(hc),
+		Writable:        true,
+	})
+}
+
 // NewWebFolderStorage create a new webfolder.Storage struct from the config.WebFolder and the HystrixCommand
 func NewWebFolderStorage(wf config.WebFolder, hc base.HystrixCommand) *webfolder.Storage {
 	return webfolder.NewStorage(
@@ -1,17 +1,22 @@
 package service
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/gojek/darkroom/pkg/storage/gcs"
 
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/gojek/darkroom/pkg/cache"
 	"github.com/gojek/darkroom/pkg/config"
+	"github.com/gojek/darkroom/pkg/metrics"
 	"github.com/gojek/darkroom/pkg/storage/aws/cloudfront"
 	"github.com/gojek/darkroom/pkg/storage/aws/s3"
 	"github.com/gojek/darkroom/pkg/storage/webfolder"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/font/gofont/goregular"
 )
 
 func TestNewDependencies(t *testing.T) {
@@ -58,6 +63,7 @@ func TestNewDependenciesWithWebFolderStorage(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, deps)
 	assert.IsType(t, &webfolder.Storage{}, deps.Storage)
+	assert.NotNil(t, deps.AssetCache)
 }
 
 func TestNewDependenciesWithS3Storage(t *testing.T) {
@@ -96,3 +102,133 @@ func TestNewDependenciesWithGCSStorage(t *testing.T) {
 	assert.NotNil(t, deps)
 	assert.IsType(t, &gcs.Storage{}, deps.Storage)
 }
+
+func TestNewDependenciesWithPresetsAndSignatureKeys(t *testing.T) {
+	v := config.Viper()
+	v.Set("source.kind", "WebFolder")
+	v.Set("source.baseURL", "https://example.com/path/to/folder")
+	v.Set("presets", map[string]string{"thumbnail": "w=200&h=200&fit=crop"})
+	v.Set("signature.keys", []string{"secret"})
+	config.Update()
+	defer func() {
+		v.Set("presets", map[string]string{})
+		v.Set("signature.keys", []string{})
+		config.Update()
+	}()
+
+	deps, err := NewDependencies(prometheus.NewRegistry())
+	assert.NoError(t, err)
+	assert.NotNil(t, deps.Presets)
+	assert.NotNil(t, deps.Verifier)
+}
+
+func TestNewProcessor(t *testing.T) {
+	v := config.Viper()
+	defer func() {
+		v.Set("text.font", "")
+		config.Update()
+	}()
+	dir := t.TempDir()
+	fontPath := filepath.Join(dir, "font.ttf")
+	invalidFontPath := filepath.Join(dir, "invalid.ttf")
+	_ = ioutil.WriteFile(fontPath, goregular.TTF, 0644)
+	_ = ioutil.WriteFile(invalidFontPath, []byte("invalid"), 0644)
+	cases := []struct {
+		name        string
+		font        string
+		expectedErr bool
+	}{
+		{name: "WithBundledFont"},
+		{name: "WithConfiguredFont", font: fontPath},
+		{name: "WithMissingFont", font: filepath.Join(dir, "missing.ttf"), expectedErr: true},
+		{name: "WithInvalidFont", font: invalidFontPath, expectedErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v.Set("text.font", c.font)
+			config.Update()
+
+			p, err := newProcessor()
+			assert.Equal(t, c.expectedErr, err != nil)
+			assert.Equal(t, c.expectedErr, p == nil)
+		})
+	}
+}
+
+func TestNewCache(t *testing.T) {
+	v := config.Viper()
+	defer func() {
+		v.Set("cache.memory.size", 0)
+		v.Set("cache.disk.path", "")
+		v.Set("cache.disk.size", 0)
+		config.Update()
+	}()
+	dir := t.TempDir()
+	cases := []struct {
+		name         string
+		memorySize   int64
+		diskPath     string
+		diskSize     int64
+		expectedType interface{}
+		expectedErr  bool
+	}{
+		{name: "WithoutCache"},
+		{name: "WithMemoryCache", memorySize: 1024, expectedType: &cache.Memory{}},
+		{name: "WithDiskCache", diskPath: dir, diskSize: 1024, expectedType: &cache.Disk{}},
+		{name: "WithMemoryAndDiskCache", memorySize: 1024, diskPath: dir, diskSize: 1024, expectedType: &cache.Layered{}},
+		{name: "WithInvalidDiskPath", diskPath: "/dev/null/cache", diskSize: 1024, expectedErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v.Set("cache.memory.size", c.memorySize)
+			v.Set("cache.disk.path", c.diskPath)
+			v.Set("cache.disk.size", c.diskSize)
+			config.Update()
+
+			ch, err := newCache(metrics.NoOpMetricService{})
+			assert.Equal(t, c.expectedErr, err != nil)
+			if c.expectedType == nil {
+				assert.Nil(t, ch)
+			} else {
+				assert.IsType(t, c.expectedType, ch)
+			}
+		})
+	}
+}
+
+func TestNewResultStorage(t *testing.T) {
+	cases := []struct {
+		name         string
+		source       *config.Source
+		expectedType interface{}
+		expectedErr  bool
+	}{
+		{name: "WithoutKind", source: &config.Source{}},
+		{
+			name:         "WithS3",
+			source:       &config.Source{Kind: "s3", Value: config.S3Bucket{Name: "results"}},
+			expectedType: &s3.Storage{},
+		},
+		{
+			name:         "WithGoogleCloudStorage",
+			source:       &config.Source{Kind: "gcs", Value: config.GoogleCloudStorage{Name: "results"}},
+			expectedType: &gcs.Storage{},
+		},
+		{
+			name:        "WithWebFolder",
+			source:      &config.Source{Kind: "webfolder", Value: config.WebFolder{BaseURL: "https://example.com"}},
+			expectedErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rs, err := newResultStorage(c.source)
+			assert.Equal(t, c.expectedErr, err != nil)
+			if c.expectedType == nil {
+				assert.Nil(t, rs)
+			} else {
+				assert.IsType(t, c.expectedType, rs)
+			}
+		})
+	}
+}
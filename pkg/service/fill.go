@@ -0,0 +1,28 @@
+package service
+
+import "github.com/gojek/darkroom/pkg/processor"
+
+const (
+	fill       = "fill"
+	pad        = "pad"
+	background = "bg"
+
+	defaultBackgroundColor = "ffffff"
+)
+
+// getFill returns the background of the fit=fill and fit=pad modes from the bg param, which is either a hex color,
+// with or without alpha, or blur for a blurred copy of the image. The image is aligned to the crop Point and the
+// background is white by default.
+func getFill(params map[string]string) *processor.FillAttrs {
+	fa := &processor.FillAttrs{Point: GetCropPoint(params[crop])}
+	if params[background] == blur {
+		fa.Blur = true
+		return fa
+	}
+	c, ok := parseHexColor(params[background])
+	if !ok {
+		c, _ = parseHexColor(defaultBackgroundColor)
+	}
+	fa.Color = c
+	return fa
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFill(t *testing.T) {
+	assert.Equal(t, &processor.FillAttrs{
+		Color: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		Point: processor.PointCenter,
+	}, getFill(map[string]string{}))
+
+	assert.Equal(t, &processor.FillAttrs{
+		Color: color.NRGBA{R: 255, G: 136, A: 128},
+		Point: processor.PointTopLeft,
+	}, getFill(map[string]string{background: "ff880080", crop: "top,left"}))
+
+	assert.Equal(t, &processor.FillAttrs{
+		Color: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		Point: processor.PointCenter,
+	}, getFill(map[string]string{background: "garbage"}))
+
+	assert.Equal(t, &processor.FillAttrs{
+		Blur:  true,
+		Point: processor.PointRight,
+	}, getFill(map[string]string{background: "blur", crop: "right"}))
+}
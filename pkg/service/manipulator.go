@@ -3,6 +3,7 @@ package service
 import (
 	"bytes"
 	"fmt"
+	"image"
 	"math"
 	"strconv"
 	"strings"
@@ -27,6 +28,16 @@ const (
 	compress     = "compress"
 	format       = "format"
 	scale        = "scale"
+	mark         = "mark"
+	markAlpha    = "markalpha"
+	markPos      = "markpos"
+	markScale    = "markscale"
+	quality      = "q"
+	faces        = "faces"
+	focalPoint   = "focalpoint"
+	focalPointX  = "fp-x"
+	focalPointY  = "fp-y"
+	focalPointZ  = "fp-z"
 
 	cropDurationKey      = "cropDuration"
 	decodeDurationKey    = "decodeDuration"
@@ -38,52 +49,89 @@ const (
 	rotateDurationKey    = "rotateDuration"
 	fixOrientationKey    = "fixOrientation"
 	scaleDurationKey     = "scaleDuration"
+	watermarkDurationKey = "watermarkDuration"
+	overlayDurationKey   = "overlayDuration"
+	textDurationKey      = "textDuration"
+	markFacesDurationKey = "markFacesDuration"
+	extractDurationKey   = "extractDuration"
+	fillDurationKey      = "fillDuration"
 )
 
 // Manipulator interface sets the contract on the implementation for common processing support in darkroom
 type Manipulator interface {
-	// Process takes ProcessSpec as an argument and returns []byte, error
-	Process(spec processSpec) ([]byte, error)
+	// Process takes ProcessSpec as an argument and returns the encoded image, the format it was encoded in and error
+	Process(spec processSpec) ([]byte, string, error)
 
 	// HasDefaultParams returns true if defaultParams are present, returns false otherwise
 	HasDefaultParams() bool
 }
 
 type manipulator struct {
-	processor     processor.Processor
-	defaultParams map[string]string
-	metricService metrics.MetricService
+	processor          processor.Processor
+	defaultParams      map[string]string
+	metricService      metrics.MetricService
+	maxAnimationPixels int64
 }
 
-// Process takes ProcessSpec as an argument and returns []byte, error
+// ManipulatorOption represents builder function for the Manipulator
+type ManipulatorOption func(*manipulator)
+
+// Process takes ProcessSpec as an argument and returns the encoded image, the format it was encoded in and error
 // This manipulator uses bild to do the actual image manipulations
-func (m *manipulator) Process(spec processSpec) ([]byte, error) {
+func (m *manipulator) Process(spec processSpec) ([]byte, string, error) {
 	params := spec.Params
 	params = joinParams(params, m.defaultParams)
 	var err error
 	t := time.Now()
-	data, f, err := m.processor.Decode(spec.ImageData)
+	data, f, err := m.decode(spec.ImageData, params)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	if spec.TargetFormat != "" {
+	// The requested format takes precedence over auto=format, unless the processor can't encode it
+	target := spec.TargetFormat != "" && m.processor.CanEncode(spec.TargetFormat)
+	if target {
 		f = spec.TargetFormat
 	}
 	m.metricService.TrackDuration(decodeDurationKey, t, spec.ImageData)
+	if len(params[rect]) != 0 {
+		t = time.Now()
+		data, err = m.extract(data, params[rect])
+		if err != nil {
+			return nil, "", err
+		}
+		m.metricService.TrackDuration(extractDurationKey, t, spec.ImageData)
+	}
+	if err = m.checkAnimationSize(data, params); err != nil {
+		return nil, "", err
+	}
 	if params[fit] == crop {
 		t = time.Now()
-		data = m.processor.Crop(data, CleanInt(params[width]), CleanInt(params[height]), GetCropPoint(params[crop]))
+		if params[crop] == focalPoint {
+			data = m.processor.CropFocalPoint(data, CleanInt(params[width]), CleanInt(params[height]), getFocalPoint(params))
+		} else {
+			data = m.processor.Crop(data, CleanInt(params[width]), CleanInt(params[height]), GetCropPoint(params[crop]))
+		}
 		m.metricService.TrackDuration(cropDurationKey, t, spec.ImageData)
 	} else if params[fit] == scale {
 		t = time.Now()
 		data = m.processor.Scale(data, CleanInt(params[width]), CleanInt(params[height]))
 		m.metricService.TrackDuration(scaleDurationKey, t, spec.ImageData)
+	} else if params[fit] == fill || params[fit] == pad {
+		t = time.Now()
+		data = m.processor.Fill(data, CleanInt(params[width]), CleanInt(params[height]), getFill(params))
+		m.metricService.TrackDuration(fillDurationKey, t, spec.ImageData)
 	} else if len(params[fit]) == 0 && (CleanInt(params[width]) != 0 || CleanInt(params[height]) != 0) {
 		t = time.Now()
 		data = m.processor.Resize(data, CleanInt(params[width]), CleanInt(params[height]))
 		m.metricService.TrackDuration(resizeDurationKey, t, spec.ImageData)
 	}
 
+	if params[faces] == "1" {
+		t = time.Now()
+		data = m.processor.MarkFaces(data)
+		m.metricService.TrackDuration(markFacesDurationKey, t, spec.ImageData)
+	}
+
 	if params[mono] == blackHexCode {
 		t = time.Now()
 		data = m.processor.GrayScale(data)
@@ -102,13 +150,8 @@ func (m *manipulator) Process(spec processSpec) ([]byte, error) {
 			t = time.Now()
 			data = m.processor.FixOrientation(data, orientation)
 			m.metricService.TrackDuration(fixOrientationKey, t, spec.ImageData)
-		} else if a == format {
-			w := spec.IsWebPSupported()
-			if w {
-				f = processor.ExtensionWebP
-			} else if f == processor.ExtensionWebP {
-				f = processor.ExtensionPNG
-			}
+		} else if a == format && !target {
+			f = m.negotiateFormat(spec, data, f)
 		}
 	}
 
@@ -124,12 +167,142 @@ func (m *manipulator) Process(spec processSpec) ([]byte, error) {
 		m.metricService.TrackDuration(rotateDurationKey, t, spec.ImageData)
 	}
 
+	overlays, err := getOverlays(params, spec.Assets)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(overlays) != 0 {
+		t = time.Now()
+		data, err = m.processor.Overlay(data, overlays)
+		if err != nil {
+			return nil, "", err
+		}
+		m.metricService.TrackDuration(overlayDurationKey, t, spec.ImageData)
+	}
+
+	if ta := getText(params); ta != nil {
+		t = time.Now()
+		data, err = m.processor.Text(data, ta)
+		if err != nil {
+			return nil, "", err
+		}
+		m.metricService.TrackDuration(textDurationKey, t, spec.ImageData)
+	}
+
+	if len(params[mark]) != 0 {
+		overlay, ok := spec.Assets[cleanAssetPath(params[mark])]
+		if !ok {
+			return nil, "", fmt.Errorf("watermark %s is not available", params[mark])
+		}
+		t = time.Now()
+		data, err = m.processor.Watermark(data, &processor.OverlayAttrs{
+			Img:             overlay,
+			Point:           getWatermarkPoint(params[markPos]),
+			WidthPercentage: getPercentage(params[markScale]),
+		}, getOpacity(params[markAlpha]))
+		if err != nil {
+			return nil, "", err
+		}
+		m.metricService.TrackDuration(watermarkDurationKey, t, spec.ImageData)
+	}
+
+	// The images in input only formats, eg: tiff, are encoded as png
+	if !m.processor.CanEncode(f) {
+		f = processor.ExtensionPNG
+	}
 	t = time.Now()
-	src, err := m.processor.Encode(data, f)
-	if err == nil {
-		m.metricService.TrackDuration(encodeDurationKey, t, spec.ImageData)
+	src, err := m.encode(data, f, getQuality(params[quality]))
+	if err != nil {
+		return src, "", err
+	}
+	m.metricService.TrackDuration(encodeDurationKey, t, spec.ImageData)
+	// The processor may encode in a different format than requested, e.g. opaque png images are encoded as jpeg
+	if ef := processor.DetectFormat(src); ef != "" {
+		f = ef
+	}
+	return src, f, nil
+}
+
+// decode rasterizes vector images at a size which covers the requested width and height, so that they are only
+// scaled down by the rest of the pipeline, and decodes the other images.
+// Vector images are rasterized at their own size if a rect is requested, as its pixels are relative to that size.
+func (m *manipulator) decode(data []byte, params map[string]string) (image.Image, string, error) {
+	if processor.DetectFormat(data) == processor.ExtensionSVG {
+		if len(params[rect]) != 0 {
+			return m.processor.Rasterize(data, 0, 0)
+		}
+		return m.processor.Rasterize(data, CleanInt(params[width]), CleanInt(params[height]))
+	}
+	return m.processor.Decode(data)
+}
+
+// extract cuts the region of the rect param out of the image, an ErrInvalidRect is returned if the rect is not
+// inside the bounds of the image
+func (m *manipulator) extract(img image.Image, input string) (image.Image, error) {
+	r, err := getRect(input, img.Bounds())
+	if err != nil {
+		return nil, err
 	}
-	return src, err
+	return m.processor.Extract(img, r), nil
+}
+
+// encode encodes the image with the requested quality q if it is set, and with the default quality of the format
+// otherwise
+// checkAnimationSize returns native.ErrAnimationTooLarge if the frames of an animation would exceed the limit of
+// pixels once they are resized, cropped, scaled or filled to the width and height of the params. The missing
+// dimension is derived from the aspect ratio of the frames, so the check is done before any frame is allocated.
+func (m *manipulator) checkAnimationSize(img image.Image, params map[string]string) error {
+	a, ok := img.(*processor.Animation)
+	if !ok || len(a.Frames) == 0 {
+		return nil
+	}
+	w, h := int64(CleanInt(params[width])), int64(CleanInt(params[height]))
+	if w == 0 && h == 0 {
+		return nil
+	}
+	b := a.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return nil
+	}
+	if w == 0 {
+		w = h * int64(b.Dx()) / int64(b.Dy())
+	} else if h == 0 {
+		h = w * int64(b.Dy()) / int64(b.Dx())
+	}
+	// A resize with both dimensions fits inside them, so w x h is an upper bound of its frames too
+	frames := int64(len(a.Frames))
+	if w*h <= int64(b.Dx()*b.Dy()) || w*h <= m.maxAnimationPixels/frames {
+		return nil
+	}
+	return fmt.Errorf("%w: the %d frames of %dx%d pixels exceed the limit of %d pixels",
+		native.ErrAnimationTooLarge, frames, w, h, m.maxAnimationPixels)
+}
+
+func (m *manipulator) encode(img image.Image, f string, q int) ([]byte, error) {
+	if q != 0 {
+		return m.processor.EncodeWithQuality(img, f, q)
+	}
+	return m.processor.Encode(img, f)
+}
+
+// negotiateFormat picks the first format accepted by the caller which can be used: avif if the processor can
+// encode it, or webp, and otherwise the original format f, falling back to png if f is a format the caller does
+// not accept. The accepted formats are expected in the order of the preference of the caller.
+// Animations are never encoded as avif as only its still images are supported.
+func (m *manipulator) negotiateFormat(spec processSpec, img image.Image, f string) string {
+	_, animated := img.(*processor.Animation)
+	for _, af := range spec.formats {
+		switch {
+		case af == "image/avif" && !animated && m.processor.CanEncode(processor.ExtensionAVIF):
+			return processor.ExtensionAVIF
+		case af == "image/webp":
+			return processor.ExtensionWebP
+		}
+	}
+	if f == processor.ExtensionWebP || f == processor.ExtensionAVIF {
+		return processor.ExtensionPNG
+	}
+	return f
 }
 
 // HasDefaultParams returns true if defaultParams are present, returns false otherwise
@@ -189,17 +362,93 @@ func GetCropPoint(input string) processor.Point {
 		return processor.PointBottomLeft
 	case "bottom,right":
 		return processor.PointBottomRight
+	case "entropy":
+		return processor.PointEntropy
+	case "attention":
+		return processor.PointAttention
+	case "faces":
+		return processor.PointFaces
 	default:
 		return processor.PointCenter
 	}
 }
 
+// getFocalPoint returns the FocalPoint of the crop from the fp-x, fp-y and fp-z params. The coordinates are clamped
+// from 0 to 1 and are at the center by default, the zoom is clamped from 1 to 100 and is 1 by default.
+func getFocalPoint(params map[string]string) processor.FocalPoint {
+	return processor.FocalPoint{
+		X: getFloatInRange(params[focalPointX], 0.5, 0, 1),
+		Y: getFloatInRange(params[focalPointY], 0.5, 0, 1),
+		Z: getFloatInRange(params[focalPointZ], 1, 1, 100),
+	}
+}
+
+// getFloatInRange takes a string and returns the float64 clamped from min to max, or def if it is not a number
+func getFloatInRange(input string, def, min, max float64) float64 {
+	val, err := strconv.ParseFloat(input, 64)
+	if err != nil || math.IsNaN(val) {
+		return def
+	}
+	return math.Max(min, math.Min(val, max))
+}
+
+// getWatermarkPoint returns the Point the watermark is anchored to, it is the bottom right corner by default
+func getWatermarkPoint(input string) processor.Point {
+	if input == "" {
+		return processor.PointBottomRight
+	}
+	return GetCropPoint(input)
+}
+
+// getPercentage returns the size of an overlay as a percentage of the size of the image, not greater than 100.
+// The overlay keeps its own size if it is not set.
+func getPercentage(input string) float64 {
+	return math.Min(CleanFloat(input, 1000), 100)
+}
+
+// getQuality takes the requested quality of the encoded image and returns it clamped from 1 to 100,
+// 0 is returned if it is not set or invalid so that the default quality of the format is used
+func getQuality(input string) int {
+	q, err := strconv.Atoi(input)
+	if err != nil || q < 1 {
+		return 0
+	} else if q > 100 {
+		return 100
+	}
+	return q
+}
+
+// getOpacity takes the alpha of an overlay as a percentage and returns its opacity,
+// the overlay is opaque by default
+func getOpacity(input string) uint8 {
+	alpha, err := strconv.Atoi(input)
+	if err != nil || alpha > 100 {
+		alpha = 100
+	} else if alpha < 0 {
+		alpha = 0
+	}
+	return uint8(alpha * math.MaxUint8 / 100)
+}
+
 // NewManipulator takes in a Processor interface and returns a new Manipulator
 func NewManipulator(processor processor.Processor, defaultParams map[string]string,
-	metricService metrics.MetricService) Manipulator {
-	return &manipulator{
-		processor:     processor,
-		defaultParams: defaultParams,
-		metricService: metricService,
+	metricService metrics.MetricService, opts ...ManipulatorOption) Manipulator {
+	m := &manipulator{
+		processor:          processor,
+		defaultParams:      defaultParams,
+		metricService:      metricService,
+		maxAnimationPixels: native.DefaultMaxAnimationPixels,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// WithMaxAnimationPixels is a builder function to set the limit of the number of frames times the number of
+// pixels of the animations once they are resized, cropped, scaled or filled by the Manipulator
+func WithMaxAnimationPixels(maxPixels int64) ManipulatorOption {
+	return func(m *manipulator) {
+		m.maxAnimationPixels = maxPixels
 	}
 }
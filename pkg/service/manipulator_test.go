@@ -1,8 +1,10 @@
 package service
 
 import (
+	"bytes"
 	"errors"
 	"image"
+	"image/color"
 	"io/ioutil"
 	"testing"
 
@@ -32,9 +34,10 @@ func TestManipulator_Process_ReturnsImageAsPNGIfCallerDoesNOTSupportWebP(t *test
 		WithImageData(img).
 		WithParams(map[string]string{auto: format}).
 		Build()
-	img, err := m.Process(s)
+	img, f, err := m.Process(s)
 	assert.Nil(t, err)
 	assert.Equal(t, expectedImg, img)
+	assert.Equal(t, processor.ExtensionPNG, f)
 }
 
 // Integration test to verify the flow of PNG image is requested with having support of WebP on client's side
@@ -51,9 +54,10 @@ func TestManipulator_Process_ReturnsImageAsWebPIfCallerSupportsWebP(t *testing.T
 		WithParams(map[string]string{auto: format}).
 		WithFormats([]string{"image/webp"}).
 		Build()
-	img, err := m.Process(s)
+	img, f, err := m.Process(s)
 	assert.Nil(t, err)
 	assert.Equal(t, expectedImg, img)
+	assert.Equal(t, processor.ExtensionWebP, f)
 }
 
 // Integration test to verify the flow of encoding with target format
@@ -69,13 +73,14 @@ func TestManipulator_Process_ReturnsImageWithTargetFormat(t *testing.T) {
 		WithImageData(img).
 		WithTargetFormat(ext).
 		Build()
-	img, err := m.Process(s)
+	img, f, err := m.Process(s)
 	assert.Nil(t, err)
 	assert.Equal(t, expectedImg, img)
+	assert.Equal(t, processor.ExtensionJPEG, f)
 }
 
 func TestManipulator_Process(t *testing.T) {
-	mp := &mockProcessor{}
+	mp := newMockProcessor()
 	ms := &metrics.MockMetricService{}
 	m := NewManipulator(mp, nil, ms)
 	params := make(map[string]string)
@@ -85,11 +90,11 @@ func TestManipulator_Process(t *testing.T) {
 
 	// Test flow for Decode error from Processor
 	mp.On("Decode", mock.Anything).Return(nil, "", errors.New("decoding error"))
-	_, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	_, _, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
 	mp.AssertExpectations(t)
 
 	// Create new struct for asserting expectations
-	mp = &mockProcessor{}
+	mp = newMockProcessor()
 	ms = &metrics.MockMetricService{}
 	m = NewManipulator(mp, nil, ms)
 	mp.On("Decode", input).Return(decoded, "png", nil)
@@ -99,52 +104,172 @@ func TestManipulator_Process(t *testing.T) {
 	params[fit] = crop
 	params[width] = "100"
 	params[height] = "100"
-	_, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	_, _, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
 
 	mp.On("Resize", decoded, 100, 100).Return(decoded, nil)
 	params = make(map[string]string)
 	params[width] = "100"
 	params[height] = "100"
-	_, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	_, _, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
 
 	mp.On("Scale", decoded, 100, 100).Return(decoded, nil)
 	params = make(map[string]string)
 	params[width] = "100"
 	params[height] = "100"
 	params[fit] = scale
-	_, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	_, _, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
 
 	mp.On("GrayScale", decoded).Return(decoded, nil)
 	params = make(map[string]string)
 	params[mono] = blackHexCode
-	_, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	_, _, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
 
 	mp.On("Blur", decoded, 60.0).Return(decoded, nil)
 	params = make(map[string]string)
 	params[blur] = "60"
-	_, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	_, _, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
 
 	mp.On("Flip", decoded, "v").Return(decoded, nil)
 	params = make(map[string]string)
 	params[flip] = "v"
-	_, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	_, _, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
 
 	mp.On("Rotate", decoded, 90.5).Return(decoded, nil)
 	params = map[string]string{rotate: "90.5"}
-	_, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	_, _, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
 
 	mp.On("FixOrientation", decoded, 0).Return(decoded)
 	params = map[string]string{auto: compress}
-	_, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	_, _, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
 
 	mp.On("Decode", input).Return(decoded, processor.ExtensionWebP, nil)
 	params = map[string]string{auto: format}
-	_, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	_, _, _ = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
 
 	// Assert all expectations once here
 	mp.AssertExpectations(t)
 }
 
+func TestManipulator_ProcessWithWatermark(t *testing.T) {
+	mp := newMockProcessor()
+	ms := &metrics.MockMetricService{}
+	m := NewManipulator(mp, nil, ms)
+	input := []byte("inputData")
+	markData := []byte("markData")
+	decoded := &image.RGBA{Pix: []uint8{1, 2, 3, 4}}
+	watermarked := &image.RGBA{Pix: []uint8{5, 6, 7, 8}}
+	params := map[string]string{mark: "logos/brand.png", markAlpha: "60", markPos: "top,left", markScale: "20"}
+	mp.On("Decode", input).Return(decoded, "png", nil)
+	mp.On("Watermark", decoded, &processor.OverlayAttrs{
+		Img:             markData,
+		Point:           processor.PointTopLeft,
+		WidthPercentage: 20,
+	}, uint8(153)).Return(watermarked, nil)
+	mp.On("Encode", watermarked, "png").Return([]byte("outputData"), nil)
+	ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+
+	out, _, err := m.Process(NewSpecBuilder().
+		WithImageData(input).
+		WithParams(params).
+		WithAssets(map[string][]byte{"/logos/brand.png": markData}).
+		Build())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("outputData"), out)
+	mp.AssertExpectations(t)
+	ms.AssertCalled(t, "TrackDuration", watermarkDurationKey, mock.Anything, input)
+
+	_, _, err = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	assert.Error(t, err)
+
+	mp = newMockProcessor()
+	m = NewManipulator(mp, nil, ms)
+	mp.On("Decode", input).Return(decoded, "png", nil)
+	mp.On("Watermark", decoded, mock.Anything, mock.Anything).Return(nil, errors.New("watermark error"))
+	_, _, err = m.Process(NewSpecBuilder().
+		WithImageData(input).
+		WithParams(params).
+		WithAssets(map[string][]byte{"/logos/brand.png": markData}).
+		Build())
+	assert.EqualError(t, err, "watermark error")
+	mp.AssertNotCalled(t, "Encode", mock.Anything, mock.Anything)
+}
+
+func TestManipulator_ProcessWithOverlays(t *testing.T) {
+	mp := newMockProcessor()
+	ms := &metrics.MockMetricService{}
+	m := NewManipulator(mp, nil, ms)
+	input := []byte("inputData")
+	decoded := &image.RGBA{Pix: []uint8{1, 2, 3, 4}}
+	overlaid := &image.RGBA{Pix: []uint8{5, 6, 7, 8}}
+	watermarked := &image.RGBA{Pix: []uint8{9, 10, 11, 12}}
+	params := map[string]string{"overlay1": "/badges/sale.png", "overlay1pos": "top", mark: "/logos/brand.png"}
+	assets := map[string][]byte{"/badges/sale.png": []byte("sale"), "/logos/brand.png": []byte("brand")}
+	mp.On("Decode", input).Return(decoded, "jpeg", nil)
+	mp.On("Overlay", decoded, []*processor.OverlayAttrs{
+		{Img: []byte("sale"), Point: processor.PointTop, Opacity: 255},
+	}).Return(overlaid, nil)
+	mp.On("Watermark", overlaid, mock.Anything, uint8(255)).Return(watermarked, nil)
+	mp.On("Encode", watermarked, "jpeg").Return([]byte("outputData"), nil)
+	ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+
+	out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).WithAssets(assets).Build())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("outputData"), out)
+	mp.AssertExpectations(t)
+	ms.AssertCalled(t, "TrackDuration", overlayDurationKey, mock.Anything, input)
+
+	mp = newMockProcessor()
+	m = NewManipulator(mp, nil, ms)
+	mp.On("Decode", input).Return(decoded, "jpeg", nil)
+	mp.On("Overlay", decoded, mock.Anything).Return(nil, errors.New("overlay error"))
+	_, _, err = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).WithAssets(assets).Build())
+	assert.EqualError(t, err, "overlay error")
+	mp.AssertNotCalled(t, "Encode", mock.Anything, mock.Anything)
+}
+
+func TestManipulator_ProcessWithText(t *testing.T) {
+	mp := newMockProcessor()
+	ms := &metrics.MockMetricService{}
+	m := NewManipulator(mp, nil, ms)
+	input := []byte("inputData")
+	decoded := &image.RGBA{Pix: []uint8{1, 2, 3, 4}}
+	captioned := &image.RGBA{Pix: []uint8{5, 6, 7, 8}}
+	params := map[string]string{txt: "SAMPLE", txtSize: "32", txtTile: "1"}
+	mp.On("Decode", input).Return(decoded, "jpeg", nil)
+	mp.On("Text", decoded, getText(params)).Return(captioned, nil)
+	mp.On("Encode", captioned, "jpeg").Return([]byte("outputData"), nil)
+	ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+
+	out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("outputData"), out)
+	mp.AssertExpectations(t)
+	ms.AssertCalled(t, "TrackDuration", textDurationKey, mock.Anything, input)
+
+	mp = newMockProcessor()
+	m = NewManipulator(mp, nil, ms)
+	mp.On("Decode", input).Return(decoded, "jpeg", nil)
+	mp.On("Text", decoded, mock.Anything).Return(nil, errors.New("text error"))
+	_, _, err = m.Process(NewSpecBuilder().WithImageData(input).WithParams(params).Build())
+	assert.EqualError(t, err, "text error")
+	mp.AssertNotCalled(t, "Encode", mock.Anything, mock.Anything)
+}
+
+func TestGetWatermarkAttrs(t *testing.T) {
+	assert.Equal(t, processor.PointBottomRight, getWatermarkPoint(""))
+	assert.Equal(t, processor.PointTop, getWatermarkPoint("top"))
+	assert.Equal(t, uint8(255), getOpacity(""))
+	assert.Equal(t, uint8(255), getOpacity("150"))
+	assert.Equal(t, uint8(127), getOpacity("50"))
+	assert.Equal(t, uint8(0), getOpacity("-10"))
+	assert.Equal(t, 0.0, getPercentage(""))
+	assert.Equal(t, 25.5, getPercentage("25.5"))
+	assert.Equal(t, 100.0, getPercentage("250"))
+}
+
 func TestGetParams(t *testing.T) {
 	cases := []struct {
 		params        map[string]string
@@ -187,6 +312,9 @@ func TestGetCropPoint(t *testing.T) {
 	assert.Equal(t, processor.PointBottom, GetCropPoint("bottom"))
 	assert.Equal(t, processor.PointBottomLeft, GetCropPoint("bottom,left"))
 	assert.Equal(t, processor.PointBottomRight, GetCropPoint("bottom,right"))
+	assert.Equal(t, processor.PointEntropy, GetCropPoint("entropy"))
+	assert.Equal(t, processor.PointAttention, GetCropPoint("attention"))
+	assert.Equal(t, processor.PointFaces, GetCropPoint("faces"))
 	assert.Equal(t, processor.PointCenter, GetCropPoint("random"))
 }
 
@@ -200,6 +328,402 @@ func TestCleanInt(t *testing.T) {
 	assert.Equal(t, 0, CleanInt("-234"))
 }
 
+func TestManipulator_ProcessWithFaces(t *testing.T) {
+	input := []byte("inputData")
+	decoded := &image.RGBA{Pix: []uint8{1, 2, 3, 4}}
+	cropped := &image.RGBA{Pix: []uint8{5, 6, 7, 8}}
+	marked := &image.RGBA{Pix: []uint8{9, 10, 11, 12}}
+	cases := []struct {
+		name     string
+		params   map[string]string
+		crop     bool
+		mark     bool
+		expected image.Image
+	}{
+		{name: "CropAndMarkFaces", params: map[string]string{width: "100", height: "100", fit: crop, crop: "faces", faces: "1"},
+			crop: true, mark: true, expected: marked},
+		{name: "CropFaces", params: map[string]string{width: "100", height: "100", fit: crop, crop: "faces"},
+			crop: true, expected: cropped},
+		{name: "DontMarkFaces", params: map[string]string{faces: "0"}, expected: decoded},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mp := newMockProcessor()
+			ms := &metrics.MockMetricService{}
+			ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+			m := NewManipulator(mp, nil, ms)
+			mp.On("Decode", input).Return(decoded, "jpeg", nil)
+			if c.crop {
+				mp.On("Crop", decoded, 100, 100, processor.PointFaces).Return(cropped)
+			}
+			if c.mark {
+				mp.On("MarkFaces", cropped).Return(marked)
+			}
+			mp.On("Encode", c.expected, "jpeg").Return([]byte("outputData"), nil)
+
+			out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(c.params).Build())
+
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("outputData"), out)
+			mp.AssertExpectations(t)
+			if c.mark {
+				ms.AssertCalled(t, "TrackDuration", markFacesDurationKey, mock.Anything, input)
+			} else {
+				mp.AssertNotCalled(t, "MarkFaces", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestManipulator_ProcessWithFocalPoint(t *testing.T) {
+	input := []byte("inputData")
+	decoded := &image.RGBA{Pix: []uint8{1, 2, 3, 4}}
+	cropped := &image.RGBA{Pix: []uint8{5, 6, 7, 8}}
+	cases := []struct {
+		name     string
+		params   map[string]string
+		expected processor.FocalPoint
+	}{
+		{name: "FocalPoint", params: map[string]string{width: "100", height: "50", fit: crop, crop: focalPoint,
+			focalPointX: "0.3", focalPointY: "0.6", focalPointZ: "2"}, expected: processor.FocalPoint{X: 0.3, Y: 0.6, Z: 2}},
+		{name: "DefaultFocalPoint", params: map[string]string{width: "100", height: "50", fit: crop, crop: focalPoint},
+			expected: processor.FocalPoint{X: 0.5, Y: 0.5, Z: 1}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mp := newMockProcessor()
+			ms := &metrics.MockMetricService{}
+			ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+			m := NewManipulator(mp, nil, ms)
+			mp.On("Decode", input).Return(decoded, "jpeg", nil)
+			mp.On("CropFocalPoint", decoded, 100, 50, c.expected).Return(cropped)
+			mp.On("Encode", cropped, "jpeg").Return([]byte("outputData"), nil)
+
+			out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(c.params).Build())
+
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("outputData"), out)
+			mp.AssertExpectations(t)
+			mp.AssertNotCalled(t, "Crop", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			ms.AssertCalled(t, "TrackDuration", cropDurationKey, mock.Anything, input)
+		})
+	}
+}
+
+func TestGetFocalPoint(t *testing.T) {
+	assert.Equal(t, processor.FocalPoint{X: 0.5, Y: 0.5, Z: 1}, getFocalPoint(map[string]string{}))
+	assert.Equal(t, processor.FocalPoint{X: 0.3, Y: 0.6, Z: 1.5},
+		getFocalPoint(map[string]string{focalPointX: "0.3", focalPointY: "0.6", focalPointZ: "1.5"}))
+	assert.Equal(t, processor.FocalPoint{X: 0, Y: 1, Z: 1},
+		getFocalPoint(map[string]string{focalPointX: "-2", focalPointY: "3", focalPointZ: "0.5"}))
+	assert.Equal(t, processor.FocalPoint{X: 0.5, Y: 0.5, Z: 100},
+		getFocalPoint(map[string]string{focalPointX: "garbage", focalPointY: "NaN", focalPointZ: "500"}))
+}
+
+func TestManipulator_ProcessWithRect(t *testing.T) {
+	input := []byte("inputData")
+	decoded := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	extracted := &image.RGBA{Pix: []uint8{5, 6, 7, 8}}
+	resized := &image.RGBA{Pix: []uint8{9, 10, 11, 12}}
+	cases := []struct {
+		name     string
+		params   map[string]string
+		expected image.Rectangle
+		err      string
+	}{
+		{name: "RectAndWidth", params: map[string]string{rect: "10,20,50%,50%", width: "100"}, expected: image.Rect(10, 20, 210, 120)},
+		{name: "Rect", params: map[string]string{rect: "0,0,10,10"}, expected: image.Rect(0, 0, 10, 10)},
+		{name: "RectOutsideImage", params: map[string]string{rect: "300,0,200,100"},
+			err: "invalid rect: 300,0,200,100 is not inside the 400x200 image"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mp := newMockProcessor()
+			ms := &metrics.MockMetricService{}
+			ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+			m := NewManipulator(mp, nil, ms)
+			mp.On("Decode", input).Return(decoded, "jpeg", nil)
+			mp.On("Extract", decoded, c.expected).Return(extracted)
+			mp.On("Resize", extracted, 100, 0).Return(resized)
+			mp.On("Encode", mock.Anything, "jpeg").Return([]byte("outputData"), nil)
+
+			out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(c.params).Build())
+
+			if c.err != "" {
+				assert.ErrorIs(t, err, ErrInvalidRect)
+				assert.EqualError(t, err, c.err)
+				mp.AssertNotCalled(t, "Extract", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("outputData"), out)
+			mp.AssertCalled(t, "Extract", decoded, c.expected)
+			ms.AssertCalled(t, "TrackDuration", extractDurationKey, mock.Anything, input)
+		})
+	}
+}
+
+func TestManipulator_ProcessWithFill(t *testing.T) {
+	input := []byte("inputData")
+	decoded := &image.RGBA{Pix: []uint8{1, 2, 3, 4}}
+	filled := &image.RGBA{Pix: []uint8{5, 6, 7, 8}}
+	cases := []struct {
+		name     string
+		params   map[string]string
+		expected *processor.FillAttrs
+	}{
+		{name: "FillWithColor", params: map[string]string{width: "200", height: "100", fit: fill, background: "ff000080", crop: "left"},
+			expected: &processor.FillAttrs{Color: color.NRGBA{R: 255, A: 128}, Point: processor.PointLeft}},
+		{name: "PadWithBlur", params: map[string]string{width: "200", height: "100", fit: pad, background: "blur"},
+			expected: &processor.FillAttrs{Blur: true, Point: processor.PointCenter}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mp := newMockProcessor()
+			ms := &metrics.MockMetricService{}
+			ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+			m := NewManipulator(mp, nil, ms)
+			mp.On("Decode", input).Return(decoded, "png", nil)
+			mp.On("Fill", decoded, 200, 100, c.expected).Return(filled)
+			mp.On("Encode", filled, "png").Return([]byte("outputData"), nil)
+
+			out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(c.params).Build())
+
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("outputData"), out)
+			mp.AssertExpectations(t)
+			ms.AssertCalled(t, "TrackDuration", fillDurationKey, mock.Anything, input)
+		})
+	}
+}
+
+func TestManipulator_ProcessWithLargeAnimation(t *testing.T) {
+	input := []byte("inputData")
+	frame := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	animation := &processor.Animation{Frames: []image.Image{frame, frame}, Delays: []int{10, 10}}
+	cases := []struct {
+		name   string
+		params map[string]string
+	}{
+		{name: "Resize", params: map[string]string{width: "100"}},
+		{name: "ResizeWithHeight", params: map[string]string{height: "50"}},
+		{name: "Crop", params: map[string]string{width: "40", height: "40", fit: crop}},
+		{name: "Scale", params: map[string]string{width: "40", height: "40", fit: scale}},
+		{name: "Fill", params: map[string]string{width: "40", height: "40", fit: fill}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mp := newMockProcessor()
+			ms := &metrics.MockMetricService{}
+			ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+			m := NewManipulator(mp, nil, ms, WithMaxAnimationPixels(2000))
+			mp.On("Decode", input).Return(animation, "gif", nil)
+
+			out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(c.params).Build())
+
+			assert.ErrorIs(t, err, native.ErrAnimationTooLarge)
+			assert.Nil(t, out)
+			mp.AssertExpectations(t)
+			for _, method := range []string{"Resize", "Crop", "Scale", "Fill"} {
+				mp.AssertNotCalled(t, method)
+			}
+		})
+	}
+
+	t.Run("WithinTheLimit", func(t *testing.T) {
+		mp := newMockProcessor()
+		ms := &metrics.MockMetricService{}
+		ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+		m := NewManipulator(mp, nil, ms, WithMaxAnimationPixels(2000))
+		mp.On("Decode", input).Return(animation, "gif", nil)
+		mp.On("Scale", animation, 30, 30).Return(animation)
+		mp.On("Encode", animation, "gif").Return([]byte("outputData"), nil)
+
+		out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(map[string]string{
+			width: "30", height: "30", fit: scale,
+		}).Build())
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("outputData"), out)
+		mp.AssertExpectations(t)
+	})
+
+	t.Run("Downscale", func(t *testing.T) {
+		mp := newMockProcessor()
+		ms := &metrics.MockMetricService{}
+		ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+		// The source animation is checked while it is decoded, downscaling it never exceeds the limit
+		m := NewManipulator(mp, nil, ms, WithMaxAnimationPixels(100))
+		mp.On("Decode", input).Return(animation, "gif", nil)
+		mp.On("Resize", animation, 10, 0).Return(animation)
+		mp.On("Encode", animation, "gif").Return([]byte("outputData"), nil)
+
+		out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(map[string]string{width: "10"}).Build())
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("outputData"), out)
+		mp.AssertExpectations(t)
+	})
+}
+
+func TestGetQuality(t *testing.T) {
+	assert.Equal(t, 1, getQuality("1"))
+	assert.Equal(t, 75, getQuality("75"))
+	assert.Equal(t, 100, getQuality("100"))
+	assert.Equal(t, 100, getQuality("250"))
+	assert.Equal(t, 0, getQuality("0"))
+	assert.Equal(t, 0, getQuality("-10"))
+	assert.Equal(t, 0, getQuality("garbage"))
+	assert.Equal(t, 0, getQuality(""))
+}
+
+func TestManipulator_ProcessWithQuality(t *testing.T) {
+	input := []byte("inputData")
+	decoded := &image.RGBA{Pix: []uint8{1, 2, 3, 4}}
+	cases := []struct {
+		name     string
+		q        string
+		expected int
+	}{
+		{name: "Quality", q: "40", expected: 40},
+		{name: "QualityAboveMax", q: "150", expected: 100},
+		{name: "ZeroQuality", q: "0"},
+		{name: "InvalidQuality", q: "high"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mp := newMockProcessor()
+			ms := &metrics.MockMetricService{}
+			ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+			m := NewManipulator(mp, nil, ms)
+			mp.On("Decode", input).Return(decoded, "jpeg", nil)
+			if c.expected != 0 {
+				mp.On("EncodeWithQuality", decoded, "jpeg", c.expected).Return([]byte("outputData"), nil)
+			} else {
+				mp.On("Encode", decoded, "jpeg").Return([]byte("outputData"), nil)
+			}
+
+			out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(map[string]string{quality: c.q}).Build())
+
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("outputData"), out)
+			mp.AssertExpectations(t)
+		})
+	}
+}
+
+func TestManipulator_ProcessWithFormatNegotiation(t *testing.T) {
+	input := []byte("inputData")
+	decoded := &image.RGBA{Pix: []uint8{1, 2, 3, 4}}
+	animation := &processor.Animation{Frames: []image.Image{decoded, decoded}, Delays: []int{10, 10}}
+	cases := []struct {
+		name     string
+		img      image.Image
+		decoded  string
+		target   string
+		formats  []string
+		canAVIF  bool
+		expected string
+	}{
+		{name: "AVIFAccepted", img: decoded, decoded: "png", formats: []string{"image/avif", "image/webp"}, canAVIF: true, expected: "avif"},
+		{name: "AVIFAcceptedButNotEncodable", img: decoded, decoded: "png", formats: []string{"image/avif", "image/webp"}, expected: "webp"},
+		{name: "AVIFAcceptedWithoutWebP", img: decoded, decoded: "jpeg", formats: []string{"image/avif"}, expected: "jpeg"},
+		{name: "WebPAccepted", img: decoded, decoded: "jpeg", formats: []string{"image/webp"}, canAVIF: true, expected: "webp"},
+		{name: "WebPPreferred", img: decoded, decoded: "png", formats: []string{"image/webp", "image/avif"}, canAVIF: true, expected: "webp"},
+		{name: "NothingAccepted", img: decoded, decoded: "jpeg", canAVIF: true, expected: "jpeg"},
+		{name: "WebPNotAccepted", img: decoded, decoded: "webp", canAVIF: true, expected: "png"},
+		{name: "TargetFormat", img: decoded, decoded: "png", target: "jpg", formats: []string{"image/avif", "image/webp"}, canAVIF: true, expected: "jpg"},
+		{name: "TargetFormatNotEncodable", img: decoded, decoded: "png", target: "avif", formats: []string{"image/avif", "image/webp"}, expected: "webp"},
+		{name: "Animation", img: animation, decoded: "gif", formats: []string{"image/avif", "image/webp"}, canAVIF: true, expected: "webp"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mp := &mockProcessor{}
+			ms := &metrics.MockMetricService{}
+			m := NewManipulator(mp, nil, ms)
+			mp.On("Decode", input).Return(c.img, c.decoded, nil)
+			mp.On("CanEncode", "avif").Return(c.canAVIF)
+			mp.On("CanEncode", mock.Anything).Return(true)
+			mp.On("Encode", c.img, c.expected).Return([]byte("outputData"), nil)
+			ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+
+			_, f, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(map[string]string{auto: format}).
+				WithTargetFormat(c.target).WithFormats(c.formats).Build())
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, f)
+			mp.AssertCalled(t, "Encode", c.img, c.expected)
+		})
+	}
+}
+
+func TestManipulator_ProcessWithSVG(t *testing.T) {
+	input := []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 50"><rect width="100" height="50" fill="#f00"/></svg>`)
+	cases := []struct {
+		params map[string]string
+		width  int
+		height int
+	}{
+		{params: map[string]string{}, width: 100, height: 50},
+		{params: map[string]string{width: "400"}, width: 400, height: 200},
+		{params: map[string]string{height: "25"}, width: 50, height: 25},
+		{params: map[string]string{width: "300", height: "300", fit: crop}, width: 300, height: 300},
+		{params: map[string]string{width: "300", height: "300", fit: scale}, width: 300, height: 300},
+	}
+	for _, c := range cases {
+		ms := &metrics.MockMetricService{}
+		ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+		m := NewManipulator(native.NewBildProcessor(), nil, ms)
+
+		out, f, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(c.params).Build())
+
+		if !assert.NoError(t, err) {
+			continue
+		}
+		img, _, err := image.Decode(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, c.width, c.height), img.Bounds(), "params %v", c.params)
+		r, g, b, _ := img.At(c.width/2, c.height/2).RGBA()
+		// The opaque image may be encoded as jpeg, so the color is not exact
+		assert.True(t, r > 0xf000 && g < 0x1000 && b < 0x1000, "color %x %x %x", r, g, b)
+		assert.Contains(t, []string{processor.ExtensionPNG, processor.ExtensionJPEG}, f)
+	}
+}
+
+func TestManipulator_ProcessWithRasterize(t *testing.T) {
+	input := []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 50"></svg>`)
+	rasterized := &image.RGBA{Pix: []uint8{1, 2, 3, 4}}
+	cases := []struct {
+		name   string
+		params map[string]string
+		width  int
+		height int
+	}{
+		{name: "OwnSize", params: map[string]string{}},
+		{name: "Width", params: map[string]string{width: "400"}, width: 400},
+		{name: "WidthAndHeight", params: map[string]string{width: "300", height: "200", fit: scale}, width: 300, height: 200},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mp := newMockProcessor()
+			ms := &metrics.MockMetricService{}
+			ms.On("TrackDuration", mock.Anything, mock.Anything, mock.Anything)
+			m := NewManipulator(mp, nil, ms)
+			mp.On("Rasterize", input, c.width, c.height).Return(rasterized, "png", nil)
+			mp.On("Resize", rasterized, mock.Anything, mock.Anything).Return(rasterized)
+			mp.On("Scale", rasterized, mock.Anything, mock.Anything).Return(rasterized)
+			mp.On("Encode", rasterized, "png").Return([]byte("outputData"), nil)
+
+			out, _, err := m.Process(NewSpecBuilder().WithImageData(input).WithParams(c.params).Build())
+
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("outputData"), out)
+			mp.AssertCalled(t, "Rasterize", input, c.width, c.height)
+			mp.AssertNotCalled(t, "Decode", mock.Anything)
+		})
+	}
+}
+
 func TestManipulator_HasDefaultParams(t *testing.T) {
 	manipulatorWithDefaultParams := NewManipulator(nil, map[string]string{"auto": "compress"}, nil)
 	manipulatorWithoutDefaultParams := NewManipulator(nil, map[string]string{}, nil)
@@ -212,11 +736,26 @@ type mockProcessor struct {
 	mock.Mock
 }
 
+// newMockProcessor returns a mockProcessor which can encode images in every format
+func newMockProcessor() *mockProcessor {
+	mp := &mockProcessor{}
+	mp.On("CanEncode", mock.Anything).Return(true).Maybe()
+	return mp
+}
+
 func (m *mockProcessor) Crop(img image.Image, width, height int, point processor.Point) image.Image {
 	args := m.Called(img, width, height, point)
 	return args.Get(0).(image.Image)
 }
 
+func (m *mockProcessor) CropFocalPoint(img image.Image, width, height int, fp processor.FocalPoint) image.Image {
+	return m.Called(img, width, height, fp).Get(0).(image.Image)
+}
+
+func (m *mockProcessor) Extract(img image.Image, rect image.Rectangle) image.Image {
+	return m.Called(img, rect).Get(0).(image.Image)
+}
+
 func (m *mockProcessor) Resize(img image.Image, width, height int) image.Image {
 	args := m.Called(img, width, height)
 	return args.Get(0).(image.Image)
@@ -227,9 +766,16 @@ func (m *mockProcessor) Scale(img image.Image, width, height int) image.Image {
 	return args.Get(0).(image.Image)
 }
 
-func (m *mockProcessor) Watermark(base []byte, overlay []byte, opacity uint8) ([]byte, error) {
-	args := m.Called(base, overlay, opacity)
-	return args.Get(0).([]byte), args.Get(1).(error)
+func (m *mockProcessor) Fill(img image.Image, width, height int, fill *processor.FillAttrs) image.Image {
+	return m.Called(img, width, height, fill).Get(0).(image.Image)
+}
+
+func (m *mockProcessor) Watermark(img image.Image, overlay *processor.OverlayAttrs, opacity uint8) (image.Image, error) {
+	args := m.Called(img, overlay, opacity)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(image.Image), args.Error(1)
 }
 
 func (m *mockProcessor) GrayScale(img image.Image) image.Image {
@@ -262,6 +808,14 @@ func (m *mockProcessor) Decode(data []byte) (image.Image, string, error) {
 	return nil, "", args.Error(2)
 }
 
+func (m *mockProcessor) Rasterize(data []byte, width, height int) (image.Image, string, error) {
+	args := m.Called(data, width, height)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(image.Image), args.String(1), args.Error(2)
+}
+
 func (m *mockProcessor) Encode(img image.Image, format string) ([]byte, error) {
 	args := m.Called(img, format)
 	b := args.Get(0).([]byte)
@@ -271,16 +825,36 @@ func (m *mockProcessor) Encode(img image.Image, format string) ([]byte, error) {
 	return b, args.Get(1).(error)
 }
 
+func (m *mockProcessor) EncodeWithQuality(img image.Image, format string, quality int) ([]byte, error) {
+	args := m.Called(img, format, quality)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockProcessor) CanEncode(format string) bool {
+	return m.Called(format).Bool(0)
+}
+
 func (m *mockProcessor) FixOrientation(img image.Image, orientation int) image.Image {
 	args := m.Called(img, orientation)
 	return args.Get(0).(image.Image)
 }
 
-func (m *mockProcessor) Overlay(base []byte, overlays []*processor.OverlayAttrs) ([]byte, error) {
-	args := m.Called(base, overlays)
-	b := args.Get(0).([]byte)
-	if args.Get(1) == nil {
-		return b, nil
+func (m *mockProcessor) Text(img image.Image, text *processor.TextAttrs) (image.Image, error) {
+	args := m.Called(img, text)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
 	}
-	return b, args.Get(1).(error)
+	return args.Get(0).(image.Image), args.Error(1)
+}
+
+func (m *mockProcessor) MarkFaces(img image.Image) image.Image {
+	return m.Called(img).Get(0).(image.Image)
+}
+
+func (m *mockProcessor) Overlay(img image.Image, overlays []*processor.OverlayAttrs) (image.Image, error) {
+	args := m.Called(img, overlays)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(image.Image), args.Error(1)
 }
@@ -8,9 +8,9 @@ type MockManipulator struct {
 	mock.Mock
 }
 
-func (m *MockManipulator) Process(spec processSpec) ([]byte, error) {
+func (m *MockManipulator) Process(spec processSpec) ([]byte, string, error) {
 	args := m.Called(spec)
-	return args.Get(0).([]byte), args.Error(1)
+	return args.Get(0).([]byte), args.String(1), args.Error(2)
 }
 
 func (m *MockManipulator) HasDefaultParams() bool {
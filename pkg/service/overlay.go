@@ -0,0 +1,105 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gojek/darkroom/pkg/processor"
+)
+
+const (
+	overlay      = "overlay"
+	overlayPos   = "pos"
+	overlayW     = "w"
+	overlayH     = "h"
+	overlayAlpha = "alpha"
+	overlayX     = "x"
+	overlayY     = "y"
+
+	// maxOverlays is the number of overlays which can be declared for an image
+	maxOverlays = 10
+	// maxOverlayOffset bounds the offsets of an overlay in pixels
+	maxOverlayOffset = 9999
+)
+
+// ErrTooManyOverlays is returned when more overlays than allowed are declared for an image
+var ErrTooManyOverlays = fmt.Errorf("more than %d overlays are not allowed", maxOverlays)
+
+// overlayKeys returns the params which declare an overlay, eg: overlay1, in the order of their index. The attributes
+// of an overlay are the params which are prefixed with its key, eg: overlay1pos.
+func overlayKeys(params map[string]string) []string {
+	type indexedKey struct {
+		key   string
+		index int
+	}
+	var keys []indexedKey
+	for k, v := range params {
+		if !strings.HasPrefix(k, overlay) || v == "" {
+			continue
+		}
+		suffix := k[len(overlay):]
+		if suffix == "" || strings.Trim(suffix, "0123456789") != "" {
+			continue
+		}
+		i, err := strconv.Atoi(suffix)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, indexedKey{key: k, index: i})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].index == keys[j].index {
+			return keys[i].key < keys[j].key
+		}
+		return keys[i].index < keys[j].index
+	})
+	res := make([]string, len(keys))
+	for i, k := range keys {
+		res[i] = k.key
+	}
+	return res
+}
+
+// getOverlays returns the overlays declared in the params in the order they are drawn, eg:
+// overlay1=/logos/brand.png&overlay1pos=top,left&overlay1w=20&overlay1h=10&overlay1alpha=60&overlay1x=10&overlay1y=10
+// The images of the overlays are taken from the assets. The overlays which are fully transparent are skipped.
+func getOverlays(params map[string]string, assets map[string][]byte) ([]*processor.OverlayAttrs, error) {
+	keys := overlayKeys(params)
+	if len(keys) > maxOverlays {
+		return nil, ErrTooManyOverlays
+	}
+	var overlays []*processor.OverlayAttrs
+	for _, k := range keys {
+		img, ok := assets[cleanAssetPath(params[k])]
+		if !ok {
+			return nil, fmt.Errorf("overlay %s is not available", params[k])
+		}
+		opacity := getOpacity(params[k+overlayAlpha])
+		if opacity == 0 {
+			continue
+		}
+		overlays = append(overlays, &processor.OverlayAttrs{
+			Img:              img,
+			Point:            GetCropPoint(params[k+overlayPos]),
+			WidthPercentage:  getPercentage(params[k+overlayW]),
+			HeightPercentage: getPercentage(params[k+overlayH]),
+			Opacity:          opacity,
+			OffsetX:          getOffset(params[k+overlayX]),
+			OffsetY:          getOffset(params[k+overlayY]),
+		})
+	}
+	return overlays, nil
+}
+
+// getOffset returns the offset of an overlay in pixels, not greater than 9999 in either direction
+func getOffset(input string) int {
+	val, _ := strconv.Atoi(input)
+	if val > maxOverlayOffset {
+		return maxOverlayOffset
+	} else if val < -maxOverlayOffset {
+		return -maxOverlayOffset
+	}
+	return val
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOverlays(t *testing.T) {
+	assets := map[string][]byte{"/logos/brand.png": []byte("brand"), "/badges/sale.png": []byte("sale")}
+	params := map[string]string{
+		"w":             "500",
+		"overlay2":      "badges/sale.png",
+		"overlay2pos":   "bottom,right",
+		"overlay2alpha": "50",
+		"overlay2x":     "-10",
+		"overlay2y":     "-20",
+		"overlay1":      "/logos/brand.png",
+		"overlay1pos":   "top,left",
+		"overlay1w":     "20",
+		"overlay1h":     "150",
+		"overlay3":      "/logos/brand.png",
+		"overlay3alpha": "0",
+	}
+
+	overlays, err := getOverlays(params, assets)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*processor.OverlayAttrs{
+		{
+			Img:              []byte("brand"),
+			Point:            processor.PointTopLeft,
+			WidthPercentage:  20,
+			HeightPercentage: 100,
+			Opacity:          255,
+		},
+		{
+			Img:     []byte("sale"),
+			Point:   processor.PointBottomRight,
+			Opacity: 127,
+			OffsetX: -10,
+			OffsetY: -20,
+		},
+	}, overlays)
+}
+
+func TestGetOverlaysWithoutOverlays(t *testing.T) {
+	overlays, err := getOverlays(map[string]string{"w": "500", "overlay": "/logos/brand.png"}, nil)
+
+	assert.NoError(t, err)
+	assert.Nil(t, overlays)
+}
+
+func TestGetOverlaysWithUnavailableAsset(t *testing.T) {
+	_, err := getOverlays(map[string]string{"overlay1": "/logos/brand.png"}, map[string][]byte{})
+
+	assert.Error(t, err)
+}
+
+func TestGetOverlaysWithTooManyOverlays(t *testing.T) {
+	params := map[string]string{}
+	assets := map[string][]byte{"/logos/brand.png": []byte("brand")}
+	for _, k := range []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"} {
+		params[overlay+k] = "/logos/brand.png"
+	}
+
+	_, err := getOverlays(params, assets)
+
+	assert.Equal(t, ErrTooManyOverlays, err)
+}
+
+func TestOverlayKeys(t *testing.T) {
+	params := map[string]string{
+		"overlay10":   "a",
+		"overlay2":    "b",
+		"overlay02":   "c",
+		"overlay":     "d",
+		"overlay-1":   "e",
+		"overlay+3":   "f",
+		"overlay4":    "",
+		"overlay1pos": "top",
+	}
+
+	assert.Equal(t, []string{"overlay02", "overlay2", "overlay10"}, overlayKeys(params))
+}
+
+func TestGetOffset(t *testing.T) {
+	assert.Equal(t, 0, getOffset(""))
+	assert.Equal(t, -25, getOffset("-25"))
+	assert.Equal(t, 9999, getOffset("20000"))
+	assert.Equal(t, -9999, getOffset("-20000"))
+}
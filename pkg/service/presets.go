@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// PresetParam is the query param used by the clients to select a preset
+const PresetParam = "preset"
+
+var (
+	// ErrUnknownPreset is returned when the requested preset is not configured
+	ErrUnknownPreset = errors.New("preset is not configured")
+	// ErrPresetsOnly is returned when params are passed while only presets are allowed
+	ErrPresetsOnly = errors.New("only presets are allowed")
+)
+
+// Presets holds the named param sets which can be selected by the clients instead of building the params themselves
+type Presets struct {
+	presets map[string]map[string]string
+	only    bool
+}
+
+// NewPresets takes the presets as a map of name to query string, eg: thumbnail: w=200&h=200&fit=crop.
+// If only is set, the clients are not allowed to pass any params other than a preset. The names are
+// case-insensitive, as the keys of the config are lowercased when it is loaded.
+func NewPresets(presets map[string]string, only bool) (*Presets, error) {
+	p := &Presets{presets: make(map[string]map[string]string), only: only}
+	for name, query := range presets {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		params := make(map[string]string)
+		for k := range values {
+			if values.Get(k) != "" {
+				params[k] = values.Get(k)
+			}
+		}
+		p.presets[strings.ToLower(name)] = params
+	}
+	return p, nil
+}
+
+// Resolve returns the params of the named preset merged with the params of the request, the params
+// of the request take precedence over the ones in the preset. The default params are applied on top
+// of the result by the Manipulator as usual. An empty name returns the params of the request as is,
+// and so does any name if no preset is configured, so that the preset param is ignored as it was
+// before presets were supported.
+func (p *Presets) Resolve(name string, params map[string]string) (map[string]string, error) {
+	if p == nil {
+		return params, nil
+	}
+	if p.only && len(params) > 0 {
+		return nil, ErrPresetsOnly
+	}
+	if name == "" || len(p.presets) == 0 {
+		return params, nil
+	}
+	preset, ok := p.presets[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownPreset
+	}
+	fp := make(map[string]string)
+	for k, v := range preset {
+		fp[k] = v
+	}
+	for k, v := range params {
+		fp[k] = v
+	}
+	return fp, nil
+}
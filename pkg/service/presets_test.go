@@ -0,0 +1,116 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPresets(t *testing.T) {
+	p, err := NewPresets(map[string]string{
+		"thumbnail": "w=200&h=200&fit=crop",
+		"Hero":      "w=1600&auto=compress,format&blur=",
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"thumbnail": {"w": "200", "h": "200", "fit": "crop"},
+		"hero":      {"w": "1600", "auto": "compress,format"},
+	}, p.presets)
+
+	_, err = NewPresets(map[string]string{"invalid": "w=%zz"}, false)
+	assert.Error(t, err)
+}
+
+func TestPresetsResolve(t *testing.T) {
+	p, _ := NewPresets(map[string]string{"thumbnail": "w=200&h=200&fit=crop"}, false)
+	only, _ := NewPresets(map[string]string{"thumbnail": "w=200&h=200&fit=crop"}, true)
+	empty, _ := NewPresets(map[string]string{}, false)
+	cases := []struct {
+		name        string
+		presets     *Presets
+		preset      string
+		params      map[string]string
+		expectedRes map[string]string
+		expectedErr error
+	}{
+		{
+			name:        "WithPreset",
+			presets:     p,
+			preset:      "thumbnail",
+			params:      map[string]string{},
+			expectedRes: map[string]string{"w": "200", "h": "200", "fit": "crop"},
+		},
+		{
+			name:        "WithPresetAndParams",
+			presets:     p,
+			preset:      "thumbnail",
+			params:      map[string]string{"w": "100", "mono": "000000"},
+			expectedRes: map[string]string{"w": "100", "h": "200", "fit": "crop", "mono": "000000"},
+		},
+		{
+			name:        "WithoutPreset",
+			presets:     p,
+			params:      map[string]string{"w": "100"},
+			expectedRes: map[string]string{"w": "100"},
+		},
+		{
+			name:        "WithUnknownPreset",
+			presets:     p,
+			preset:      "hero",
+			params:      map[string]string{},
+			expectedErr: ErrUnknownPreset,
+		},
+		{
+			name:        "WithMixedCasePreset",
+			presets:     p,
+			preset:      "Thumbnail",
+			params:      map[string]string{},
+			expectedRes: map[string]string{"w": "200", "h": "200", "fit": "crop"},
+		},
+		{
+			name:        "WithoutPresetsConfigured",
+			preset:      "thumbnail",
+			params:      map[string]string{"w": "100"},
+			expectedRes: map[string]string{"w": "100"},
+		},
+		{
+			name:        "WithEmptyPresets",
+			presets:     empty,
+			preset:      "thumbnail",
+			params:      map[string]string{"w": "100"},
+			expectedRes: map[string]string{"w": "100"},
+		},
+		{
+			name:        "WithParamsAndWithoutPresetsConfigured",
+			params:      map[string]string{"w": "100"},
+			expectedRes: map[string]string{"w": "100"},
+		},
+		{
+			name:        "WithPresetWhenPresetsOnly",
+			presets:     only,
+			preset:      "thumbnail",
+			params:      map[string]string{},
+			expectedRes: map[string]string{"w": "200", "h": "200", "fit": "crop"},
+		},
+		{
+			name:        "WithPresetAndParamsWhenPresetsOnly",
+			presets:     only,
+			preset:      "thumbnail",
+			params:      map[string]string{"w": "100"},
+			expectedErr: ErrPresetsOnly,
+		},
+		{
+			name:        "WithoutParamsWhenPresetsOnly",
+			presets:     only,
+			params:      map[string]string{},
+			expectedRes: map[string]string{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := c.presets.Resolve(c.preset, c.params)
+			assert.Equal(t, c.expectedErr, err)
+			assert.Equal(t, c.expectedRes, res)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const rect = "rect"
+
+// ErrInvalidRect is returned when the rect param is malformed or not inside the bounds of the image
+var ErrInvalidRect = errors.New("invalid rect")
+
+// getRect takes the rect param as x,y,w,h and the bounds of the image and returns the rectangle relative to the
+// top left corner of the image. Every value is either in pixels or, with a % suffix, a percentage of the width
+// for x and w and of the height for y and h, eg: rect=10,10,50%,50%.
+func getRect(input string, bounds image.Rectangle) (image.Rectangle, error) {
+	parts := strings.Split(input, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("%w: %s is not x,y,w,h", ErrInvalidRect, input)
+	}
+	var v [4]int
+	for i, p := range parts {
+		size := bounds.Dx()
+		if i%2 == 1 {
+			size = bounds.Dy()
+		}
+		n, err := parseRectValue(strings.TrimSpace(p), size)
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("%w: %s is not a number of pixels or a percentage from 0%% to 100%%",
+				ErrInvalidRect, p)
+		}
+		v[i] = n
+	}
+	r := image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3])
+	if v[0] < 0 || v[1] < 0 || v[2] <= 0 || v[3] <= 0 || r.Max.X > bounds.Dx() || r.Max.Y > bounds.Dy() {
+		return image.Rectangle{}, fmt.Errorf("%w: %s is not inside the %dx%d image",
+			ErrInvalidRect, input, bounds.Dx(), bounds.Dy())
+	}
+	return r, nil
+}
+
+// parseRectValue returns the number of pixels of a value of the rect param, a percentage from 0 to 100 is relative
+// to size
+func parseRectValue(input string, size int) (int, error) {
+	if strings.HasSuffix(input, "%") {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(input, "%"), 64)
+		if err != nil || !(f >= 0 && f <= 100) {
+			return 0, ErrInvalidRect
+		}
+		return int(math.Round(f * float64(size) / 100)), nil
+	}
+	return strconv.Atoi(input)
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRect(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 200)
+	cases := []struct {
+		input    string
+		expected image.Rectangle
+	}{
+		{input: "10,20,100,50", expected: image.Rect(10, 20, 110, 70)},
+		{input: "0,0,400,200", expected: bounds},
+		{input: "25%,50%,50%,50%", expected: image.Rect(100, 100, 300, 200)},
+		{input: "10, 10%, 12.5%,20", expected: image.Rect(10, 20, 60, 40)},
+	}
+	for _, c := range cases {
+		r, err := getRect(c.input, bounds)
+		assert.NoError(t, err, c.input)
+		assert.Equal(t, c.expected, r, c.input)
+	}
+
+	// The rect is relative to the top left corner of the image
+	r, err := getRect("10,20,100,50", image.Rect(5, 5, 405, 205))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(10, 20, 110, 70), r)
+
+	for _, input := range []string{
+		"", "10,20,100", "10,20,100,50,1", "a,20,100,50", "10,20,100px,50", "10,20,1.5,50", "10,20,150%,50",
+		"10,20,NaN%,50", "-1,0,100,50", "0,0,0,50", "0,0,100,-5", "300,0,101,50", "0,150,100,51", "50%,0,51%,50",
+	} {
+		_, err := getRect(input, bounds)
+		assert.ErrorIs(t, err, ErrInvalidRect, input)
+	}
+}
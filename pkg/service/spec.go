@@ -1,8 +1,12 @@
 package service
 
+import "strings"
+
 type ProcessSpec interface {
 	// IsWebPSupported() will tell if WebP is supported based on the accepted formats
 	IsWebPSupported() bool
+	// IsAVIFSupported() will tell if AVIF is supported based on the accepted formats
+	IsAVIFSupported() bool
 }
 
 type processSpec struct {
@@ -14,15 +18,37 @@ type processSpec struct {
 	Params map[string]string
 	// Reformat image to target format
 	TargetFormat string
-	// Formats have the information of accepted formats, whether darkroom can return the image using webp or not
+	// Assets hold the contents of the images referenced by the params, eg: the watermark, keyed by their path
+	Assets map[string][]byte
+	// Formats have the information of accepted formats, whether darkroom can return the image using webp/avif or not,
+	// ordered by the preference of the caller
 	formats []string
 }
 
+const (
+	// FormatParam is the query param used by the clients to request the format of the processed image, eg: fm=webp
+	FormatParam = "fm"
+	// FormatAliasParam is an alias of FormatParam, FormatParam takes precedence if both are used
+	FormatAliasParam = "format"
+)
+
+// GetTargetFormat returns the format requested with FormatParam or FormatAliasParam, an empty string is
+// returned if none of them is set
+func GetTargetFormat(params map[string]string) string {
+	f := params[FormatParam]
+	if f == "" {
+		f = params[FormatAliasParam]
+	}
+	return strings.ToLower(strings.TrimSpace(f))
+}
+
 const (
 	extJPG  = "jpg"
 	extPNG  = "png"
 	extWebP = "webp"
 	extJPEG = "jpeg"
+	extAVIF = "avif"
+	extGIF  = "gif"
 )
 
 func (ps *processSpec) IsWebPSupported() bool {
@@ -34,12 +60,22 @@ func (ps *processSpec) IsWebPSupported() bool {
 	return false
 }
 
+func (ps *processSpec) IsAVIFSupported() bool {
+	for _, f := range ps.formats {
+		if f == "image/avif" {
+			return true
+		}
+	}
+	return false
+}
+
 type SpecBuilder interface {
 	WithScope(scope string) SpecBuilder
 	WithImageData(img []byte) SpecBuilder
 	WithParams(params map[string]string) SpecBuilder
 	WithFormats(formats []string) SpecBuilder
 	WithTargetFormat(ext string) SpecBuilder
+	WithAssets(assets map[string][]byte) SpecBuilder
 	Build() processSpec
 }
 
@@ -49,6 +85,7 @@ type specBuilder struct {
 	params    map[string]string
 	formats   []string
 	extension string
+	assets    map[string][]byte
 }
 
 func (sb *specBuilder) WithScope(scope string) SpecBuilder {
@@ -73,12 +110,17 @@ func (sb *specBuilder) WithFormats(formats []string) SpecBuilder {
 
 func (sb *specBuilder) WithTargetFormat(ext string) SpecBuilder {
 	switch ext {
-	case extJPG, extJPEG, extPNG, extWebP:
+	case extJPG, extJPEG, extPNG, extWebP, extAVIF, extGIF:
 		sb.extension = ext
 	}
 	return sb
 }
 
+func (sb *specBuilder) WithAssets(assets map[string][]byte) SpecBuilder {
+	sb.assets = assets
+	return sb
+}
+
 func (sb *specBuilder) Build() processSpec {
 	return processSpec{
 		Scope:        sb.scope,
@@ -86,6 +128,7 @@ func (sb *specBuilder) Build() processSpec {
 		Params:       sb.params,
 		formats:      sb.formats,
 		TargetFormat: sb.extension,
+		Assets:       sb.assets,
 	}
 }
 
@@ -37,8 +37,32 @@ func TestSpec_IsWebPSupported(t *testing.T) {
 	assert.False(t, spec.IsWebPSupported())
 }
 
+func TestSpec_IsAVIFSupported(t *testing.T) {
+	f := []string{"image/avif", "image/webp"}
+	spec := NewSpecBuilder().WithFormats(f).Build()
+	assert.True(t, spec.IsAVIFSupported())
+
+	f = []string{"image/webp"}
+	spec = NewSpecBuilder().WithFormats(f).Build()
+	assert.False(t, spec.IsAVIFSupported())
+}
+
+func TestSpec_Build_TargetExtensionAVIF(t *testing.T) {
+	spec := NewSpecBuilder().WithTargetFormat("avif").Build()
+	assert.Equal(t, "avif", spec.TargetFormat)
+	spec = NewSpecBuilder().WithTargetFormat("gif").Build()
+	assert.Equal(t, "gif", spec.TargetFormat)
+}
+
 func TestSpec_Build_TargetExtensionNotValid(t *testing.T) {
-	ext := "gif"
+	ext := "bmp"
 	spec := NewSpecBuilder().WithTargetFormat(ext).Build()
 	assert.Empty(t, spec.TargetFormat)
 }
+
+func TestGetTargetFormat(t *testing.T) {
+	assert.Equal(t, "", GetTargetFormat(nil))
+	assert.Equal(t, "webp", GetTargetFormat(map[string]string{FormatParam: "webp"}))
+	assert.Equal(t, "png", GetTargetFormat(map[string]string{FormatAliasParam: " PNG "}))
+	assert.Equal(t, "avif", GetTargetFormat(map[string]string{FormatParam: "avif", FormatAliasParam: "png"}))
+}
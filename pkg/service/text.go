@@ -0,0 +1,64 @@
+package service
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/gojek/darkroom/pkg/processor"
+)
+
+const (
+	txt      = "txt"
+	txtSize  = "txtsize"
+	txtColor = "txtcolor"
+	txtAlpha = "txtalpha"
+	txtPos   = "txtpos"
+	txtPad   = "txtpad"
+	txtTile  = "txttile"
+
+	defaultTextSize    = 24
+	minTextSize        = 8
+	maxTextSize        = 1000
+	maxTextLength      = 500
+	defaultTextPadding = 10
+	defaultTextColor   = "000000"
+)
+
+// getText returns the text layer declared in the params, eg: txt=SAMPLE&txtsize=32&txtcolor=ffffff&txtalpha=60,
+// it returns nil if there is no text or if it is fully transparent. The text is cut after maxTextLength characters.
+func getText(params map[string]string) *processor.TextAttrs {
+	if params[txt] == "" {
+		return nil
+	}
+	opacity := getOpacity(params[txtAlpha])
+	if opacity == 0 {
+		return nil
+	}
+	size, err := strconv.ParseFloat(params[txtSize], 64)
+	if err != nil || math.IsNaN(size) {
+		size = defaultTextSize
+	}
+	size = math.Max(minTextSize, math.Min(size, maxTextSize))
+	c, ok := parseHexColor(params[txtColor])
+	if !ok {
+		c, _ = parseHexColor(defaultTextColor)
+	}
+	padding := defaultTextPadding
+	if params[txtPad] != "" {
+		padding = CleanInt(params[txtPad])
+	}
+	tile, _ := strconv.ParseBool(params[txtTile])
+	text := []rune(params[txt])
+	if len(text) > maxTextLength {
+		text = text[:maxTextLength]
+	}
+	return &processor.TextAttrs{
+		Text:    string(text),
+		Size:    size,
+		Color:   c,
+		Opacity: opacity,
+		Point:   getWatermarkPoint(params[txtPos]),
+		Padding: padding,
+		Tile:    tile,
+	}
+}
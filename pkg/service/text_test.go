@@ -0,0 +1,51 @@
+package service
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/gojek/darkroom/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetText(t *testing.T) {
+	assert.Nil(t, getText(map[string]string{width: "100"}))
+	assert.Nil(t, getText(map[string]string{txt: "SAMPLE", txtAlpha: "0"}))
+
+	assert.Equal(t, &processor.TextAttrs{
+		Text:    "SAMPLE",
+		Size:    24,
+		Color:   color.NRGBA{A: 255},
+		Opacity: 255,
+		Point:   processor.PointBottomRight,
+		Padding: 10,
+	}, getText(map[string]string{txt: "SAMPLE"}))
+
+	assert.Equal(t, &processor.TextAttrs{
+		Text:    "Sold by Toko Jaya ✓",
+		Size:    32.5,
+		Color:   color.NRGBA{R: 255, G: 255, B: 255, A: 128},
+		Opacity: 153,
+		Point:   processor.PointTopLeft,
+		Padding: 0,
+		Tile:    true,
+	}, getText(map[string]string{
+		txt:      "Sold by Toko Jaya ✓",
+		txtSize:  "32.5",
+		txtColor: "ffffff80",
+		txtAlpha: "60",
+		txtPos:   "top,left",
+		txtPad:   "0",
+		txtTile:  "true",
+	}))
+
+	attrs := getText(map[string]string{txt: "SAMPLE", txtSize: "2", txtColor: "invalid", txtTile: "invalid"})
+	assert.Equal(t, float64(minTextSize), attrs.Size)
+	assert.Equal(t, color.NRGBA{A: 255}, attrs.Color)
+	assert.False(t, attrs.Tile)
+	assert.Equal(t, float64(maxTextSize), getText(map[string]string{txt: "SAMPLE", txtSize: "5000"}).Size)
+
+	long := getText(map[string]string{txt: strings.Repeat("✓", maxTextLength+10)})
+	assert.Equal(t, strings.Repeat("✓", maxTextLength), long.Text)
+}
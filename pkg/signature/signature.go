@@ -0,0 +1,119 @@
+// Package signature implements the HMAC signing of image URLs, so that only the URLs generated by the holders of a
+// shared secret can be used to request transformations from darkroom
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// Param is the query parameter which holds the signature of the URL
+	Param = "s"
+	// ExpiresParam is the optional query parameter which holds the unix timestamp after which the URL is rejected,
+	// it is a part of the signed params so it can't be tampered with
+	ExpiresParam = "expires"
+)
+
+var (
+	// ErrMissingSignature is returned when the URL doesn't have a signature
+	ErrMissingSignature = errors.New("signature is missing")
+	// ErrInvalidSignature is returned when the signature doesn't match any of the keys
+	ErrInvalidSignature = errors.New("signature is invalid")
+	// ErrInvalidExpiry is returned when the expires param is not a unix timestamp
+	ErrInvalidExpiry = errors.New("expiry timestamp is invalid")
+	// ErrExpired is returned when the URL is used after its expiry timestamp
+	ErrExpired = errors.New("signature has expired")
+)
+
+// Verifier checks the signature of the URLs against a set of keys. Multiple keys are accepted
+// at the same time so that a key can be rotated without invalidating the URLs already handed out.
+type Verifier struct {
+	keys [][]byte
+	now  func() time.Time
+}
+
+// NewVerifier returns a Verifier which accepts the signatures generated with any of the keys
+func NewVerifier(keys ...string) *Verifier {
+	v := &Verifier{now: time.Now}
+	for _, k := range keys {
+		if k != "" {
+			v.keys = append(v.keys, []byte(k))
+		}
+	}
+	return v
+}
+
+// Verify returns nil if the values hold a valid and unexpired signature of the path and the rest of the values
+func (v *Verifier) Verify(path string, values url.Values) error {
+	s := values.Get(Param)
+	if s == "" {
+		return ErrMissingSignature
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	msg := message(path, values)
+	valid := false
+	for _, k := range v.keys {
+		if hmac.Equal(sig, sum(k, msg)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	if e := values.Get(ExpiresParam); e != "" {
+		ts, err := strconv.ParseInt(e, 10, 64)
+		if err != nil {
+			return ErrInvalidExpiry
+		}
+		if v.now().Unix() > ts {
+			return ErrExpired
+		}
+	}
+	return nil
+}
+
+// Sign returns the signature of the path and the values (except the signature param itself) using the key
+func Sign(key, path string, values url.Values) string {
+	return base64.RawURLEncoding.EncodeToString(sum([]byte(key), message(path, values)))
+}
+
+// SignURL adds the expires param (if expiresAt is not zero) and the signature param to the URL
+func SignURL(key string, u *url.URL, expiresAt time.Time) *url.URL {
+	signed := *u
+	values := signed.Query()
+	values.Del(Param)
+	if !expiresAt.IsZero() {
+		values.Set(ExpiresParam, strconv.FormatInt(expiresAt.Unix(), 10))
+	}
+	values.Set(Param, Sign(key, signed.Path, values))
+	signed.RawQuery = values.Encode()
+	return &signed
+}
+
+// message builds the string which is signed, the values are encoded in the order of their keys
+// so that reordering the query params of a signed URL doesn't invalidate it
+func message(path string, values url.Values) []byte {
+	params := url.Values{}
+	for k, v := range values {
+		if k != Param {
+			params[k] = v
+		}
+	}
+	return []byte(path + "?" + params.Encode())
+}
+
+func sum(key, msg []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write(msg)
+	return h.Sum(nil)
+}
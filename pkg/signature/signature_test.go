@@ -0,0 +1,74 @@
+package signature
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	values := url.Values{"w": {"100"}, "h": {"200"}}
+	s := Sign("secret", "/image.jpg", values)
+
+	assert.Equal(t, s, Sign("secret", "/image.jpg", url.Values{"h": {"200"}, "w": {"100"}}))
+	assert.Equal(t, s, Sign("secret", "/image.jpg", url.Values{"h": {"200"}, "w": {"100"}, Param: {"ignored"}}))
+	assert.NotEqual(t, s, Sign("another-secret", "/image.jpg", values))
+	assert.NotEqual(t, s, Sign("secret", "/another-image.jpg", values))
+	assert.NotEqual(t, s, Sign("secret", "/image.jpg", url.Values{"w": {"9999"}, "h": {"200"}}))
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	v := NewVerifier("old-secret", "", "new-secret")
+	v.now = func() time.Time { return now }
+	sign := func(key string, values url.Values) url.Values {
+		values.Set(Param, Sign(key, "/image.jpg", values))
+		return values
+	}
+
+	cases := []struct {
+		name     string
+		values   url.Values
+		expected error
+	}{
+		{name: "WithNewKey", values: sign("new-secret", url.Values{"w": {"100"}})},
+		{name: "WithOldKey", values: sign("old-secret", url.Values{"w": {"100"}})},
+		{name: "WithoutParams", values: sign("new-secret", url.Values{})},
+		{name: "WithUnknownKey", values: sign("unknown", url.Values{"w": {"100"}}), expected: ErrInvalidSignature},
+		{name: "WithoutSignature", values: url.Values{"w": {"100"}}, expected: ErrMissingSignature},
+		{name: "WithMalformedSignature", values: url.Values{Param: {"!!"}}, expected: ErrInvalidSignature},
+		{
+			name: "WithTamperedParams",
+			values: func() url.Values {
+				values := sign("new-secret", url.Values{"w": {"100"}})
+				values.Set("w", "9999")
+				return values
+			}(),
+			expected: ErrInvalidSignature,
+		},
+		{name: "WithFutureExpiry", values: sign("new-secret", url.Values{ExpiresParam: {"1600000001"}})},
+		{name: "WithPastExpiry", values: sign("new-secret", url.Values{ExpiresParam: {"1599999999"}}), expected: ErrExpired},
+		{name: "WithInvalidExpiry", values: sign("new-secret", url.Values{ExpiresParam: {"tomorrow"}}), expected: ErrInvalidExpiry},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, v.Verify("/image.jpg", c.values))
+		})
+	}
+}
+
+func TestSignURL(t *testing.T) {
+	u, _ := url.Parse("https://example.com/image.jpg?w=100&h=200")
+	v := NewVerifier("secret")
+
+	signed := SignURL("secret", u, time.Time{})
+	assert.Equal(t, "https://example.com/image.jpg?w=100&h=200", u.String())
+	assert.Equal(t, "", signed.Query().Get(ExpiresParam))
+	assert.NoError(t, v.Verify(signed.Path, signed.Query()))
+
+	signed = SignURL("secret", signed, time.Now().Add(time.Hour))
+	assert.NotEqual(t, "", signed.Query().Get(ExpiresParam))
+	assert.NoError(t, v.Verify(signed.Path, signed.Query()))
+}
@@ -26,7 +26,9 @@ func (s *Storage) Get(ctx context.Context, path string) storage.IResponse {
 		return errRes
 	}
 	body, _ := ioutil.ReadAll(res.Body)
-	return storage.NewResponse(body, res.StatusCode, nil)
+	return storage.
+		NewResponse(body, res.StatusCode, nil).
+		WithMetadata(newMetadata(res.Header))
 }
 
 // GetPartially takes in the Context, path and opt as an argument and returns an IResponse interface implementation.
@@ -48,14 +50,18 @@ func (s *Storage) GetPartially(ctx context.Context, path string, opt *storage.Ge
 	body, _ := ioutil.ReadAll(res.Body)
 	return storage.
 		NewResponse(body, res.StatusCode, nil).
-		WithMetadata(&storage.ResponseMetadata{
-			AcceptRanges:  res.Header.Get(storage.HeaderAcceptRanges),
-			ContentLength: res.Header.Get(storage.HeaderContentLength),
-			ContentRange:  res.Header.Get(storage.HeaderContentRange),
-			ContentType:   res.Header.Get(storage.HeaderContentType),
-			ETag:          res.Header.Get(storage.HeaderETag),
-			LastModified:  res.Header.Get(storage.HeaderLastModified),
-		})
+		WithMetadata(newMetadata(res.Header))
+}
+
+func newMetadata(h http.Header) *storage.ResponseMetadata {
+	return &storage.ResponseMetadata{
+		AcceptRanges:  h.Get(storage.HeaderAcceptRanges),
+		ContentLength: h.Get(storage.HeaderContentLength),
+		ContentRange:  h.Get(storage.HeaderContentRange),
+		ContentType:   h.Get(storage.HeaderContentType),
+		ETag:          h.Get(storage.HeaderETag),
+		LastModified:  h.Get(storage.HeaderLastModified),
+	}
 }
 
 func (s *Storage) getURL(path string) string {
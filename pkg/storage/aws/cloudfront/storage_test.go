@@ -84,10 +84,24 @@ func (s *StorageTestSuite) TestStorage_GetForbidden() {
 }
 
 func (s *StorageTestSuite) TestStorage_GetSuccessResponse() {
+	metadata := storage.ResponseMetadata{
+		ContentLength: "13",
+		ContentType:   "image/png",
+		ETag:          "32705ce195789d7bf07f3d44783c2988",
+		LastModified:  "Wed, 21 Oct 2015 07:28:00 GMT",
+	}
+
+	respHeader := http.Header{}
+	respHeader.Add(storage.HeaderContentLength, metadata.ContentLength)
+	respHeader.Add(storage.HeaderContentType, metadata.ContentType)
+	respHeader.Add(storage.HeaderETag, metadata.ETag)
+	respHeader.Add(storage.HeaderLastModified, metadata.LastModified)
+
 	s.client.On("Get", fmt.Sprintf("%s://%s%s", s.storage.getProtocol(), validHost, validPath), http.Header(nil)).
 		Return(&http.Response{
 			StatusCode: http.StatusOK,
 			Body:       ioutil.NopCloser(bytes.NewReader([]byte("response body"))),
+			Header:     respHeader,
 		}, nil)
 
 	res := s.storage.Get(context.TODO(), validPath)
@@ -95,6 +109,7 @@ func (s *StorageTestSuite) TestStorage_GetSuccessResponse() {
 	assert.Nil(s.T(), res.Error())
 	assert.Equal(s.T(), http.StatusOK, res.Status())
 	assert.Equal(s.T(), []byte("response body"), res.Data())
+	assert.Equal(s.T(), &metadata, res.Metadata())
 }
 
 func (s *StorageTestSuite) TestStorage_GetPartialObjectSuccessResponse() {
@@ -145,7 +160,7 @@ func (s *StorageTestSuite) TestStorage_GetPartialObjectSuccessResponse_WhenRange
 	assert.Nil(s.T(), res.Error())
 	assert.Equal(s.T(), http.StatusOK, res.Status())
 	assert.Equal(s.T(), []byte("response body"), res.Data())
-	assert.Nil(s.T(), res.Metadata())
+	assert.Equal(s.T(), &storage.ResponseMetadata{}, res.Metadata())
 }
 
 func (s *StorageTestSuite) TestStorage_getURL() {
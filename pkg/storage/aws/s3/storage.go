@@ -1,6 +1,7 @@
 package s3
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
@@ -11,8 +12,6 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
 	"github.com/gojek/darkroom/pkg/storage"
 )
 
@@ -25,7 +24,6 @@ type Storage struct {
 	endpoint     string
 	service      s3iface.S3API
 	hystrixCmd   storage.HystrixCommand
-	downloader   s3manageriface.DownloaderAPI
 }
 
 // Get takes in the Context and path as an argument and returns an IResponse interface implementation.
@@ -35,20 +33,7 @@ func (s *Storage) Get(ctx context.Context, path string) storage.IResponse {
 		Bucket: aws.String(s.bucketName),
 		Key:    aws.String(path),
 	}
-
-	buff := &aws.WriteAtBuffer{}
-	responseChannel := make(chan error, 1)
-	makeNetworkCall(s.hystrixCmd.Name, s.hystrixCmd.Config, func() error {
-		_, err := s.downloader.Download(buff, &input)
-		responseChannel <- err
-		return err
-	}, func(e error) error {
-		responseChannel <- e
-		return e
-	})
-	s3Err := <-responseChannel
-
-	return storage.NewResponse(buff.Bytes(), getStatusCodeFromError(s3Err, nil), s3Err)
+	return s.getObject(&input, http.StatusOK)
 }
 
 // GetPartially takes in the Context, path and opt as an argument and returns an IResponse interface implementation.
@@ -57,19 +42,45 @@ func (s *Storage) GetPartially(ctx context.Context, path string, opt *storage.Ge
 	if opt == nil || len(opt.Range) == 0 {
 		return s.Get(ctx, path)
 	}
-
 	input := s3.GetObjectInput{
 		Bucket: aws.String(s.bucketName),
 		Key:    aws.String(path),
 		Range:  &opt.Range,
 	}
+	return s.getObject(&input, http.StatusPartialContent)
+}
+
+// Put takes in the Context, path, data and opt as an argument and returns an error if the data couldn't be stored.
+// This method figures out how to store the data in the S3 storage backend.
+func (s *Storage) Put(ctx context.Context, path string, data []byte, opt *storage.PutRequestOptions) error {
+	input := s3.PutObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(path),
+		Body:   bytes.NewReader(data),
+	}
+	if opt != nil && opt.ContentType != "" {
+		input.ContentType = aws.String(opt.ContentType)
+	}
+	errChannel := make(chan error, 1)
+	makeNetworkCall(s.hystrixCmd.Name, s.hystrixCmd.Config, func() error {
+		_, err := s.service.PutObject(&input)
+		errChannel <- err
+		return err
+	}, func(e error) error {
+		errChannel <- e
+		return e
+	})
+	return <-errChannel
+}
+
+func (s *Storage) getObject(input *s3.GetObjectInput, successStatus int) storage.IResponse {
 	type getObjectResponse struct {
 		output *s3.GetObjectOutput
 		err    error
 	}
 	responseChannel := make(chan getObjectResponse, 1)
 	makeNetworkCall(s.hystrixCmd.Name, s.hystrixCmd.Config, func() error {
-		resp, err := s.service.GetObject(&input)
+		resp, err := s.service.GetObject(input)
 		responseChannel <- getObjectResponse{
 			output: resp,
 			err:    err,
@@ -81,20 +92,16 @@ func (s *Storage) GetPartially(ctx context.Context, path string, opt *storage.Ge
 		}
 		return e
 	})
-
 	s3Resp := <-responseChannel
-
 	var metadata *storage.ResponseMetadata
 	var body []byte
-	var status int
 	if s3Resp.err == nil {
 		metadata = s.newMetadata(*s3Resp.output)
 		body, _ = ioutil.ReadAll(s3Resp.output.Body)
-		status = http.StatusPartialContent
+		_ = s3Resp.output.Body.Close()
 	}
-
 	return storage.
-		NewResponse(body, getStatusCodeFromError(s3Resp.err, &status), s3Resp.err).
+		NewResponse(body, getStatusCodeFromError(s3Resp.err, &successStatus), s3Resp.err).
 		WithMetadata(metadata)
 }
 
@@ -127,6 +134,5 @@ func NewStorage(opts ...Option) *Storage {
 		)
 	ssn, _ := session.NewSession(cfg)
 	s.service = s3.New(ssn)
-	s.downloader = s3manager.NewDownloaderWithClient(s.service)
 	return &s
 }
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"errors"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"testing"
@@ -14,7 +13,6 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/gojek/darkroom/pkg/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -50,7 +48,6 @@ func (s *StorageTestSuite) SetupSuite() {
 			},
 		}),
 	)
-	s.storage.downloader = &mockDownloader{}
 	s.storage.service = &mockGetObject{}
 }
 
@@ -101,20 +98,16 @@ func (s *StorageTestSuite) TestStorage_GetPartialObjectFailure() {
 	assert.Equal(s.T(), http.StatusUnprocessableEntity, res.Status())
 }
 
-type mockDownloader struct {
-	mock.Mock
-}
+func (s *StorageTestSuite) TestStorage_Put() {
+	err := s.storage.Put(context.Background(), validPath, []byte("someData"), &storage.PutRequestOptions{ContentType: "image/webp"})
 
-func (d *mockDownloader) Download(w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (int64, error) {
-	return d.DownloadWithContext(aws.BackgroundContext(), w, input, options...)
+	assert.Nil(s.T(), err)
 }
 
-func (d *mockDownloader) DownloadWithContext(ctx aws.Context, w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (int64, error) {
-	if *input.Key == validPath {
-		_, _ = w.WriteAt([]byte("someData"), 0)
-		return 0, nil
-	}
-	return 0, errors.New("error")
+func (s *StorageTestSuite) TestStorage_PutFailure() {
+	err := s.storage.Put(context.Background(), invalidPath, []byte("someData"), nil)
+
+	assert.NotNil(s.T(), err)
 }
 
 type mockGetObject struct {
@@ -123,8 +116,18 @@ type mockGetObject struct {
 }
 
 func (d *mockGetObject) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
-	if *input.Range == validRange {
-		t, _ := time.Parse(http.TimeFormat, "Wed, 21 Oct 2015 07:28:00 GMT")
+	t, _ := time.Parse(http.TimeFormat, "Wed, 21 Oct 2015 07:28:00 GMT")
+	if input.Range == nil && *input.Key == validPath {
+		return &s3.GetObjectOutput{
+			AcceptRanges:  aws.String("bytes"),
+			ContentLength: aws.Int64(247103),
+			ContentType:   aws.String("image/png"),
+			ETag:          aws.String("32705ce195789d7bf07f3d44783c2988"),
+			LastModified:  aws.Time(t),
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte("someData"))),
+		}, nil
+	}
+	if input.Range != nil && *input.Range == validRange {
 		return &s3.GetObjectOutput{
 			AcceptRanges:  aws.String("bytes"),
 			ContentLength: aws.Int64(101),
@@ -137,3 +140,11 @@ func (d *mockGetObject) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput
 	}
 	return nil, errors.New("error")
 }
+
+func (d *mockGetObject) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	body, _ := ioutil.ReadAll(input.Body)
+	if *input.Key == validPath && string(body) == "someData" && aws.StringValue(input.ContentType) == "image/webp" {
+		return &s3.PutObjectOutput{}, nil
+	}
+	return nil, errors.New("error")
+}
@@ -34,11 +34,18 @@ func getCredentialsOption(opts *Options) option.ClientOption {
 	return option.WithoutAuthentication()
 }
 
+func getScope(opts *Options) string {
+	if opts.Writable {
+		return storage.ScopeReadWrite
+	}
+	return storage.ScopeReadOnly
+}
+
 func newTransport(ctx context.Context, opts *Options) (http.RoundTripper, error) {
 	return gcloud.NewTransport(ctx,
 		&hystrixTransport{client: opts.Client},
 		option.WithUserAgent(userAgent),
-		option.WithScopes(storage.ScopeReadOnly),
+		option.WithScopes(getScope(opts)),
 		getCredentialsOption(opts),
 	)
 }
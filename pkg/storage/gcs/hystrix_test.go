@@ -6,10 +6,16 @@ import (
 	"net/http"
 	"testing"
 
+	"cloud.google.com/go/storage"
 	"github.com/gojektech/heimdall/hystrix"
 	"github.com/stretchr/testify/assert"
 )
 
+func TestGetScope(t *testing.T) {
+	assert.Equal(t, storage.ScopeReadOnly, getScope(&Options{}))
+	assert.Equal(t, storage.ScopeReadWrite, getScope(&Options{Writable: true}))
+}
+
 func TestNewHeimdallHTTPClientWithInvalidCredentials(t *testing.T) {
 	hc := hystrix.NewClient()
 	hhc, err := newHeimdallHTTPClient(context.TODO(), &Options{
@@ -14,6 +14,7 @@ type ObjectHandle interface {
 	NewReader(ctx context.Context) (Reader, error)
 	NewRangeReader(ctx context.Context, offset, length int64) (Reader, error)
 	Attrs(ctx context.Context) (attrs *storage.ObjectAttrs, err error)
+	NewWriter(ctx context.Context, contentType string) Writer
 }
 
 type BucketHandle interface {
@@ -23,12 +24,19 @@ type BucketHandle interface {
 
 type Reader interface {
 	io.ReadCloser
+	// Attrs returns the attributes of the generation of the object which is read
+	Attrs() storage.ReaderObjectAttrs
+}
+
+type Writer interface {
+	io.WriteCloser
 }
 
 type (
 	bucketHandle struct{ *storage.BucketHandle }
 	objectHandle struct{ *storage.ObjectHandle }
 	reader       struct{ *storage.Reader }
+	writer       struct{ *storage.Writer }
 )
 
 func (b bucketHandle) Object(name string) ObjectHandle {
@@ -40,6 +48,16 @@ func (o objectHandle) NewReader(ctx context.Context) (Reader, error) {
 	return reader{r}, err
 }
 
+func (r reader) Attrs() storage.ReaderObjectAttrs {
+	return r.Reader.Attrs
+}
+
+func (o objectHandle) NewWriter(ctx context.Context, contentType string) Writer {
+	w := o.ObjectHandle.NewWriter(ctx)
+	w.ContentType = contentType
+	return writer{w}
+}
+
 func (o objectHandle) NewRangeReader(ctx context.Context, offset, length int64) (Reader, error) {
 	r, err := o.ObjectHandle.NewRangeReader(ctx, offset, length)
 	return reader{r}, err
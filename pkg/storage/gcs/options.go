@@ -15,4 +15,6 @@ type Options struct {
 	Credentials *google.Credentials
 	// Client can be used to specify a heimdall.Client with hystrix like circuit breaker
 	Client heimdall.Client
+	// Writable requests the read-write scope, which is required to Put objects in the bucket
+	Writable bool
 }
@@ -5,12 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"math"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	"google.golang.org/api/option"
 
@@ -20,8 +18,9 @@ import (
 )
 
 var (
-	rangeRegex      = regexp.MustCompile(`bytes=(\d+)-(\d+)`)
-	ErrInvalidRange = errors.New("invalid range")
+	rangeRegex = regexp.MustCompile(`^bytes=(\d*)-(\d*)$`)
+	// ErrInvalidRange is returned by GetPartially for the ranges which are not a single byte range
+	ErrInvalidRange = storage.ErrInvalidRange
 )
 
 // Storage holds the fields used by Google Cloud Storage implementation
@@ -44,7 +43,8 @@ func NewStorage(opts Options) (*Storage, error) {
 // This method figures out how to get the data from the S3 storage backend.
 func (s *Storage) Get(ctx context.Context, path string) storage.IResponse {
 	path = strings.TrimPrefix(path, "/")
-	r, err := s.bucketHandle.Object(path).NewReader(ctx)
+	objHandle := s.bucketHandle.Object(path)
+	r, err := objHandle.NewReader(ctx)
 
 	if err != nil {
 		if errors.Is(err, gs.ErrObjectNotExist) {
@@ -64,7 +64,9 @@ func (s *Storage) Get(ctx context.Context, path string) storage.IResponse {
 	if err != nil {
 		return storage.NewResponse(nil, http.StatusUnprocessableEntity, err)
 	}
-	return storage.NewResponse(d, http.StatusOK, nil)
+	// The metadata is taken from the reader so that it describes the same generation of the object as the data
+	return storage.NewResponse(d, http.StatusOK, nil).
+		WithMetadata(s.parseReaderMetadata(r.Attrs()))
 }
 
 // GetPartially takes in the Context, path and opt as an argument and returns an IResponse interface implementation.
@@ -100,26 +102,92 @@ func (s *Storage) GetPartially(ctx context.Context, path string, opt *storage.Ge
 		WithMetadata(s.parseMetadata(objAttrs, offset, length))
 }
 
+// Put takes in the Context, path, data and opt as an argument and returns an error if the data couldn't be stored.
+// This method figures out how to store the data in the Google Cloud Storage backend.
+func (s *Storage) Put(ctx context.Context, path string, data []byte, opt *storage.PutRequestOptions) error {
+	var contentType string
+	if opt != nil {
+		contentType = opt.ContentType
+	}
+	w := s.bucketHandle.Object(strings.TrimPrefix(path, "/")).NewWriter(ctx, contentType)
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// parseRange returns the offset and the length of the single byte range of input as they are taken by
+// NewRangeReader: the length is -1 for the ranges which end at the end of the object, eg: bytes=100-, and the
+// offset is negative for the suffix ranges, eg: bytes=-500 for the last 500 bytes
 func (s *Storage) parseRange(input string) (int64, int64, error) {
-	matches := rangeRegex.FindStringSubmatch(input)
-	if matches == nil {
+	matches := rangeRegex.FindStringSubmatch(strings.TrimSpace(input))
+	if matches == nil || (matches[1] == "" && matches[2] == "") {
+		return 0, 0, errors.New("range parse error")
+	}
+	if matches[1] == "" {
+		suffix, err := strconv.ParseInt(matches[2], 10, 64)
+		if err != nil || suffix == 0 {
+			return 0, 0, errors.New("range parse error")
+		}
+		return -suffix, -1, nil
+	}
+	start, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return 0, 0, errors.New("range parse error")
+	}
+	if matches[2] == "" {
+		return start, -1, nil
+	}
+	end, err := strconv.ParseInt(matches[2], 10, 64)
+	if err != nil || end < start {
 		return 0, 0, errors.New("range parse error")
 	}
-	start, _ := strconv.ParseInt(matches[1], 10, 64)
-	end, _ := strconv.ParseInt(matches[2], 10, 64)
 	return start, (end - start) + 1, nil
 }
 
+// parseReaderMetadata returns the metadata of the object read with attrs, its ETag is the generation of the object
+// which changes every time its data is overwritten
+func (s *Storage) parseReaderMetadata(attrs gs.ReaderObjectAttrs) *storage.ResponseMetadata {
+	md := &storage.ResponseMetadata{
+		AcceptRanges:  "bytes",
+		ContentLength: strconv.FormatInt(attrs.Size, 10),
+		ContentType:   attrs.ContentType,
+	}
+	if attrs.Generation != 0 {
+		md.ETag = strconv.FormatInt(attrs.Generation, 10)
+	}
+	if !attrs.LastModified.IsZero() {
+		md.LastModified = attrs.LastModified.UTC().Format(http.TimeFormat)
+	}
+	return md
+}
+
+// parseMetadata returns the metadata of the part of the object read from offset with length as they are taken by
+// NewRangeReader, the part is cut at the end of the object
 func (s *Storage) parseMetadata(attrs *gs.ObjectAttrs, offset, length int64) *storage.ResponseMetadata {
-	return &storage.ResponseMetadata{
+	if offset < 0 {
+		offset += attrs.Size
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	end := offset + length
+	if length < 0 || end > attrs.Size {
+		end = attrs.Size
+	}
+	md := &storage.ResponseMetadata{
 		// TODO: bytes is the only range unit formally defined by RFC 7233,
 		// update this when GCS supports getting it via headers.
 		// Ref: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Accept-Ranges
 		AcceptRanges:  "bytes",
-		ContentLength: strconv.FormatInt(int64(math.Abs(float64(offset-length))), 10),
-		ContentRange:  fmt.Sprintf("bytes %d-%d/%d", offset, length-1, attrs.Size),
+		ContentLength: strconv.FormatInt(end-offset, 10),
+		ContentRange:  fmt.Sprintf("bytes %d-%d/%d", offset, end-1, attrs.Size),
 		ContentType:   attrs.ContentType,
 		ETag:          attrs.Etag,
-		LastModified:  attrs.Updated.Format(time.RFC1123),
 	}
+	if !attrs.Updated.IsZero() {
+		md.LastModified = attrs.Updated.UTC().Format(http.TimeFormat)
+	}
+	return md
 }
@@ -1,6 +1,7 @@
 package gcs
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"io/ioutil"
@@ -111,9 +112,24 @@ func (s *StorageTestSuite) TestBenchForStorage_Get() {
 			ctx:  context.TODO(),
 			path: validPath,
 			newReaderReturn: func() (Reader, error) {
-				return ioutil.NopCloser(strings.NewReader("someData")), nil
+				return newMockReader("someData", validReaderAttrs), nil
 			},
-			res: storageTypes.NewResponse([]byte("someData"), http.StatusOK, nil),
+			res: storageTypes.NewResponse([]byte("someData"), http.StatusOK, nil).
+				WithMetadata(validObjectMetadata),
+		},
+		{
+			name: "SuccessWithoutGeneration",
+			ctx:  context.TODO(),
+			path: validPath,
+			newReaderReturn: func() (Reader, error) {
+				return newMockReader("someData", storage.ReaderObjectAttrs{Size: 8, ContentType: "image/png"}), nil
+			},
+			res: storageTypes.NewResponse([]byte("someData"), http.StatusOK, nil).
+				WithMetadata(&storageTypes.ResponseMetadata{
+					AcceptRanges:  "bytes",
+					ContentLength: "8",
+					ContentType:   "image/png",
+				}),
 		},
 		{
 			name: "FailureWithInvalidPath",
@@ -157,6 +173,60 @@ func (s *StorageTestSuite) TestBenchForStorage_Get() {
 			s.Equal(t.res.Data(), res.Data())
 			s.Equal(t.res.Status(), res.Status())
 			s.Equal(t.res.Metadata(), res.Metadata())
+			mo.AssertNotCalled(s.T(), "Attrs", mock.Anything)
+		})
+	}
+}
+
+func (s *StorageTestSuite) TestBenchForStorage_Put() {
+	testcases := []struct {
+		name   string
+		writer *mockWriter
+		opt    *storageTypes.PutRequestOptions
+		err    error
+	}{
+		{
+			name:   "Success",
+			writer: &mockWriter{},
+			opt:    &storageTypes.PutRequestOptions{ContentType: "image/webp"},
+		},
+		{
+			name:   "SuccessWithNilRequestOptions",
+			writer: &mockWriter{},
+		},
+		{
+			name:   "FailureWhileWriting",
+			writer: &mockWriter{writeErr: io.ErrShortWrite},
+			opt:    &storageTypes.PutRequestOptions{ContentType: "image/webp"},
+			err:    io.ErrShortWrite,
+		},
+		{
+			name:   "FailureWhileClosing",
+			writer: &mockWriter{closeErr: io.ErrUnexpectedEOF},
+			opt:    &storageTypes.PutRequestOptions{ContentType: "image/webp"},
+			err:    io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, t := range testcases {
+		s.SetupTest()
+		s.Run(t.name, func() {
+			ctx := context.Background()
+			var contentType string
+			if t.opt != nil {
+				contentType = t.opt.ContentType
+			}
+			mo := &mockObjectHandle{objectKey: validPath}
+			s.bucketHandle.On("Object", validPath).Return(mo)
+			mo.On("NewWriter", ctx, contentType).Return(t.writer)
+
+			err := s.storage.Put(ctx, "/"+validPath, []byte("someData"), t.opt)
+
+			s.Equal(t.err, err)
+			if t.err == nil {
+				s.Equal("someData", t.writer.String())
+			}
+			mo.AssertExpectations(s.T())
 		})
 	}
 }
@@ -167,17 +237,35 @@ func (s *StorageTestSuite) TestStorageRangeGetter() {
 	s.Equal(int64(101), length)
 	s.NoError(err)
 
-	offset, length, err = s.storage.parseRange(invalidRange)
+	offset, length, err = s.storage.parseRange("bytes=0-")
 	s.Equal(int64(0), offset)
-	s.Equal(int64(0), length)
-	s.Error(err)
+	s.Equal(int64(-1), length)
+	s.NoError(err)
+
+	offset, length, err = s.storage.parseRange("bytes=-500")
+	s.Equal(int64(-500), offset)
+	s.Equal(int64(-1), length)
+	s.NoError(err)
+
+	for _, input := range []string{invalidRange, "bytes=-", "bytes=-0", "bytes=200-100", "bytes=0-1,5-6", "items=0-1"} {
+		offset, length, err = s.storage.parseRange(input)
+		s.Equal(int64(0), offset, input)
+		s.Equal(int64(0), length, input)
+		s.Error(err, input)
+	}
 }
 
 func (s *StorageTestSuite) TestBenchForStorage_GetPartially() {
 	validRange := validRange
 	invalidRange := invalidRange
 	outOfBoundRange := "bytes=4000-5000"
+	offsetRange := "bytes=100-200"
+	tailRange := "bytes=247000-247999"
+	openEndedRange := "bytes=100-"
+	suffixRange := "bytes=-500"
 	emptyRange := ""
+	// The object is updated at the same time as in validObjectMetadata, in another time zone
+	updated := time.Date(2015, 10, 21, 9, 28, 0, 0, time.FixedZone("CEST", 2*60*60))
 	testcases := []struct {
 		name                 string
 		ctx                  context.Context
@@ -194,7 +282,7 @@ func (s *StorageTestSuite) TestBenchForStorage_GetPartially() {
 			path:     validPath,
 			rangeStr: &validRange,
 			newRangeReaderReturn: func() (Reader, error) {
-				return ioutil.NopCloser(strings.NewReader("someData")), nil
+				return newMockReader("someData", storage.ReaderObjectAttrs{}), nil
 			},
 			attrsReturn: func() (*storage.ObjectAttrs, error) {
 				t, _ := time.Parse(time.RFC1123, "Wed, 21 Oct 2015 07:28:00 GMT")
@@ -217,14 +305,96 @@ func (s *StorageTestSuite) TestBenchForStorage_GetPartially() {
 					LastModified:  "Wed, 21 Oct 2015 07:28:00 GMT",
 				}),
 		},
+		{
+			name:     "SuccessWithOffset",
+			ctx:      context.TODO(),
+			path:     validPath,
+			rangeStr: &offsetRange,
+			newRangeReaderReturn: func() (Reader, error) {
+				return newMockReader("someData", storage.ReaderObjectAttrs{}), nil
+			},
+			attrsReturn: func() (*storage.ObjectAttrs, error) {
+				return &storage.ObjectAttrs{ContentType: "image/png", Size: 247103, Updated: updated, Etag: "etag"}, nil
+			},
+			res: storageTypes.NewResponse([]byte("someData"), http.StatusPartialContent, nil).
+				WithMetadata(&storageTypes.ResponseMetadata{
+					AcceptRanges:  "bytes",
+					ContentLength: "101",
+					ContentRange:  "bytes 100-200/247103",
+					ContentType:   "image/png",
+					ETag:          "etag",
+					LastModified:  "Wed, 21 Oct 2015 07:28:00 GMT",
+				}),
+		},
+		{
+			name:     "SuccessWithRangePastTheEnd",
+			ctx:      context.TODO(),
+			path:     validPath,
+			rangeStr: &tailRange,
+			newRangeReaderReturn: func() (Reader, error) {
+				return newMockReader("someData", storage.ReaderObjectAttrs{}), nil
+			},
+			attrsReturn: func() (*storage.ObjectAttrs, error) {
+				return &storage.ObjectAttrs{ContentType: "image/png", Size: 247103, Etag: "etag"}, nil
+			},
+			res: storageTypes.NewResponse([]byte("someData"), http.StatusPartialContent, nil).
+				WithMetadata(&storageTypes.ResponseMetadata{
+					AcceptRanges:  "bytes",
+					ContentLength: "103",
+					ContentRange:  "bytes 247000-247102/247103",
+					ContentType:   "image/png",
+					ETag:          "etag",
+				}),
+		},
+		{
+			name:     "SuccessWithOpenEndedRange",
+			ctx:      context.TODO(),
+			path:     validPath,
+			rangeStr: &openEndedRange,
+			newRangeReaderReturn: func() (Reader, error) {
+				return newMockReader("someData", storage.ReaderObjectAttrs{}), nil
+			},
+			attrsReturn: func() (*storage.ObjectAttrs, error) {
+				return &storage.ObjectAttrs{ContentType: "image/png", Size: 247103, Etag: "etag"}, nil
+			},
+			res: storageTypes.NewResponse([]byte("someData"), http.StatusPartialContent, nil).
+				WithMetadata(&storageTypes.ResponseMetadata{
+					AcceptRanges:  "bytes",
+					ContentLength: "247003",
+					ContentRange:  "bytes 100-247102/247103",
+					ContentType:   "image/png",
+					ETag:          "etag",
+				}),
+		},
+		{
+			name:     "SuccessWithSuffixRange",
+			ctx:      context.TODO(),
+			path:     validPath,
+			rangeStr: &suffixRange,
+			newRangeReaderReturn: func() (Reader, error) {
+				return newMockReader("someData", storage.ReaderObjectAttrs{}), nil
+			},
+			attrsReturn: func() (*storage.ObjectAttrs, error) {
+				return &storage.ObjectAttrs{ContentType: "image/png", Size: 247103, Etag: "etag"}, nil
+			},
+			res: storageTypes.NewResponse([]byte("someData"), http.StatusPartialContent, nil).
+				WithMetadata(&storageTypes.ResponseMetadata{
+					AcceptRanges:  "bytes",
+					ContentLength: "500",
+					ContentRange:  "bytes 246603-247102/247103",
+					ContentType:   "image/png",
+					ETag:          "etag",
+				}),
+		},
 		{
 			name: "WithNilRequestOptions",
 			ctx:  context.TODO(),
 			path: validPath,
 			newReaderReturn: func() (Reader, error) {
-				return ioutil.NopCloser(strings.NewReader("someData")), nil
+				return newMockReader("someData", validReaderAttrs), nil
 			},
-			res: storageTypes.NewResponse([]byte("someData"), http.StatusOK, nil),
+			res: storageTypes.NewResponse([]byte("someData"), http.StatusOK, nil).
+				WithMetadata(validObjectMetadata),
 		},
 		{
 			name:     "WithEmptyRangeValue",
@@ -232,9 +402,10 @@ func (s *StorageTestSuite) TestBenchForStorage_GetPartially() {
 			path:     validPath,
 			rangeStr: &emptyRange,
 			newReaderReturn: func() (Reader, error) {
-				return ioutil.NopCloser(strings.NewReader("someData")), nil
+				return newMockReader("someData", validReaderAttrs), nil
 			},
-			res: storageTypes.NewResponse([]byte("someData"), http.StatusOK, nil),
+			res: storageTypes.NewResponse([]byte("someData"), http.StatusOK, nil).
+				WithMetadata(validObjectMetadata),
 		},
 		{
 			name: "OnInvalidPath",
@@ -251,7 +422,7 @@ func (s *StorageTestSuite) TestBenchForStorage_GetPartially() {
 			path:     validPath,
 			rangeStr: &invalidRange,
 			newReaderReturn: func() (Reader, error) {
-				return ioutil.NopCloser(strings.NewReader("someData")), nil
+				return newMockReader("someData", storage.ReaderObjectAttrs{}), nil
 			},
 			res: storageTypes.NewResponse(nil, http.StatusUnprocessableEntity, ErrInvalidRange),
 		},
@@ -294,7 +465,7 @@ func (s *StorageTestSuite) TestBenchForStorage_GetPartially() {
 			path:     invalidPath,
 			rangeStr: &validRange,
 			newRangeReaderReturn: func() (Reader, error) {
-				return ioutil.NopCloser(strings.NewReader("someData")), nil
+				return newMockReader("someData", storage.ReaderObjectAttrs{}), nil
 			},
 			attrsReturn: func() (*storage.ObjectAttrs, error) {
 				return nil, storage.ErrObjectNotExist
@@ -334,6 +505,21 @@ func (s *StorageTestSuite) TestBenchForStorage_GetPartially() {
 	}
 }
 
+var validObjectMetadata = &storageTypes.ResponseMetadata{
+	AcceptRanges:  "bytes",
+	ContentLength: "247103",
+	ContentType:   "image/png",
+	ETag:          "1445412480000000",
+	LastModified:  "Wed, 21 Oct 2015 07:28:00 GMT",
+}
+
+var validReaderAttrs = storage.ReaderObjectAttrs{
+	ContentType:  "image/png",
+	Size:         247103,
+	LastModified: time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC),
+	Generation:   1445412480000000,
+}
+
 type mockBucketHandle struct {
 	mock.Mock
 }
@@ -377,6 +563,28 @@ func (m *mockObjectHandle) NewRangeReader(ctx context.Context, offset, length in
 	return args[0].(Reader), args.Error(1)
 }
 
+func (m *mockObjectHandle) NewWriter(ctx context.Context, contentType string) Writer {
+	args := m.Called(ctx, contentType)
+	return args[0].(Writer)
+}
+
+type mockWriter struct {
+	bytes.Buffer
+	writeErr error
+	closeErr error
+}
+
+func (w *mockWriter) Write(p []byte) (int, error) {
+	if w.writeErr != nil {
+		return 0, w.writeErr
+	}
+	return w.Buffer.Write(p)
+}
+
+func (w *mockWriter) Close() error {
+	return w.closeErr
+}
+
 type badReader struct{}
 
 func (b badReader) Read(p []byte) (n int, err error) {
@@ -386,3 +594,20 @@ func (b badReader) Read(p []byte) (n int, err error) {
 func (b badReader) Close() error {
 	return nil
 }
+
+func (b badReader) Attrs() storage.ReaderObjectAttrs {
+	return storage.ReaderObjectAttrs{}
+}
+
+type mockReader struct {
+	io.ReadCloser
+	attrs storage.ReaderObjectAttrs
+}
+
+func newMockReader(data string, attrs storage.ReaderObjectAttrs) *mockReader {
+	return &mockReader{ReadCloser: ioutil.NopCloser(strings.NewReader(data)), attrs: attrs}
+}
+
+func (r *mockReader) Attrs() storage.ReaderObjectAttrs {
+	return r.attrs
+}
@@ -13,6 +13,19 @@ type Storage interface {
 	GetPartially(ctx context.Context, path string, opt *GetPartiallyRequestOptions) IResponse
 }
 
+// Writer interface sets the contract that the implementation of a Storage backend which can also store data has to fulfil.
+type Writer interface {
+	// Put takes in the Context, path, data and opt as an argument and returns an error if the data couldn't be stored.
+	// This method figures out how to store the data at the path in the storage backend.
+	Put(ctx context.Context, path string, data []byte, opt *PutRequestOptions) error
+}
+
+// ReadWriter interface groups the Storage and Writer interfaces.
+type ReadWriter interface {
+	Storage
+	Writer
+}
+
 // IResponse interface sets the contract that can be used to return the result from different Storage backends.
 type IResponse interface {
 	// Data method returns a byte array if the operation was successful
@@ -1,6 +1,15 @@
 package storage
 
-import "github.com/afex/hystrix-go/hystrix"
+import (
+	"errors"
+
+	"github.com/afex/hystrix-go/hystrix"
+)
+
+// ErrInvalidRange is returned by the backends which can't parse the range requested with GetPartially, the
+// range can be ignored and the whole object served instead as described in
+// https://tools.ietf.org/html/rfc7233#section-3.1
+var ErrInvalidRange = errors.New("invalid range")
 
 // ResponseMetadata contains metadata of the storage response
 type ResponseMetadata struct {
@@ -61,3 +70,8 @@ type HystrixCommand struct {
 type GetPartiallyRequestOptions struct {
 	Range string
 }
+
+// PutRequestOptions holds option to store data in storage
+type PutRequestOptions struct {
+	ContentType string
+}